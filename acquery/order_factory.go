@@ -5,7 +5,7 @@ import (
 	"reflect"
 	"sync"
 
-	"github.com/teawithsand/arcah/internal/refutil"
+	"github.com/teawithsand/arcah/mongoutil"
 	"github.com/teawithsand/reval/stdesc"
 )
 
@@ -20,17 +20,22 @@ type mongoOrderSchemaFactory struct {
 }
 
 func NewMongoOrderSchemaFactory() OrderSchemaFactory {
+	return NewMongoOrderSchemaFactoryWithTagParser(mongoutil.DefaultStructTagParser)
+}
+
+// Creates order schema factory, which uses specified parser to obtain BSON names of fields.
+// Parser should match one used by mongo driver.
+func NewMongoOrderSchemaFactoryWithTagParser(bsonTagParser mongoutil.StructTagParser) OrderSchemaFactory {
 	return &mongoOrderSchemaFactory{
 		computer: &stdesc.Computer{
 			FieldProcessorFactory: stdesc.FieldProcessorFunc(func(pf stdesc.PendingFiled) (options stdesc.FieldOptions, err error) {
-				meta := refutil.BSONFieldMeta{}
-				err = meta.ParseTag(pf.Field.Tag.Get(refutil.BsonTagName))
+				tags, err := bsonTagParser.ParseStructTags(pf.Field)
 				if err != nil {
 					return
 				}
 
-				options.Name = meta.BSONFieldName
-				options.Skip = meta.Skip
+				options.Name = tags.Name
+				options.Skip = tags.Skip
 				// TODO(teawithsand): support for embedding
 				// options.Embed =
 
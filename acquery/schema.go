@@ -0,0 +1,62 @@
+package acquery
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/teawithsand/arcah/jsonschema"
+)
+
+// Returns schema of OrderFields text, which passes validation of this schema.
+// Schema does not disallow fields used twice.
+func (schema *OrderSchema) JSONSchema() *jsonschema.Schema {
+	aliases := make([]string, 0, len(schema.AliasToField))
+	for alias := range schema.AliasToField {
+		aliases = append(aliases, regexp.QuoteMeta(alias))
+	}
+	sort.Strings(aliases)
+
+	res := &jsonschema.Schema{
+		Type:        "string",
+		Description: "Space separated fields to order by, prefixed with '+' for ascending or '-' for descending order",
+	}
+	if len(aliases) == 0 {
+		maxLength := 0
+		res.MaxLength = &maxLength
+		return res
+	}
+
+	field := "[+-](?:" + strings.Join(aliases, "|") + ")"
+	res.Pattern = "^" + field + "(?: " + field + ")*$"
+	return res
+}
+
+// Returns schema of pagination, which limit is at most maxLimit.
+// Zero maxLimit means no limit.
+func PaginationSchema(generator *jsonschema.Generator, maxLimit uint32) *jsonschema.Schema {
+	res := generator.TypeSchema(reflect.TypeOf(Pagination{})).Clone()
+	if maxLimit == 0 {
+		return res
+	}
+
+	limit := res.Properties["limit"].Clone()
+	max := float64(maxLimit)
+	limit.Maximum = &max
+	res.Properties["limit"] = limit
+	return res
+}
+
+// Returns schema of filter structure, which is encoded as JSON.
+// Rules of valid tags of its fields are included.
+func FilterSchema(generator *jsonschema.Generator, filter interface{}) *jsonschema.Schema {
+	ty := reflect.TypeOf(filter)
+	if ty == nil {
+		return &jsonschema.Schema{}
+	}
+	for ty.Kind() == reflect.Ptr {
+		ty = ty.Elem()
+	}
+	return generator.TypeSchema(ty)
+}
@@ -0,0 +1,222 @@
+// Package arcahvet provides analyzer, which checks struct tags used by arcah.
+//
+// It parses tags with same code as arcah does at runtime, so tag mistakes can be found in CI, before any code runs.
+package arcahvet
+
+import (
+	"go/ast"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/teawithsand/arcah/mongoutil"
+	"github.com/teawithsand/arcah/mttor"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const doc = `check struct tags used by arcah
+
+Checks mttor and bson tags for unknown mutators and flags, malformed values,
+duplicate names and tags set on unexported fields, which are ignored.`
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "arcahvet",
+	Doc:      doc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+var customMutators string
+
+func init() {
+	Analyzer.Flags.StringVar(&customMutators, "mutators", "", "comma separated names of custom mutators registered in engines")
+}
+
+var knownBSONFlags = map[string]struct{}{
+	"omitempty": {},
+	"minsize":   {},
+	"truncate":  {},
+	"inline":    {},
+}
+
+func knownMutators() map[string]struct{} {
+	mutators := map[string]struct{}{}
+	for _, name := range mttor.BuiltinMutationNames() {
+		mutators[name] = struct{}{}
+	}
+	for _, name := range strings.Split(customMutators, ",") {
+		mutators[strings.TrimSpace(name)] = struct{}{}
+	}
+	return mutators
+}
+
+type structField struct {
+	field *ast.Field
+	name  string
+	tag   reflect.StructTag
+}
+
+func (sf *structField) isExported() bool {
+	return ast.IsExported(sf.name)
+}
+
+// Returns fields of struct, each name of field separately.
+func listFields(st *ast.StructType) (fields []structField) {
+	for _, f := range st.Fields.List {
+		var tag reflect.StructTag
+		if f.Tag != nil {
+			value, err := strconv.Unquote(f.Tag.Value)
+			if err == nil {
+				tag = reflect.StructTag(value)
+			}
+		}
+
+		if len(f.Names) == 0 {
+			// embedded field
+			ty := f.Type
+			if star, ok := ty.(*ast.StarExpr); ok {
+				ty = star.X
+			}
+			if sel, ok := ty.(*ast.SelectorExpr); ok {
+				ty = sel.Sel
+			}
+
+			name := ""
+			if ident, ok := ty.(*ast.Ident); ok {
+				name = ident.Name
+			}
+
+			fields = append(fields, structField{field: f, name: name, tag: tag})
+			continue
+		}
+
+		for _, n := range f.Names {
+			fields = append(fields, structField{field: f, name: n.Name, tag: tag})
+		}
+	}
+	return
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	mutators := knownMutators()
+
+	inspect.Preorder([]ast.Node{(*ast.StructType)(nil)}, func(n ast.Node) {
+		fields := listFields(n.(*ast.StructType))
+		checkMttorTags(pass, fields, mutators)
+		checkBSONTags(pass, fields)
+	})
+
+	return nil, nil
+}
+
+func checkMttorTags(pass *analysis.Pass, fields []structField, mutators map[string]struct{}) {
+	isMutation := false
+	for _, f := range fields {
+		tag, ok := f.tag.Lookup(mttor.TagName)
+		if ok && !mttor.IsTargetTag(tag) {
+			isMutation = true
+		}
+	}
+
+	targets := map[string]string{}
+	for _, f := range fields {
+		tag, ok := f.tag.Lookup(mttor.TagName)
+		if ok && !f.isExported() {
+			pass.Reportf(f.field.Pos(), "mttor tag on unexported field %s is ignored", f.name)
+			continue
+		}
+
+		if ok && mttor.IsTargetTag(tag) {
+			_, err := mttor.ParseTargetTag(tag)
+			if err != nil {
+				pass.Reportf(f.field.Pos(), "malformed mttor tag of field %s: %s", f.name, err)
+			}
+			continue
+		}
+
+		if !isMutation || !f.isExported() {
+			continue
+		}
+
+		parsed, err := mttor.ParseMutationTag(tag)
+		if err != nil {
+			pass.Reportf(f.field.Pos(), "malformed mttor tag of field %s: %s", f.name, err)
+			continue
+		}
+
+		if parsed.TargetFieldName == "-" {
+			continue
+		}
+
+		if _, ok := mutators[parsed.MutationName]; !ok {
+			pass.Reportf(f.field.Pos(), "field %s uses unknown mutator %q", f.name, parsed.MutationName)
+		}
+
+		// embedded structures without mutation are flattened, so they do not target any field
+		if len(f.field.Names) == 0 && parsed.MutationName == "" {
+			continue
+		}
+
+		target := parsed.TargetFieldName
+		if len(target) == 0 {
+			target = f.name
+		}
+
+		if other, ok := targets[target]; ok {
+			pass.Reportf(f.field.Pos(), "field %s targets %s, which is already targeted by field %s", f.name, target, other)
+			continue
+		}
+		targets[target] = f.name
+	}
+}
+
+func checkBSONTags(pass *analysis.Pass, fields []structField) {
+	hasBSONTags := false
+	for _, f := range fields {
+		if _, ok := f.tag.Lookup(mongoutil.BSONTagName); ok {
+			hasBSONTags = true
+		}
+	}
+	if !hasBSONTags {
+		return
+	}
+
+	names := map[string]string{}
+	for _, f := range fields {
+		tag, ok := f.tag.Lookup(mongoutil.BSONTagName)
+		if ok && !f.isExported() {
+			pass.Reportf(f.field.Pos(), "bson tag on unexported field %s is ignored", f.name)
+			continue
+		}
+
+		if !f.isExported() {
+			continue
+		}
+
+		// note: fields without bson tag are checked as well, since their default names may collide
+		parsed, err := mongoutil.ParseTag(mongoutil.DefaultFieldName(f.name), tag)
+		if err != nil {
+			pass.Reportf(f.field.Pos(), "malformed bson tag of field %s: %s", f.name, err)
+			continue
+		}
+
+		for _, flag := range strings.Split(tag, ",")[1:] {
+			if _, ok := knownBSONFlags[flag]; !ok && len(flag) > 0 {
+				pass.Reportf(f.field.Pos(), "field %s has unknown bson flag %q", f.name, flag)
+			}
+		}
+
+		if parsed.Skip || parsed.Inline {
+			continue
+		}
+
+		if other, ok := names[parsed.Name]; ok {
+			pass.Reportf(f.field.Pos(), "field %s has bson name %s, which is already used by field %s", f.name, parsed.Name, other)
+			continue
+		}
+		names[parsed.Name] = f.name
+	}
+}
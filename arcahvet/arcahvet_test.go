@@ -0,0 +1,12 @@
+package arcahvet_test
+
+import (
+	"testing"
+
+	"github.com/teawithsand/arcah/arcahvet"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), arcahvet.Analyzer, "a")
+}
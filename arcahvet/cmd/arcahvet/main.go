@@ -0,0 +1,15 @@
+// Command arcahvet checks struct tags used by arcah.
+//
+// It may be run standalone or as vet tool:
+//
+//	go vet -vettool=$(which arcahvet) ./...
+package main
+
+import (
+	"github.com/teawithsand/arcah/arcahvet"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(arcahvet.Analyzer)
+}
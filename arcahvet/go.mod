@@ -0,0 +1,21 @@
+module github.com/teawithsand/arcah/arcahvet
+
+// oldest go supported by releases of x/tools, which build with current go
+go 1.22.0
+
+require (
+	github.com/teawithsand/arcah v0.1.0
+	golang.org/x/tools v0.26.0
+)
+
+require (
+	github.com/go-stack/stack v1.8.0 // indirect
+	github.com/golang/snappy v0.0.1 // indirect
+	github.com/klauspost/compress v1.13.6 // indirect
+	github.com/teawithsand/reval v0.0.4 // indirect
+	github.com/youmark/pkcs8 v0.0.0-20181117223130-1be2e3e5546d // indirect
+	go.mongodb.org/mongo-driver v1.8.4 // indirect
+	golang.org/x/crypto v0.0.0-20201216223049-8b5274cf687f // indirect
+	golang.org/x/mod v0.21.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+)
@@ -0,0 +1,26 @@
+package a
+
+type User struct {
+	ID       string `bson:"_id" mttor:"-,readonly"`
+	Username string `bson:"username"`
+	Email    string `bson:"username"`          // want `field Email has bson name username, which is already used by field Username`
+	Counter  int64  `bson:"counter,omitemtpy"` // want `field Counter has unknown bson flag "omitemtpy"`
+	secret   string `bson:"secret"`            // want `bson tag on unexported field secret is ignored`
+	Owner    string `mttor:"owner,immutable"`  // want `malformed mttor tag of field Owner: .*target fields can't be renamed`
+}
+
+type Account struct {
+	Flags string `mttor:",readonly,writable"` // want `field Flags uses unknown mutator "readonly"`
+}
+
+type UserUpdate struct {
+	Username string `mttor:",,omitempty"`
+	Login    string `mttor:"Username"`   // want `field Login targets Username, which is already targeted by field Username`
+	Counter  int64  `mttor:",increment"` // want `field Counter uses unknown mutator "increment"`
+	Tags     []int  `mttor:",push,,omitempty"`
+	Limits   []int  `mttor:",push,:x"`               // want `malformed mttor tag of field Limits: .*argument name is empty`
+	Quoted   string `mttor:"target=Quoted,mode=set"` // want `malformed mttor tag of field Quoted: .*unknown named value mode`
+	Ignored  string `mttor:"-,whatever"`             // want `malformed mttor tag of field Ignored: .*unknown flag whatever`
+	Locked   bool   `mttor:",set,readonly"`
+	internal string `mttor:",set"` // want `mttor tag on unexported field internal is ignored`
+}
@@ -0,0 +1,74 @@
+package refutil
+
+import "reflect"
+
+// Returns deep copy of value provided.
+// Unexported fields of structures are copied shallowly, since they can't be set using reflection.
+func CloneValue(v reflect.Value) reflect.Value {
+	return cloneValue(v, map[uintptr]reflect.Value{})
+}
+
+func cloneValue(v reflect.Value, visited map[uintptr]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+
+		if res, ok := visited[v.Pointer()]; ok {
+			return res
+		}
+
+		res := reflect.New(v.Type().Elem())
+		visited[v.Pointer()] = res
+		res.Elem().Set(cloneValue(v.Elem(), visited))
+		return res
+	case reflect.Struct:
+		res := reflect.New(v.Type()).Elem()
+		res.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if !res.Field(i).CanSet() {
+				continue
+			}
+			res.Field(i).Set(cloneValue(v.Field(i), visited))
+		}
+		return res
+	case reflect.Array:
+		res := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			res.Index(i).Set(cloneValue(v.Index(i), visited))
+		}
+		return res
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+
+		res := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			res.Index(i).Set(cloneValue(v.Index(i), visited))
+		}
+		return res
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+
+		res := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			res.SetMapIndex(cloneValue(iter.Key(), visited), cloneValue(iter.Value(), visited))
+		}
+		return res
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+
+		res := reflect.New(v.Type()).Elem()
+		res.Set(cloneValue(v.Elem(), visited))
+		return res
+	default:
+		return v
+	}
+}
@@ -2,12 +2,31 @@ package refutil
 
 import "reflect"
 
+// Returns kind of number, which ValueToNumber returns for values of given type.
+// It's one of Int64, Uint64 and Float64 or Invalid, when type is not number.
+func NumberKind(ty reflect.Type) reflect.Kind {
+	switch ty.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return reflect.Int64
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return reflect.Uint64
+	case reflect.Float32, reflect.Float64:
+		return reflect.Float64
+	}
+	return reflect.Invalid
+}
+
 func ValueToNumber(v reflect.Value) (res interface{}) {
-	if v.Kind() == reflect.Int || v.Kind() == reflect.Int8 || v.Kind() == reflect.Int16 || v.Kind() == reflect.Int32 || v.Kind() == reflect.Int64 {
+	if !v.IsValid() {
+		return
+	}
+
+	switch NumberKind(v.Type()) {
+	case reflect.Int64:
 		return v.Int()
-	} else if v.Kind() == reflect.Uint || v.Kind() == reflect.Uint16 || v.Kind() == reflect.Uint32 || v.Kind() == reflect.Uint64 {
+	case reflect.Uint64:
 		return v.Uint()
-	} else if v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64 {
+	case reflect.Float64:
 		return v.Float()
 	}
 	return
@@ -0,0 +1,40 @@
+package refutil
+
+import "reflect"
+
+// Allocates nil pointers to structures, which are on path to field with specified index, so field can be accessed.
+// Value must be pointer to structure.
+func AllocFieldPath(v reflect.Value, path []int) {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	for _, i := range path[:len(path)-1] {
+		v = v.Field(i)
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+	}
+}
+
+// Returns field with specified index.
+// Returns false, when field can't be accessed, because some pointer to structure on its path is nil.
+func FieldByPath(v reflect.Value, path []int) (res reflect.Value, ok bool) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	res, err := v.FieldByIndexErr(path)
+	if err != nil {
+		return
+	}
+
+	ok = true
+	return
+}
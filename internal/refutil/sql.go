@@ -0,0 +1,44 @@
+package refutil
+
+import (
+	"strings"
+
+	"github.com/teawithsand/reval/jsonutil"
+)
+
+// Tag names, which are checked for SQL column name, in order of precedence.
+const DBTagName = "db"
+const SQLTagName = "sql"
+
+// Returns name of SQL column for field, when no such name is set by hand.
+func DefaultSQLColumnName(name string) string {
+	return strings.ToLower(name)
+}
+
+type SQLFieldMeta struct {
+	SQLColumnName string
+	SQLSkip       bool
+}
+
+// Parses column name from db or sql tags.
+// Tag db takes precedence over sql tag, when both are set.
+// Column name is left empty, when tag does not set one, and field is skipped only for "-" tag.
+func (mtm *SQLFieldMeta) ParseTag(dbTags, sqlTags string) (err error) {
+	for _, tags := range []string{dbTags, sqlTags} {
+		columnName, ok := jsonutil.GetJSONFieldName(tags)
+		if !ok {
+			continue
+		}
+
+		if tags == "-" {
+			mtm.SQLSkip = true
+			return
+		}
+
+		// tag like ",omitempty" sets no name, so default one is used
+		mtm.SQLColumnName = columnName
+		return
+	}
+
+	return
+}
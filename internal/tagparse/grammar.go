@@ -0,0 +1,104 @@
+package tagparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyntaxError is returned, when tag does not follow grammar.
+type SyntaxError struct {
+	Tag string
+	// Offset in bytes of item of tag, which is invalid.
+	Offset int
+	Msg    string
+}
+
+func (err *SyntaxError) Error() string {
+	if err == nil {
+		return "<nil>"
+	}
+
+	return fmt.Sprintf("invalid tag %q at offset %d: %s", err.Tag, err.Offset, err.Msg)
+}
+
+// item is single comma separated element of tag.
+//
+// Items have form of `value`, `key:value` or `key=value`.
+// Any part may be quoted with double quotes and any character may be escaped with backslash,
+// so `"a,b"` and `a\,b` are both same value.
+type item struct {
+	Offset int
+
+	// Separator of key and value; ':', '=' or zero, when item has no key.
+	Sep   rune
+	Key   string
+	Value string
+}
+
+// Splits tag into items, handling quoting and escaping.
+func splitItems(tag string) (items []item, err error) {
+	var buf strings.Builder
+	current := item{}
+	inQuote := false
+	isEscaped := false
+
+	finish := func() {
+		current.Value = buf.String()
+		items = append(items, current)
+		buf.Reset()
+	}
+
+	for i, r := range tag {
+		switch {
+		case isEscaped:
+			buf.WriteRune(r)
+			isEscaped = false
+		case r == '\\':
+			isEscaped = true
+		case r == '"':
+			inQuote = !inQuote
+		case inQuote:
+			buf.WriteRune(r)
+		case r == ',':
+			finish()
+			current = item{Offset: i + 1}
+		case (r == ':' || r == '=') && current.Sep == 0:
+			current.Sep = r
+			current.Key = buf.String()
+			buf.Reset()
+		default:
+			buf.WriteRune(r)
+		}
+	}
+
+	if isEscaped {
+		err = &SyntaxError{Tag: tag, Offset: current.Offset, Msg: "backslash at the end of tag"}
+		return
+	}
+
+	if inQuote {
+		err = &SyntaxError{Tag: tag, Offset: current.Offset, Msg: "unterminated quoted value"}
+		return
+	}
+
+	finish()
+	return
+}
+
+// Quotes value, if it contains any characters, which have special meaning in tags.
+func quoteValue(value string) string {
+	if !strings.ContainsAny(value, ",:=\"\\") {
+		return value
+	}
+
+	var b strings.Builder
+	b.WriteRune('"')
+	for _, r := range value {
+		if r == '"' || r == '\\' {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteRune('"')
+	return b.String()
+}
@@ -0,0 +1,192 @@
+// Parsing of struct tags used by arcah.
+// It's shared by runtime descriptors and static analysis, so both of them accept same tags.
+package tagparse
+
+import (
+	"sort"
+	"strings"
+)
+
+const MttorTagName = "mttor"
+
+// Names of fields, which may be set in named form, like `target=Name`.
+const TargetKey = "target"
+const OpKey = "op"
+
+// Tag of mutation field.
+//
+// It has form of `mttor:"target,op,args..."`, where target and op may be also set anywhere in tag
+// in named form, like `mttor:"op=inc,target=Counter"`.
+// Once any named value or arg is found, following values without key are args.
+// Args have form of `key` or `key:value`, keys may be repeated.
+// Values may be quoted, like `key:"a,b"` or have special characters escaped, like `key:a\,b`.
+type MutationTag struct {
+	// Name of target field. "-" means that field is skipped.
+	TargetFieldName string
+	MutationName    string
+	Args            map[string][]string
+}
+
+func ParseMutationTag(tag string) (res MutationTag, err error) {
+	items, err := splitItems(tag)
+	if err != nil {
+		return
+	}
+
+	res.Args = map[string][]string{}
+
+	isTargetSet := false
+	isOpSet := false
+	setNamed := func(it item, key string, isSet *bool, value *string) (err error) {
+		if *isSet {
+			err = &SyntaxError{Tag: tag, Offset: it.Offset, Msg: key + " is set more than once"}
+			return
+		}
+		*isSet = true
+		*value = it.Value
+		return
+	}
+
+	// target and op may be set positionally only by leading items of tag
+	positionalCount := 0
+	for _, it := range items {
+		if it.Sep != 0 {
+			positionalCount = 2
+		}
+
+		switch {
+		case it.Sep == '=':
+			switch it.Key {
+			case TargetKey:
+				err = setNamed(it, TargetKey, &isTargetSet, &res.TargetFieldName)
+			case OpKey:
+				err = setNamed(it, OpKey, &isOpSet, &res.MutationName)
+			default:
+				err = &SyntaxError{Tag: tag, Offset: it.Offset, Msg: "unknown named value " + it.Key}
+			}
+			if err != nil {
+				return
+			}
+		case it.Sep == 0 && positionalCount == 0:
+			positionalCount++
+			if len(it.Value) > 0 {
+				err = setNamed(it, TargetKey, &isTargetSet, &res.TargetFieldName)
+				if err != nil {
+					return
+				}
+			}
+		case it.Sep == 0 && positionalCount == 1:
+			positionalCount++
+			if len(it.Value) > 0 {
+				err = setNamed(it, OpKey, &isOpSet, &res.MutationName)
+				if err != nil {
+					return
+				}
+			}
+		case it.Sep == ':':
+			if len(it.Key) == 0 {
+				err = &SyntaxError{Tag: tag, Offset: it.Offset, Msg: "argument name is empty"}
+				return
+			}
+			res.Args[it.Key] = append(res.Args[it.Key], it.Value)
+		default:
+			// empty items, like in `target,op,,arg`, are ignored
+			if len(it.Value) > 0 {
+				res.Args[it.Value] = append(res.Args[it.Value], "")
+			}
+		}
+	}
+
+	return
+}
+
+// Renders tag, which is parsed into same MutationTag as one given.
+// Args are rendered in order of their keys.
+func FormatMutationTag(tag MutationTag) string {
+	parts := []string{
+		quoteValue(tag.TargetFieldName),
+		quoteValue(tag.MutationName),
+	}
+
+	keys := make([]string, 0, len(tag.Args))
+	for k := range tag.Args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range tag.Args[k] {
+			if len(v) == 0 {
+				parts = append(parts, quoteValue(k))
+			} else {
+				parts = append(parts, quoteValue(k)+":"+quoteValue(v))
+			}
+		}
+	}
+
+	return strings.TrimRight(strings.Join(parts, ","), ",")
+}
+
+// Flags, which are allowed in tag of target field.
+const ReadonlyFlag = "readonly"
+const ImmutableFlag = "immutable"
+
+// Tag of target field, which has form of `mttor:"-,flags..."`.
+// Name may be only empty or "-", since target fields can't be renamed with tags.
+type TargetTag struct {
+	Readonly  bool
+	Immutable bool
+}
+
+// Parses tag of target field.
+// Tags, which are not target tags, like mutation tags on types used both as target and mutation, are ignored.
+// Tags with "-" name are always target tags, so misspelled flags are reported rather than ignored.
+func ParseTargetTag(tag string) (res TargetTag, err error) {
+	items, err := splitItems(tag)
+	if err != nil || !isTargetItems(items) {
+		return
+	}
+
+	if name := items[0]; name.Sep != 0 || len(name.Value) > 0 && name.Value != "-" {
+		err = &SyntaxError{Tag: tag, Offset: name.Offset, Msg: "target fields can't be renamed"}
+		return
+	}
+
+	for _, it := range items[1:] {
+		switch it.Value {
+		case ReadonlyFlag:
+			res.Readonly = true
+		case ImmutableFlag:
+			res.Immutable = true
+		default:
+			err = &SyntaxError{Tag: tag, Offset: it.Offset, Msg: "unknown flag " + it.Value}
+			return
+		}
+	}
+	return
+}
+
+// Returns true, if mttor tag belongs to target field rather than mutation field.
+// Target tags have only flags after name, so tags with any mutation or args are mutation tags,
+// unless name is "-", since skipped mutation fields have no use for them.
+func IsTargetTag(tag string) bool {
+	items, err := splitItems(tag)
+	return err == nil && isTargetItems(items)
+}
+
+func isTargetItems(items []item) bool {
+	if len(items) < 2 {
+		return false
+	}
+
+	isSkipped := items[0].Sep == 0 && items[0].Value == "-"
+	for _, it := range items[1:] {
+		if it.Sep != 0 {
+			return false
+		}
+		if !isSkipped && it.Value != ReadonlyFlag && it.Value != ImmutableFlag {
+			return false
+		}
+	}
+	return true
+}
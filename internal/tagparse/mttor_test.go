@@ -0,0 +1,163 @@
+package tagparse_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/internal/tagparse"
+)
+
+func TestParseMutationTag(t *testing.T) {
+	for _, tc := range []struct {
+		tag      string
+		expected tagparse.MutationTag
+	}{
+		{"", tagparse.MutationTag{Args: map[string][]string{}}},
+		{"-", tagparse.MutationTag{TargetFieldName: "-", Args: map[string][]string{}}},
+		{",inc,omitempty", tagparse.MutationTag{
+			MutationName: "inc",
+			Args:         map[string][]string{"omitempty": {""}},
+		}},
+		{"Ints,push,max:10,max:20", tagparse.MutationTag{
+			TargetFieldName: "Ints",
+			MutationName:    "push",
+			Args:            map[string][]string{"max": {"10", "20"}},
+		}},
+		{`op=inc,omitempty,target=Counter`, tagparse.MutationTag{
+			TargetFieldName: "Counter",
+			MutationName:    "inc",
+			Args:            map[string][]string{"omitempty": {""}},
+		}},
+		{`Name,sep:",",other:a\,b\:c,url:"http://x"`, tagparse.MutationTag{
+			TargetFieldName: "Name",
+			Args: map[string][]string{
+				"sep":   {","},
+				"other": {"a,b:c"},
+				"url":   {"http://x"},
+			},
+		}},
+		{`Name,omitempty`, tagparse.MutationTag{
+			TargetFieldName: "Name",
+			MutationName:    "omitempty",
+			Args:            map[string][]string{},
+		}},
+		{`,,omitempty,,`, tagparse.MutationTag{
+			Args: map[string][]string{"omitempty": {""}},
+		}},
+	} {
+		res, err := tagparse.ParseMutationTag(tc.tag)
+		if err != nil {
+			t.Error(tc.tag, err)
+			return
+		}
+
+		if !reflect.DeepEqual(res, tc.expected) {
+			t.Errorf("tag %q: expected %+#v got %+#v", tc.tag, tc.expected, res)
+			return
+		}
+	}
+}
+
+func TestParseMutationTag_Errors(t *testing.T) {
+	for _, tag := range []string{
+		`Name,target=Other`,
+		`op=set,op=inc`,
+		`,set,op=inc`,
+		`,,:value`,
+		`,,unknown=value`,
+		`"unterminated`,
+		`trailing\`,
+	} {
+		_, err := tagparse.ParseMutationTag(tag)
+
+		var syntaxError *tagparse.SyntaxError
+		if !errors.As(err, &syntaxError) {
+			t.Errorf("tag %q: expected syntax error, got %v", tag, err)
+			return
+		}
+	}
+}
+
+func TestParseTargetTag(t *testing.T) {
+	for _, tc := range []struct {
+		tag      string
+		expected tagparse.TargetTag
+	}{
+		{"", tagparse.TargetTag{}},
+		{"-,readonly", tagparse.TargetTag{Readonly: true}},
+		{",immutable,readonly", tagparse.TargetTag{Readonly: true, Immutable: true}},
+		// mutation tags are left alone
+		{",inc", tagparse.TargetTag{}},
+		{"Owner,set,readonly", tagparse.TargetTag{}},
+		{",readonly,writable", tagparse.TargetTag{}},
+	} {
+		res, err := tagparse.ParseTargetTag(tc.tag)
+		if err != nil {
+			t.Error(tc.tag, err)
+			return
+		}
+
+		if res != tc.expected {
+			t.Errorf("tag %q: expected %+#v got %+#v", tc.tag, tc.expected, res)
+			return
+		}
+	}
+
+	for _, tag := range []string{"owner,immutable", "-,readonyl", `"a`} {
+		_, err := tagparse.ParseTargetTag(tag)
+		var syntaxError *tagparse.SyntaxError
+		if !errors.As(err, &syntaxError) {
+			t.Error("expected syntax error for tag", tag, "got", err)
+			return
+		}
+	}
+}
+
+func FuzzParseMutationTag(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"-",
+		",inc,omitempty",
+		"Ints,push,max:10,max:20",
+		`op=inc,target=Counter`,
+		`Name,sep:",",other:a\,b\:c`,
+		`"a\"b",c`,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, tag string) {
+		res, err := tagparse.ParseMutationTag(tag)
+		if err != nil {
+			return
+		}
+
+		formatted := tagparse.FormatMutationTag(res)
+		reparsed, err := tagparse.ParseMutationTag(formatted)
+		if err != nil {
+			t.Fatalf("formatted tag %q of %q can't be parsed: %s", formatted, tag, err)
+		}
+
+		if !reflect.DeepEqual(res, reparsed) {
+			t.Fatalf("tag %q was formatted as %q, which is parsed differently: %+#v vs %+#v", tag, formatted, res, reparsed)
+		}
+	})
+}
+
+func FuzzParseTargetTag(f *testing.F) {
+	for _, seed := range []string{"", "-,readonly", "name,immutable", `"a,b",readonly`} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, tag string) {
+		res, err := tagparse.ParseTargetTag(tag)
+		if err != nil {
+			return
+		}
+
+		if (res.Readonly || res.Immutable) != tagparse.IsTargetTag(tag) {
+			t.Fatalf("tag %q parsed as %+#v is not recognized as target tag", tag, res)
+		}
+	})
+}
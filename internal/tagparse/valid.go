@@ -0,0 +1,35 @@
+package tagparse
+
+// Single rule of valid tag, which has form of `name` or `name:value`.
+type ValidRule struct {
+	Name  string
+	Value string
+	// True, when rule has value, even empty one.
+	HasValue bool
+}
+
+// Parses valid tag into rules in order of their appearance.
+// Empty items are skipped.
+func ParseValidTag(tag string) (rules []ValidRule, err error) {
+	items, err := splitItems(tag)
+	if err != nil {
+		return
+	}
+
+	for _, it := range items {
+		if it.Sep == 0 {
+			if len(it.Value) == 0 {
+				continue
+			}
+			rules = append(rules, ValidRule{Name: it.Value})
+			continue
+		}
+
+		if len(it.Key) == 0 {
+			err = &SyntaxError{Tag: tag, Offset: it.Offset, Msg: "rule has no name"}
+			return
+		}
+		rules = append(rules, ValidRule{Name: it.Key, Value: it.Value, HasValue: true})
+	}
+	return
+}
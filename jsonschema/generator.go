@@ -0,0 +1,285 @@
+package jsonschema
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/teawithsand/arcah/validation"
+)
+
+// Prefix of references, which point to $defs of root schema.
+const DefaultRefPrefix = "#/$defs/"
+
+// Prefix of references, which point to OpenAPI components.
+const OpenAPIRefPrefix = "#/components/schemas/"
+
+var (
+	timeType             = reflect.TypeOf(time.Time{})
+	jsonMarshalerType    = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType    = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonUnmarshalerType  = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType  = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	byteSliceElementKind = reflect.Uint8
+)
+
+// Generator creates schemas of go types, which describe their JSON encoding.
+//
+// Recursive types are stored in Definitions and referenced with $ref.
+// Definitions are named after types; types with same names, like ones from different packages, get numeric suffixes.
+// Zero value is ready to use.
+type Generator struct {
+	// Prefix of references to definitions, defaults to DefaultRefPrefix.
+	RefPrefix string
+
+	// Definitions of types referenced by generated schemas.
+	Definitions map[string]*Schema
+
+	// Provides rules of valid tags of fields, which are included in schemas.
+	// Defaults to validation.DefaultValidator.
+	Validator *validation.Validator
+
+	inProgress map[reflect.Type]bool
+	recursive  map[reflect.Type]bool
+
+	definitionNames map[reflect.Type]string
+	usedNames       map[string]bool
+}
+
+// Returns name of definition of given type, which is not used by any other type.
+func (g *Generator) definitionName(ty reflect.Type) string {
+	if name, ok := g.definitionNames[ty]; ok {
+		return name
+	}
+
+	if g.definitionNames == nil {
+		g.definitionNames = map[reflect.Type]string{}
+		g.usedNames = map[string]bool{}
+	}
+
+	base := ty.Name()
+	if len(base) == 0 {
+		base = "Anonymous"
+	}
+
+	name := base
+	for i := 2; ; i++ {
+		_, isDefined := g.Definitions[name]
+		if !g.usedNames[name] && !isDefined {
+			break
+		}
+		name = base + strconv.Itoa(i)
+	}
+
+	g.definitionNames[ty] = name
+	g.usedNames[name] = true
+	return name
+}
+
+func (g *Generator) refPrefix() string {
+	if len(g.RefPrefix) == 0 {
+		return DefaultRefPrefix
+	}
+	return g.RefPrefix
+}
+
+// Returns OpenAPI components object with definitions collected by generator.
+func (g *Generator) Components() Components {
+	schemas := map[string]*Schema{}
+	for k, v := range g.Definitions {
+		schemas[k] = v
+	}
+	return Components{Schemas: schemas}
+}
+
+// Returns true, if values of type are encoded with custom JSON or text marshaler.
+func hasCustomEncoding(ty reflect.Type) bool {
+	ptrTy := reflect.PtrTo(ty)
+	for _, iface := range []reflect.Type{jsonMarshalerType, textMarshalerType, jsonUnmarshalerType, textUnmarshalerType} {
+		if ty.Implements(iface) || ptrTy.Implements(iface) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTextType(ty reflect.Type) bool {
+	ptrTy := reflect.PtrTo(ty)
+	return ty.Implements(textMarshalerType) || ptrTy.Implements(textMarshalerType)
+}
+
+func boundsSchema(typeName string, min, max float64) *Schema {
+	return &Schema{Type: typeName, Minimum: &min, Maximum: &max}
+}
+
+// Returns schema of values of given type.
+func (g *Generator) TypeSchema(ty reflect.Type) *Schema {
+	switch {
+	case ty == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case ty.Kind() == reflect.Slice && ty.Elem().Kind() == byteSliceElementKind:
+		return &Schema{Type: "string", Format: "byte"}
+	case ty.Kind() != reflect.Ptr && hasCustomEncoding(ty):
+		if isTextType(ty) {
+			return &Schema{Type: "string"}
+		}
+		// any value
+		return &Schema{}
+	}
+
+	switch ty.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8:
+		return boundsSchema("integer", math.MinInt8, math.MaxInt8)
+	case reflect.Int16:
+		return boundsSchema("integer", math.MinInt16, math.MaxInt16)
+	case reflect.Int32:
+		return boundsSchema("integer", math.MinInt32, math.MaxInt32)
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Uint8:
+		return boundsSchema("integer", 0, math.MaxUint8)
+	case reflect.Uint16:
+		return boundsSchema("integer", 0, math.MaxUint16)
+	case reflect.Uint32:
+		return boundsSchema("integer", 0, math.MaxUint32)
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		min := 0.
+		return &Schema{Type: "integer", Minimum: &min}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice:
+		return &Schema{Type: "array", Items: g.TypeSchema(ty.Elem())}
+	case reflect.Array:
+		n := ty.Len()
+		return &Schema{Type: "array", Items: g.TypeSchema(ty.Elem()), MinItems: &n, MaxItems: &n}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.TypeSchema(ty.Elem())}
+	case reflect.Ptr:
+		res := g.TypeSchema(ty.Elem())
+		if typeName, ok := res.Type.(string); ok {
+			res = res.Clone()
+			res.Type = []string{typeName, "null"}
+		}
+		return res
+	case reflect.Struct:
+		return g.structSchema(ty)
+	}
+
+	// interfaces and types, which can't be encoded
+	return &Schema{}
+}
+
+func (g *Generator) structSchema(ty reflect.Type) *Schema {
+	if g.inProgress[ty] {
+		if g.recursive == nil {
+			g.recursive = map[reflect.Type]bool{}
+		}
+		g.recursive[ty] = true
+		return &Schema{Ref: g.refPrefix() + g.definitionName(ty)}
+	}
+
+	if g.inProgress == nil {
+		g.inProgress = map[reflect.Type]bool{}
+	}
+	g.inProgress[ty] = true
+	defer delete(g.inProgress, ty)
+
+	res := &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{},
+	}
+
+	for _, f := range JSONFields(ty) {
+		fieldSchema := g.TypeSchema(f.Field.Type)
+		if fieldSchema.Ref == "" {
+			fieldSchema = fieldSchema.Clone()
+		}
+
+		// invalid tags are reported, when values are validated
+		rules, _ := g.FieldRules(context.Background(), ty, f.Index)
+		if ApplyRules(fieldSchema, f.Field.Type, rules) {
+			res.Required = append(res.Required, f.Name)
+		}
+		res.Properties[f.Name] = fieldSchema
+	}
+
+	if g.recursive[ty] {
+		if g.Definitions == nil {
+			g.Definitions = map[string]*Schema{}
+		}
+		name := g.definitionName(ty)
+		g.Definitions[name] = res
+		return &Schema{Ref: g.refPrefix() + name}
+	}
+	return res
+}
+
+// JSONField is field of struct, which encoding/json encodes.
+type JSONField struct {
+	Name      string
+	OmitEmpty bool
+	Field     reflect.StructField
+
+	// Index of field in struct, including indices of embedded structures.
+	Index []int
+}
+
+// Returns fields of struct, which encoding/json encodes, including ones of embedded structures.
+func JSONFields(ty reflect.Type) (fields []JSONField) {
+	return jsonFields(ty, nil)
+}
+
+func jsonFields(ty reflect.Type, index []int) (fields []JSONField) {
+	for i := 0; i < ty.NumField(); i++ {
+		sf := ty.Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+
+		if sf.Anonymous && len(name) == 0 {
+			embeddedType := sf.Type
+			for embeddedType.Kind() == reflect.Ptr {
+				embeddedType = embeddedType.Elem()
+			}
+			if embeddedType.Kind() == reflect.Struct {
+				fields = append(fields, jsonFields(embeddedType, fieldIndex)...)
+				continue
+			}
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+
+		if len(name) == 0 {
+			name = sf.Name
+		}
+
+		omitEmpty := false
+		for _, opt := range parts[1:] {
+			omitEmpty = omitEmpty || opt == "omitempty"
+		}
+
+		fields = append(fields, JSONField{
+			Name:      name,
+			OmitEmpty: omitEmpty,
+			Field:     sf,
+			Index:     fieldIndex,
+		})
+	}
+	return
+}
@@ -0,0 +1,141 @@
+package jsonschema_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/teawithsand/arcah/jsonschema"
+	"github.com/teawithsand/arcah/jsonschema/internal/schematest"
+)
+
+type Embedded struct {
+	Extra string `json:"extra"`
+}
+
+type Item struct {
+	Embedded
+
+	Name     string    `json:"name" valid:"required,min:1,max:10"`
+	Count    uint8     `json:"count,omitempty"`
+	Kind     string    `json:"kind" valid:"oneof:a b"`
+	Email    *string   `json:"email" valid:"email"`
+	Created  time.Time `json:"created"`
+	Children []Item    `json:"children"`
+	Skipped  int       `json:"-"`
+	hidden   int
+}
+
+type Address struct {
+	Street string   `json:"street"`
+	Next   *Address `json:"next"`
+}
+
+type Addresses struct {
+	Home Address            `json:"home"`
+	Work schematest.Address `json:"work"`
+}
+
+func marshalToMap(t *testing.T, v interface{}) map[string]interface{} {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res map[string]interface{}
+	err = json.Unmarshal(raw, &res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestGenerator_TypeSchema(t *testing.T) {
+	generator := &jsonschema.Generator{}
+	schema := generator.TypeSchema(reflect.TypeOf(Item{}))
+
+	if schema.Ref != "#/$defs/Item" {
+		t.Error("recursive type isn't referenced", schema.Ref)
+		return
+	}
+
+	def := marshalToMap(t, generator.Definitions["Item"])
+	properties := def["properties"].(map[string]interface{})
+
+	expected := map[string]interface{}{
+		"extra": map[string]interface{}{"type": "string"},
+		"name":  map[string]interface{}{"type": "string", "minLength": 1., "maxLength": 10.},
+		"count": map[string]interface{}{"type": "integer", "minimum": 0., "maximum": 255.},
+		"kind":  map[string]interface{}{"type": "string", "enum": []interface{}{"a", "b"}},
+		"email": map[string]interface{}{
+			"type":   []interface{}{"string", "null"},
+			"format": "email",
+		},
+		"created": map[string]interface{}{"type": "string", "format": "date-time"},
+		"children": map[string]interface{}{
+			"type":  "array",
+			"items": map[string]interface{}{"$ref": "#/$defs/Item"},
+		},
+	}
+	if !reflect.DeepEqual(properties, expected) {
+		t.Error("invalid properties", properties)
+		return
+	}
+
+	if !reflect.DeepEqual(def["required"], []interface{}{"name"}) {
+		t.Error("invalid required fields", def["required"])
+		return
+	}
+}
+
+func TestSchema_MarshalJSON(t *testing.T) {
+	t.Run("extensions", func(t *testing.T) {
+		schema := (&jsonschema.Schema{Type: "integer"}).SetExtension("x-op", "inc")
+
+		res := marshalToMap(t, schema)
+		if !reflect.DeepEqual(res, map[string]interface{}{"type": "integer", "x-op": "inc"}) {
+			t.Error("invalid schema", res)
+			return
+		}
+	})
+
+	t.Run("false", func(t *testing.T) {
+		raw, err := json.Marshal(&jsonschema.Schema{
+			Type:                 "object",
+			AdditionalProperties: jsonschema.False(),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if string(raw) != `{"type":"object","additionalProperties":false}` {
+			t.Error("invalid schema", string(raw))
+			return
+		}
+	})
+}
+
+func TestGenerator_SameTypeNames(t *testing.T) {
+	generator := &jsonschema.Generator{}
+	schema := marshalToMap(t, generator.TypeSchema(reflect.TypeOf(Addresses{})))
+	properties := schema["properties"].(map[string]interface{})
+
+	refs := map[string]string{}
+	for _, name := range []string{"home", "work"} {
+		refs[name] = properties[name].(map[string]interface{})["$ref"].(string)
+	}
+	if refs["home"] != "#/$defs/Address" || refs["work"] != "#/$defs/Address2" {
+		t.Error("invalid references", refs)
+		return
+	}
+
+	for name, field := range map[string]string{"Address": "street", "Address2": "city"} {
+		def := marshalToMap(t, generator.Definitions[name])
+		if _, ok := def["properties"].(map[string]interface{})[field]; !ok {
+			t.Error("definition", name, "is not one of expected type", def)
+			return
+		}
+	}
+}
@@ -0,0 +1,8 @@
+// Package schematest holds types used by tests of jsonschema, which have to be declared in other package.
+package schematest
+
+// Address has same name as type declared by tests of jsonschema.
+type Address struct {
+	City   string   `json:"city"`
+	Parent *Address `json:"parent"`
+}
@@ -0,0 +1,124 @@
+// Package jsonschema generates JSON schemas (draft 2020-12) of go types,
+// which may be also used as OpenAPI 3.1 schema objects.
+package jsonschema
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Schema is JSON schema object.
+// Only keywords used by arcah are supported; others may be set with Extensions.
+type Schema struct {
+	Ref         string `json:"$ref,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// Either single type name or list of them, like ["string", "null"].
+	Type   interface{}   `json:"type,omitempty"`
+	Format string        `json:"format,omitempty"`
+	Enum   []interface{} `json:"enum,omitempty"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+
+	Minimum          *float64 `json:"minimum,omitempty"`
+	Maximum          *float64 `json:"maximum,omitempty"`
+	ExclusiveMinimum *float64 `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum *float64 `json:"exclusiveMaximum,omitempty"`
+	MinLength        *int     `json:"minLength,omitempty"`
+	MaxLength        *int     `json:"maxLength,omitempty"`
+	MinItems         *int     `json:"minItems,omitempty"`
+	MaxItems         *int     `json:"maxItems,omitempty"`
+	Pattern          string   `json:"pattern,omitempty"`
+
+	ReadOnly bool `json:"readOnly,omitempty"`
+
+	// Definitions of schemas referenced with $ref.
+	Defs map[string]*Schema `json:"$defs,omitempty"`
+
+	// Additional keywords, like x-arcah-op, which are written along with ones above.
+	Extensions map[string]interface{} `json:"-"`
+
+	// Set for boolean schemas, which match anything or nothing.
+	boolean *bool
+}
+
+// False is schema, which matches nothing. It's used to disallow additional properties.
+func False() *Schema {
+	value := false
+	return &Schema{boolean: &value}
+}
+
+type plainSchema Schema
+
+func (s *Schema) MarshalJSON() (res []byte, err error) {
+	if s.boolean != nil {
+		return json.Marshal(*s.boolean)
+	}
+
+	res, err = json.Marshal((*plainSchema)(s))
+	if err != nil || len(s.Extensions) == 0 {
+		return
+	}
+
+	fields := map[string]json.RawMessage{}
+	err = json.Unmarshal(res, &fields)
+	if err != nil {
+		return
+	}
+
+	keys := make([]string, 0, len(s.Extensions))
+	for k := range s.Extensions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		var raw []byte
+		raw, err = json.Marshal(s.Extensions[k])
+		if err != nil {
+			return
+		}
+		fields[k] = raw
+	}
+
+	return json.Marshal(fields)
+}
+
+// Sets extension keyword on schema.
+func (s *Schema) SetExtension(key string, value interface{}) *Schema {
+	if s.Extensions == nil {
+		s.Extensions = map[string]interface{}{}
+	}
+	s.Extensions[key] = value
+	return s
+}
+
+// Returns copy of schema, which may be modified without changing original one.
+// Nested schemas are shared.
+func (s *Schema) Clone() *Schema {
+	res := *s
+	if s.Properties != nil {
+		res.Properties = make(map[string]*Schema, len(s.Properties))
+		for k, v := range s.Properties {
+			res.Properties[k] = v
+		}
+	}
+	if s.Extensions != nil {
+		res.Extensions = make(map[string]interface{}, len(s.Extensions))
+		for k, v := range s.Extensions {
+			res.Extensions[k] = v
+		}
+	}
+	res.Required = append([]string(nil), s.Required...)
+	res.Enum = append([]interface{}(nil), s.Enum...)
+	return &res
+}
+
+// OpenAPI components object, which holds schemas referenced by API description.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
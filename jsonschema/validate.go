@@ -0,0 +1,124 @@
+package jsonschema
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+
+	"github.com/teawithsand/arcah/validation"
+)
+
+// Returns rules of field at given path in structure of given type, which are included in its schema.
+// Path is index of field, like one of reflect.StructField.
+func (g *Generator) FieldRules(ctx context.Context, ty reflect.Type, path []int) (rules validation.Rules, err error) {
+	validator := g.Validator
+	if validator == nil {
+		validator = validation.DefaultValidator
+	}
+	return validator.FieldRules(ctx, ty, path)
+}
+
+// Applies rules of field of given type to its schema.
+// Returns true, if field is required.
+func ApplyRules(s *Schema, ty reflect.Type, rules validation.Rules) (required bool) {
+	for ty.Kind() == reflect.Ptr {
+		ty = ty.Elem()
+	}
+
+	switch rules.Format {
+	case validation.FormatEmail:
+		s.Format = "email"
+	case validation.FormatURL:
+		s.Format = "uri"
+	case validation.FormatUUID:
+		s.Format = "uuid"
+	}
+
+	if len(rules.OneOf) > 0 {
+		s.Enum = nil
+		for _, v := range rules.OneOf {
+			s.Enum = append(s.Enum, parseEnumValue(ty, v))
+		}
+	}
+
+	for _, b := range []struct {
+		name  string
+		bound *float64
+	}{
+		{"min", rules.Min},
+		{"max", rules.Max},
+		{"gt", rules.Gt},
+		{"lt", rules.Lt},
+		{"len", rules.Len},
+	} {
+		if b.bound != nil {
+			applyBound(s, ty, b.name, *b.bound)
+		}
+	}
+	return rules.Required
+}
+
+func parseEnumValue(ty reflect.Type, v string) interface{} {
+	switch ty.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return v
+}
+
+// Applies bound rule, which limits length of strings, slices and maps or value of numbers.
+func applyBound(s *Schema, ty reflect.Type, name string, f float64) {
+	n := int(f)
+
+	switch ty.Kind() {
+	case reflect.String:
+		switch name {
+		case "min":
+			s.MinLength = &n
+		case "gt":
+			n++
+			s.MinLength = &n
+		case "max":
+			s.MaxLength = &n
+		case "lt":
+			n--
+			s.MaxLength = &n
+		case "len":
+			s.MinLength, s.MaxLength = &n, &n
+		}
+	case reflect.Slice, reflect.Array:
+		switch name {
+		case "min":
+			s.MinItems = &n
+		case "gt":
+			n++
+			s.MinItems = &n
+		case "max":
+			s.MaxItems = &n
+		case "lt":
+			n--
+			s.MaxItems = &n
+		case "len":
+			s.MinItems, s.MaxItems = &n, &n
+		}
+	case reflect.Map:
+		// object size keywords are not supported
+	default:
+		switch name {
+		case "min":
+			s.Minimum = &f
+		case "gt":
+			s.ExclusiveMinimum = &f
+		case "max":
+			s.Maximum = &f
+		case "lt":
+			s.ExclusiveMaximum = &f
+		case "len":
+			s.Minimum, s.Maximum = &f, &f
+		}
+	}
+}
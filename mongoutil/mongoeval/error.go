@@ -0,0 +1,44 @@
+package mongoeval
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnsupportedOperator = errors.New("arcah/mongoeval: unsupported update operator")
+var ErrConflict = errors.New("arcah/mongoeval: update would create conflict")
+var ErrInvalidUpdate = errors.New("arcah/mongoeval: invalid update document")
+
+// UpdateError is returned, when update can't be applied to document.
+// It corresponds to errors returned by mongo server for same update.
+type UpdateError struct {
+	Operator string
+	Path     string
+	Msg      string
+
+	// Err is one of sentinel errors of this package or nil.
+	Err error
+}
+
+func (err *UpdateError) Error() string {
+	if err == nil {
+		return "<nil>"
+	}
+
+	msg := err.Msg
+	if len(msg) == 0 && err.Err != nil {
+		msg = err.Err.Error()
+	}
+
+	switch {
+	case len(err.Path) > 0:
+		return fmt.Sprintf("arcah/mongoeval: %s of %q failed: %s", err.Operator, err.Path, msg)
+	case len(err.Operator) > 0:
+		return fmt.Sprintf("arcah/mongoeval: %s failed: %s", err.Operator, msg)
+	}
+	return "arcah/mongoeval: " + msg
+}
+
+func (err *UpdateError) Unwrap() error {
+	return err.Err
+}
@@ -0,0 +1,579 @@
+// Package mongoeval applies mongo update documents to documents in memory.
+//
+// It implements subset of update operators used by arcah, so that rendered mutations can be tested without mongo server.
+// Semantics follow ones of mongo server, including errors returned, but only for supported operators.
+package mongoeval
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Converts any value, which can be encoded as BSON document into bson.D,
+// which contains only values that default decoder produces.
+// Nil values are treated as empty documents.
+func toDocument(v interface{}) (doc bson.D, err error) {
+	refV := reflect.ValueOf(v)
+	switch refV.Kind() {
+	case reflect.Invalid:
+		return
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if refV.IsNil() {
+			return
+		}
+	}
+
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	err = bson.Unmarshal(raw, &doc)
+	return
+}
+
+// Applies update document to copy of doc and returns it.
+// Both doc and update may be of any type, which can be encoded as BSON document.
+func ApplyUpdate(doc interface{}, update interface{}) (res bson.D, err error) {
+	res, err = toDocument(doc)
+	if err != nil {
+		return
+	}
+
+	updateDoc, err := toDocument(update)
+	if err != nil {
+		return
+	}
+
+	ops, err := parseUpdate(updateDoc)
+	if err != nil {
+		return
+	}
+
+	for _, op := range ops {
+		// document exists, so there is nothing to insert
+		if op.Operator == "$setOnInsert" {
+			continue
+		}
+
+		var updated interface{}
+		updated, err = op.apply(res)
+		if err != nil {
+			return
+		}
+		res = updated.(bson.D)
+	}
+
+	if res == nil {
+		res = bson.D{}
+	}
+	return
+}
+
+// Applies update document to target, which must be pointer to value encodable as BSON document.
+// Target is encoded, updated and decoded back, so it's what would be read from database after update.
+func ApplyUpdateTo(target interface{}, update interface{}) (err error) {
+	refTarget := reflect.ValueOf(target)
+	if refTarget.Kind() != reflect.Ptr || refTarget.IsNil() {
+		err = &UpdateError{
+			Msg: fmt.Sprintf("target must be non-nil pointer, got %T", target),
+			Err: ErrInvalidUpdate,
+		}
+		return
+	}
+
+	res, err := ApplyUpdate(target, update)
+	if err != nil {
+		return
+	}
+
+	raw, err := bson.Marshal(res)
+	if err != nil {
+		return
+	}
+
+	// fields, which were unset, must be zeroed
+	refTarget.Elem().Set(reflect.Zero(refTarget.Elem().Type()))
+	err = bson.Unmarshal(raw, target)
+	return
+}
+
+// fieldOp is single field of update operator.
+type fieldOp struct {
+	Operator string
+	Path     string
+	Value    interface{}
+}
+
+func (op *fieldOp) errorf(format string, args ...interface{}) error {
+	return &UpdateError{
+		Operator: op.Operator,
+		Path:     op.Path,
+		Msg:      fmt.Sprintf(format, args...),
+	}
+}
+
+// Parses update document into list of operations sorted by path, which is order mongo applies them in.
+func parseUpdate(update bson.D) (ops []fieldOp, err error) {
+	if len(update) == 0 {
+		err = &UpdateError{
+			Msg: "update document must contain at least one operator",
+			Err: ErrInvalidUpdate,
+		}
+		return
+	}
+
+	for _, e := range update {
+		if !strings.HasPrefix(e.Key, "$") {
+			err = &UpdateError{
+				Msg: fmt.Sprintf("replacement documents are not supported, found field %q", e.Key),
+				Err: ErrInvalidUpdate,
+			}
+			return
+		}
+
+		if _, ok := applierMap[e.Key]; !ok {
+			err = &UpdateError{Operator: e.Key, Err: ErrUnsupportedOperator}
+			return
+		}
+
+		fields, ok := e.Value.(bson.D)
+		if !ok {
+			err = &UpdateError{
+				Operator: e.Key,
+				Msg:      fmt.Sprintf("operator value must be document, got %T", e.Value),
+				Err:      ErrInvalidUpdate,
+			}
+			return
+		}
+
+		for _, f := range fields {
+			if len(f.Key) == 0 || strings.HasPrefix(f.Key, ".") || strings.HasSuffix(f.Key, ".") || strings.Contains(f.Key, "..") {
+				err = &UpdateError{Operator: e.Key, Path: f.Key, Msg: "invalid path", Err: ErrInvalidUpdate}
+				return
+			}
+
+			ops = append(ops, fieldOp{
+				Operator: e.Key,
+				Path:     f.Key,
+				Value:    f.Value,
+			})
+		}
+	}
+
+	sort.SliceStable(ops, func(i, j int) bool {
+		return ops[i].Path < ops[j].Path
+	})
+
+	// paths can't be compared only with their neighbours, since characters like '-' sort before '.'
+	paths := map[string]struct{}{}
+	for _, op := range ops {
+		if _, ok := paths[op.Path]; ok {
+			err = &UpdateError{
+				Operator: op.Operator,
+				Path:     op.Path,
+				Msg:      fmt.Sprintf("updating the path %q would create a conflict at %q", op.Path, op.Path),
+				Err:      ErrConflict,
+			}
+			return
+		}
+		paths[op.Path] = struct{}{}
+	}
+
+	for _, op := range ops {
+		for i := range op.Path {
+			if op.Path[i] != '.' {
+				continue
+			}
+
+			prefix := op.Path[:i]
+			if _, ok := paths[prefix]; ok {
+				err = &UpdateError{
+					Operator: op.Operator,
+					Path:     op.Path,
+					Msg:      fmt.Sprintf("updating the path %q would create a conflict at %q", op.Path, prefix),
+					Err:      ErrConflict,
+				}
+				return
+			}
+		}
+	}
+	return
+}
+
+// applier computes new value of field from its current one.
+// When field does not exist, old is nil and exists is false.
+// It returns remove set to true, if field should be removed or left absent.
+type applier func(op *fieldOp, old interface{}, exists bool) (res interface{}, remove bool, err error)
+
+type applierEntry struct {
+	apply applier
+
+	// If true, missing subdocuments on path are created.
+	create bool
+}
+
+var applierMap map[string]applierEntry
+
+func init() {
+	applierMap = map[string]applierEntry{
+		"$set":      {apply: applySet, create: true},
+		"$unset":    {apply: applyUnset},
+		"$inc":      {apply: applyInc, create: true},
+		"$mul":      {apply: applyMul, create: true},
+		"$min":      {apply: applyMinMax, create: true},
+		"$max":      {apply: applyMinMax, create: true},
+		"$push":     {apply: applyPush, create: true},
+		"$addToSet": {apply: applyAddToSet, create: true},
+		"$pop":      {apply: applyPop},
+
+		"$setOnInsert": {apply: applySet, create: true},
+	}
+}
+
+func (op *fieldOp) apply(doc bson.D) (res interface{}, err error) {
+	if op.Path == "_id" || strings.HasPrefix(op.Path, "_id.") {
+		err = op.errorf("field _id is immutable")
+		return
+	}
+
+	entry := applierMap[op.Operator]
+	return op.applyAt(doc, strings.Split(op.Path, "."), entry)
+}
+
+// Applies operation to field at path relative to container, which is bson.D or bson.A.
+// Returns updated container.
+func (op *fieldOp) applyAt(container interface{}, path []string, entry applierEntry) (res interface{}, err error) {
+	key := path[0]
+	isLast := len(path) == 1
+
+	var old interface{}
+	var exists bool
+	index := -1
+
+	switch c := container.(type) {
+	case bson.D:
+		for i, e := range c {
+			if e.Key == key {
+				old, exists, index = e.Value, true, i
+				break
+			}
+		}
+	case bson.A:
+		n, convErr := strconv.Atoi(key)
+		if convErr != nil || n < 0 {
+			if !entry.create {
+				return container, nil
+			}
+			err = op.errorf("cannot use part %q of path to traverse array", key)
+			return
+		}
+		index = n
+		if n < len(c) {
+			old, exists = c[n], true
+		}
+	}
+
+	var value interface{}
+	var remove bool
+	if isLast {
+		value, remove, err = entry.apply(op, old, exists)
+		if err != nil {
+			return
+		}
+	} else {
+		switch old.(type) {
+		case bson.D, bson.A:
+		default:
+			if !entry.create {
+				return container, nil
+			}
+			if exists {
+				err = op.errorf("cannot create field %q in element %v", path[1], old)
+				return
+			}
+			old = bson.D{}
+		}
+
+		value, err = op.applyAt(old, path[1:], entry)
+		if err != nil {
+			return
+		}
+	}
+
+	switch c := container.(type) {
+	case bson.D:
+		switch {
+		case remove && exists:
+			res = append(append(bson.D{}, c[:index]...), c[index+1:]...)
+		case remove:
+			res = c
+		case exists:
+			c = append(bson.D{}, c...)
+			c[index].Value = value
+			res = c
+		default:
+			res = append(append(bson.D{}, c...), bson.E{Key: key, Value: value})
+		}
+	case bson.A:
+		switch {
+		case remove && exists:
+			// unset element of array is set to null, array is not shrunk
+			c = append(bson.A{}, c...)
+			c[index] = nil
+			res = c
+		case remove:
+			res = c
+		default:
+			c = append(bson.A{}, c...)
+			for len(c) <= index {
+				c = append(c, nil)
+			}
+			c[index] = value
+			res = c
+		}
+	}
+	return
+}
+
+func applySet(op *fieldOp, old interface{}, exists bool) (res interface{}, remove bool, err error) {
+	res = op.Value
+	return
+}
+
+func applyUnset(op *fieldOp, old interface{}, exists bool) (res interface{}, remove bool, err error) {
+	remove = true
+	return
+}
+
+func applyArithmetic(op *fieldOp, old interface{}, exists bool, isMul bool) (res interface{}, remove bool, err error) {
+	if !isNumber(op.Value) {
+		err = op.errorf("cannot apply with non-numeric argument %v", op.Value)
+		return
+	}
+
+	if !exists {
+		if isMul {
+			// missing field is treated as zero of type of argument
+			res, _ = arithmetic(op.Value, int32(0), true)
+			return
+		}
+		res = op.Value
+		return
+	}
+
+	if !isNumber(old) {
+		err = op.errorf("cannot apply to value of non-numeric type %T", old)
+		return
+	}
+
+	res, ok := arithmetic(old, op.Value, isMul)
+	if !ok {
+		err = op.errorf("result of %v and %v overflows", old, op.Value)
+		return
+	}
+	return
+}
+
+func applyInc(op *fieldOp, old interface{}, exists bool) (res interface{}, remove bool, err error) {
+	return applyArithmetic(op, old, exists, false)
+}
+
+func applyMul(op *fieldOp, old interface{}, exists bool) (res interface{}, remove bool, err error) {
+	return applyArithmetic(op, old, exists, true)
+}
+
+func applyMinMax(op *fieldOp, old interface{}, exists bool) (res interface{}, remove bool, err error) {
+	if !exists {
+		res = op.Value
+		return
+	}
+
+	cmp, ok := compareValues(op.Value, old)
+	if !ok {
+		err = op.errorf("can't compare %T and %T", op.Value, old)
+		return
+	}
+
+	res = old
+	if (op.Operator == "$min" && cmp < 0) || (op.Operator == "$max" && cmp > 0) {
+		res = op.Value
+	}
+	return
+}
+
+// Returns true, if value is document of modifiers like {$each: [...]}.
+func isModifierDoc(v interface{}) bool {
+	d, ok := v.(bson.D)
+	return ok && len(d) > 0 && strings.HasPrefix(d[0].Key, "$")
+}
+
+type pushModifiers struct {
+	Each     bson.A
+	Position *int
+	Slice    *int
+}
+
+func parsePushModifiers(op *fieldOp) (mods pushModifiers, err error) {
+	if !isModifierDoc(op.Value) {
+		mods.Each = bson.A{op.Value}
+		return
+	}
+
+	hasEach := false
+	for _, e := range op.Value.(bson.D) {
+		switch e.Key {
+		case "$each":
+			each, ok := e.Value.(bson.A)
+			if !ok {
+				err = op.errorf("argument of $each must be array, got %T", e.Value)
+				return
+			}
+			mods.Each = each
+			hasEach = true
+		case "$position", "$slice":
+			if !isNumber(e.Value) {
+				err = op.errorf("argument of %s must be integer, got %T", e.Key, e.Value)
+				return
+			}
+			n := int(toInt64(e.Value))
+			if e.Key == "$position" {
+				mods.Position = &n
+			} else {
+				mods.Slice = &n
+			}
+		default:
+			err = &UpdateError{Operator: op.Operator, Path: op.Path, Msg: "modifier " + e.Key, Err: ErrUnsupportedOperator}
+			return
+		}
+	}
+
+	if !hasEach {
+		err = op.errorf("modifiers require $each")
+		return
+	}
+
+	if op.Operator == "$addToSet" && (mods.Position != nil || mods.Slice != nil) {
+		err = op.errorf("only $each modifier is allowed")
+		return
+	}
+	return
+}
+
+func existingArray(op *fieldOp, old interface{}, exists bool) (arr bson.A, err error) {
+	if !exists {
+		return
+	}
+
+	arr, ok := old.(bson.A)
+	if !ok {
+		err = op.errorf("field must be array, but is of type %T", old)
+		return
+	}
+	return
+}
+
+func applyPush(op *fieldOp, old interface{}, exists bool) (res interface{}, remove bool, err error) {
+	arr, err := existingArray(op, old, exists)
+	if err != nil {
+		return
+	}
+
+	mods, err := parsePushModifiers(op)
+	if err != nil {
+		return
+	}
+
+	position := len(arr)
+	if mods.Position != nil {
+		position = *mods.Position
+		if position < 0 {
+			position += len(arr)
+		}
+		if position < 0 {
+			position = 0
+		}
+		if position > len(arr) {
+			position = len(arr)
+		}
+	}
+
+	newArr := bson.A{}
+	newArr = append(newArr, arr[:position]...)
+	newArr = append(newArr, mods.Each...)
+	newArr = append(newArr, arr[position:]...)
+
+	if mods.Slice != nil {
+		n := *mods.Slice
+		switch {
+		case n >= 0 && n < len(newArr):
+			newArr = newArr[:n]
+		case n < 0 && -n < len(newArr):
+			newArr = newArr[len(newArr)+n:]
+		}
+	}
+
+	res = newArr
+	return
+}
+
+func applyAddToSet(op *fieldOp, old interface{}, exists bool) (res interface{}, remove bool, err error) {
+	arr, err := existingArray(op, old, exists)
+	if err != nil {
+		return
+	}
+
+	mods, err := parsePushModifiers(op)
+	if err != nil {
+		return
+	}
+
+	newArr := append(bson.A{}, arr...)
+	for _, v := range mods.Each {
+		found := false
+		for _, e := range newArr {
+			if valuesEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			newArr = append(newArr, v)
+		}
+	}
+
+	res = newArr
+	return
+}
+
+func applyPop(op *fieldOp, old interface{}, exists bool) (res interface{}, remove bool, err error) {
+	if !isNumber(op.Value) || (toFloat(op.Value) != 1 && toFloat(op.Value) != -1) {
+		err = op.errorf("argument must be 1 or -1, got %v", op.Value)
+		return
+	}
+
+	if !exists {
+		remove = true
+		return
+	}
+
+	arr, err := existingArray(op, old, exists)
+	if err != nil {
+		return
+	}
+
+	switch {
+	case len(arr) == 0:
+		res = arr
+	case toFloat(op.Value) == 1:
+		res = append(bson.A{}, arr[:len(arr)-1]...)
+	default:
+		res = append(bson.A{}, arr[1:]...)
+	}
+	return
+}
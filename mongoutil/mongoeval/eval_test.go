@@ -0,0 +1,193 @@
+package mongoeval_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/mongoutil/mongoeval"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestApplyUpdate(t *testing.T) {
+	for _, tc := range []struct {
+		Name     string
+		Doc      bson.D
+		Update   bson.D
+		Expected bson.D
+	}{
+		{
+			Name:     "set_nested",
+			Doc:      bson.D{{Key: "a", Value: int32(1)}},
+			Update:   bson.D{{Key: "$set", Value: bson.D{{Key: "b.c", Value: "x"}}}},
+			Expected: bson.D{{Key: "a", Value: int32(1)}, {Key: "b", Value: bson.D{{Key: "c", Value: "x"}}}},
+		},
+		{
+			Name:     "unset",
+			Doc:      bson.D{{Key: "a", Value: int32(1)}, {Key: "b", Value: int32(2)}},
+			Update:   bson.D{{Key: "$unset", Value: bson.D{{Key: "a", Value: ""}, {Key: "x.y", Value: ""}}}},
+			Expected: bson.D{{Key: "b", Value: int32(2)}},
+		},
+		{
+			Name: "inc",
+			Doc:  bson.D{{Key: "a", Value: int32(1)}, {Key: "b", Value: int32(2147483647)}},
+			Update: bson.D{{Key: "$inc", Value: bson.D{
+				{Key: "a", Value: 1.5},
+				{Key: "b", Value: int32(1)},
+				{Key: "c", Value: int64(3)},
+			}}},
+			Expected: bson.D{{Key: "a", Value: 2.5}, {Key: "b", Value: int64(2147483648)}, {Key: "c", Value: int64(3)}},
+		},
+		{
+			Name: "min_max_mul",
+			Doc:  bson.D{{Key: "a", Value: int32(5)}, {Key: "b", Value: int32(5)}},
+			Update: bson.D{
+				{Key: "$min", Value: bson.D{{Key: "a", Value: int32(3)}}},
+				{Key: "$max", Value: bson.D{{Key: "b", Value: int32(3)}}},
+				{Key: "$mul", Value: bson.D{{Key: "c", Value: int64(3)}}},
+			},
+			Expected: bson.D{{Key: "a", Value: int32(3)}, {Key: "b", Value: int32(5)}, {Key: "c", Value: int64(0)}},
+		},
+		{
+			Name: "push_each",
+			Doc:  bson.D{{Key: "a", Value: bson.A{int32(1), int32(2)}}},
+			Update: bson.D{{Key: "$push", Value: bson.D{
+				{Key: "a", Value: bson.D{
+					{Key: "$each", Value: bson.A{int32(3), int32(4)}},
+					{Key: "$position", Value: int32(0)},
+					{Key: "$slice", Value: int32(3)},
+				}},
+				{Key: "b", Value: "x"},
+			}}},
+			Expected: bson.D{{Key: "a", Value: bson.A{int32(3), int32(4), int32(1)}}, {Key: "b", Value: bson.A{"x"}}},
+		},
+		{
+			Name:     "add_to_set_pop",
+			Doc:      bson.D{{Key: "a", Value: bson.A{int32(1)}}, {Key: "b", Value: bson.A{int32(1), int32(2)}}},
+			Update:   bson.D{{Key: "$addToSet", Value: bson.D{{Key: "a", Value: bson.D{{Key: "$each", Value: bson.A{int64(1), int32(2)}}}}}}, {Key: "$pop", Value: bson.D{{Key: "b", Value: int32(-1)}}}},
+			Expected: bson.D{{Key: "a", Value: bson.A{int32(1), int32(2)}}, {Key: "b", Value: bson.A{int32(2)}}},
+		},
+		{
+			Name:     "array_index",
+			Doc:      bson.D{{Key: "a", Value: bson.A{int32(1)}}},
+			Update:   bson.D{{Key: "$set", Value: bson.D{{Key: "a.2", Value: int32(3)}}}},
+			Expected: bson.D{{Key: "a", Value: bson.A{int32(1), nil, int32(3)}}},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			res, err := mongoeval.ApplyUpdate(tc.Doc, tc.Update)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if !reflect.DeepEqual(res, tc.Expected) {
+				t.Errorf("expected %v got %v", tc.Expected, res)
+				return
+			}
+		})
+	}
+}
+
+func TestApplyUpdate_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		Name   string
+		Doc    bson.D
+		Update bson.D
+		Err    error
+	}{
+		{
+			Name:   "conflict",
+			Update: bson.D{{Key: "$set", Value: bson.D{{Key: "a", Value: 1}}}, {Key: "$inc", Value: bson.D{{Key: "a.b", Value: 1}}}},
+			Err:    mongoeval.ErrConflict,
+		},
+		{
+			// a-x sorts between a and a.b
+			Name:   "conflict_not_adjacent",
+			Update: bson.D{{Key: "$set", Value: bson.D{{Key: "a", Value: 1}, {Key: "a-x", Value: 1}, {Key: "a.b", Value: 1}}}},
+			Err:    mongoeval.ErrConflict,
+		},
+		{
+			Name:   "conflict_same_path",
+			Update: bson.D{{Key: "$set", Value: bson.D{{Key: "a", Value: 1}}}, {Key: "$inc", Value: bson.D{{Key: "a", Value: 1}}}},
+			Err:    mongoeval.ErrConflict,
+		},
+		{
+			Name:   "unsupported",
+			Update: bson.D{{Key: "$rename", Value: bson.D{{Key: "a", Value: "b"}}}},
+			Err:    mongoeval.ErrUnsupportedOperator,
+		},
+		{
+			Name:   "replacement",
+			Update: bson.D{{Key: "a", Value: 1}},
+			Err:    mongoeval.ErrInvalidUpdate,
+		},
+		{
+			Name:   "inc_string",
+			Doc:    bson.D{{Key: "a", Value: "x"}},
+			Update: bson.D{{Key: "$inc", Value: bson.D{{Key: "a", Value: 1}}}},
+		},
+		{
+			Name:   "inc_overflow",
+			Doc:    bson.D{{Key: "a", Value: int64(9223372036854775807)}},
+			Update: bson.D{{Key: "$inc", Value: bson.D{{Key: "a", Value: 1}}}},
+		},
+		{
+			Name:   "set_in_null",
+			Doc:    bson.D{{Key: "a", Value: nil}},
+			Update: bson.D{{Key: "$set", Value: bson.D{{Key: "a.b", Value: 1}}}},
+		},
+		{
+			Name:   "id",
+			Update: bson.D{{Key: "$set", Value: bson.D{{Key: "_id", Value: 1}}}},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := mongoeval.ApplyUpdate(tc.Doc, tc.Update)
+
+			var updateError *mongoeval.UpdateError
+			if !errors.As(err, &updateError) {
+				t.Error("expected update error, got", err)
+				return
+			}
+
+			if tc.Err != nil && !errors.Is(err, tc.Err) {
+				t.Error("expected", tc.Err, "got", err)
+				return
+			}
+		})
+	}
+}
+
+func TestUpdateError_Error(t *testing.T) {
+	_, err := mongoeval.ApplyUpdate(bson.D{}, bson.D{})
+	if err == nil || err.Error() != "arcah/mongoeval: update document must contain at least one operator" {
+		t.Error("invalid error message", err)
+		return
+	}
+}
+
+func TestApplyUpdateTo(t *testing.T) {
+	type Target struct {
+		Number int64
+		Text   string
+		Ints   []int
+	}
+
+	target := Target{Number: 1, Text: "a", Ints: []int{1}}
+	err := mongoeval.ApplyUpdateTo(&target, bson.D{
+		{Key: "$inc", Value: bson.D{{Key: "number", Value: 2}}},
+		{Key: "$unset", Value: bson.D{{Key: "text", Value: ""}}},
+		{Key: "$push", Value: bson.D{{Key: "ints", Value: 2}}},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := Target{Number: 3, Ints: []int{1, 2}}
+	if !reflect.DeepEqual(target, expected) {
+		t.Error("invalid result", target)
+		return
+	}
+}
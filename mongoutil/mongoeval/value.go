@@ -0,0 +1,188 @@
+package mongoeval
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Returns true, if value is number, which arithmetic operators work with.
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int32, int64, float64:
+		return true
+	}
+	return false
+}
+
+func toFloat(v interface{}) float64 {
+	switch tv := v.(type) {
+	case int32:
+		return float64(tv)
+	case int64:
+		return float64(tv)
+	case float64:
+		return tv
+	}
+	return 0
+}
+
+func toInt64(v interface{}) int64 {
+	switch tv := v.(type) {
+	case int32:
+		return int64(tv)
+	case int64:
+		return tv
+	}
+	return 0
+}
+
+// Performs arithmetic on numbers the way mongo does.
+// Result has widest type of operands; int32 results, which overflow are promoted to int64,
+// while int64 ones, which overflow, are reported as error.
+func arithmetic(lhs, rhs interface{}, isMul bool) (res interface{}, ok bool) {
+	_, lhsFloat := lhs.(float64)
+	_, rhsFloat := rhs.(float64)
+	if lhsFloat || rhsFloat {
+		if isMul {
+			return toFloat(lhs) * toFloat(rhs), true
+		}
+		return toFloat(lhs) + toFloat(rhs), true
+	}
+
+	a, b := toInt64(lhs), toInt64(rhs)
+	var r int64
+	if isMul {
+		r = a * b
+		if a != 0 && (r/a != b || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64)) {
+			return nil, false
+		}
+	} else {
+		r = a + b
+		if (b > 0 && r < a) || (b < 0 && r > a) {
+			return nil, false
+		}
+	}
+
+	_, lhsLong := lhs.(int64)
+	_, rhsLong := rhs.(int64)
+	if !lhsLong && !rhsLong && r >= math.MinInt32 && r <= math.MaxInt32 {
+		return int32(r), true
+	}
+	return r, true
+}
+
+// Returns position of type of value in order, which mongo uses to compare values of different types.
+func typeOrder(v interface{}) int {
+	switch v.(type) {
+	case primitive.MinKey:
+		return 1
+	case nil, primitive.Null, primitive.Undefined:
+		return 2
+	case int32, int64, float64, primitive.Decimal128:
+		return 3
+	case string, primitive.Symbol:
+		return 4
+	case bson.D, bson.M:
+		return 5
+	case bson.A:
+		return 6
+	case primitive.Binary:
+		return 7
+	case primitive.ObjectID:
+		return 8
+	case bool:
+		return 9
+	case primitive.DateTime:
+		return 10
+	case primitive.Timestamp:
+		return 11
+	case primitive.Regex:
+		return 12
+	case primitive.MaxKey:
+		return 14
+	}
+	return 13
+}
+
+// Compares values using mongo ordering.
+// Returns ok set to false, when values can't be compared by this package.
+func compareValues(lhs, rhs interface{}) (res int, ok bool) {
+	lo, ro := typeOrder(lhs), typeOrder(rhs)
+	if lo != ro {
+		if lo < ro {
+			return -1, true
+		}
+		return 1, true
+	}
+
+	switch lv := lhs.(type) {
+	case nil, primitive.Null, primitive.Undefined, primitive.MinKey, primitive.MaxKey:
+		return 0, true
+	case int32, int64, float64:
+		if !isNumber(rhs) {
+			return 0, false
+		}
+		_, lhsFloat := lhs.(float64)
+		_, rhsFloat := rhs.(float64)
+		if lhsFloat || rhsFloat {
+			return compareFloats(toFloat(lhs), toFloat(rhs)), true
+		}
+		return compareInts(toInt64(lhs), toInt64(rhs)), true
+	case string:
+		rv, rOk := rhs.(string)
+		if !rOk {
+			return 0, false
+		}
+		return strings.Compare(lv, rv), true
+	case bool:
+		rv := rhs.(bool)
+		switch {
+		case lv == rv:
+			return 0, true
+		case !lv:
+			return -1, true
+		}
+		return 1, true
+	case primitive.DateTime:
+		return compareInts(int64(lv), int64(rhs.(primitive.DateTime))), true
+	case primitive.ObjectID:
+		rv := rhs.(primitive.ObjectID)
+		return bytes.Compare(lv[:], rv[:]), true
+	}
+	return 0, false
+}
+
+func compareInts(lhs, rhs int64) int {
+	switch {
+	case lhs < rhs:
+		return -1
+	case lhs > rhs:
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(lhs, rhs float64) int {
+	switch {
+	case lhs < rhs:
+		return -1
+	case lhs > rhs:
+		return 1
+	}
+	return 0
+}
+
+// Returns true, if values are equal, as mongo would see them.
+// Numbers of different types are equal, if they have same value.
+func valuesEqual(lhs, rhs interface{}) bool {
+	if isNumber(lhs) && isNumber(rhs) {
+		res, _ := compareValues(lhs, rhs)
+		return res == 0
+	}
+	return reflect.DeepEqual(lhs, rhs)
+}
@@ -1,3 +1,108 @@
 package mongoutil
 
-// TODO(teawithsand): util for parsing mongodb tags here
+import (
+	"reflect"
+	"strings"
+)
+
+const BSONTagName = "bson"
+const JSONTagName = "json"
+
+// Returns name of field, once it's rendered to BSON, when no such name is set by hand.
+func DefaultFieldName(name string) string {
+	return strings.ToLower(name)
+}
+
+// StructTags mirrors bsoncodec.StructTags of mongo driver.
+// It describes how field is encoded to BSON.
+// Unlike driver, it tells if name was set, since named embedded structures are not flattened.
+type StructTags struct {
+	// Name of field in BSON document.
+	// Lowercased field name, when not set in tag.
+	Name string
+	// True, if name was set in tag rather than defaulted.
+	NameSet bool
+
+	OmitEmpty bool
+	MinSize   bool
+	Truncate  bool
+
+	// If true, fields of struct or keys of map are stored as if they were part of outer struct.
+	Inline bool
+
+	// If true, field is not stored in BSON document at all.
+	Skip bool
+}
+
+// StructTagParser returns BSON tags of struct field.
+// It has same semantics as bsoncodec.StructTagParser of mongo driver, but it sets NameSet as well.
+type StructTagParser interface {
+	ParseStructTags(sf reflect.StructField) (tags StructTags, err error)
+}
+
+type StructTagParserFunc func(sf reflect.StructField) (tags StructTags, err error)
+
+func (f StructTagParserFunc) ParseStructTags(sf reflect.StructField) (tags StructTags, err error) {
+	return f(sf)
+}
+
+// Parser, which behaves like bsoncodec.DefaultStructTagParser.
+//
+// It uses bson tag of field. When there is no bson tag and whole tag has no key, like in `Field int "name,omitempty"`,
+// whole tag is used as bson tag.
+var DefaultStructTagParser StructTagParser = StructTagParserFunc(func(sf reflect.StructField) (tags StructTags, err error) {
+	tag, ok := sf.Tag.Lookup(BSONTagName)
+	if !ok && !strings.Contains(string(sf.Tag), ":") && len(sf.Tag) > 0 {
+		tag = string(sf.Tag)
+	}
+
+	return ParseTag(DefaultFieldName(sf.Name), tag)
+})
+
+// Parser, which behaves like bsoncodec.JSONFallbackStructTagParser.
+//
+// It's same as DefaultStructTagParser, but uses json tag, when field has no bson tag.
+var JSONFallbackStructTagParser StructTagParser = StructTagParserFunc(func(sf reflect.StructField) (tags StructTags, err error) {
+	tag, ok := sf.Tag.Lookup(BSONTagName)
+	if !ok {
+		tag, ok = sf.Tag.Lookup(JSONTagName)
+	}
+	if !ok && !strings.Contains(string(sf.Tag), ":") && len(sf.Tag) > 0 {
+		tag = string(sf.Tag)
+	}
+
+	return ParseTag(DefaultFieldName(sf.Name), tag)
+})
+
+// Parses value of bson tag, which has form of "[<key>][,<flag1>[,<flag2>]]".
+// Default name is used, when tag does not set one.
+//
+// Unknown flags are ignored, like mongo driver does.
+func ParseTag(defaultName string, tag string) (tags StructTags, err error) {
+	if tag == "-" {
+		tags.Skip = true
+		return
+	}
+
+	tags.Name = defaultName
+	for i, value := range strings.Split(tag, ",") {
+		if i == 0 && len(value) > 0 {
+			tags.Name = value
+			tags.NameSet = true
+		}
+
+		// note: like in mongo driver, name is matched against flags as well
+		switch value {
+		case "omitempty":
+			tags.OmitEmpty = true
+		case "minsize":
+			tags.MinSize = true
+		case "truncate":
+			tags.Truncate = true
+		case "inline":
+			tags.Inline = true
+		}
+	}
+
+	return
+}
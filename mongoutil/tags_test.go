@@ -0,0 +1,102 @@
+package mongoutil_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/mongoutil"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+)
+
+func TestStructTagParser_DriverParity(t *testing.T) {
+	var fields []reflect.StructField
+	for i, tag := range []string{
+		``,
+		`myb`,
+		`myc,omitempty`,
+		`bson:",omitempty" json:"jsonkey"`,
+		`,minsize`,
+		`myf,omitempty,minsize`,
+		`-`,
+		`bson:"-"`,
+		`bson:"-,omitempty"`,
+		`json:"jsonname,omitempty"`,
+		`json:"-"`,
+		`json:",omitempty"`,
+		`bson:",inline"`,
+		`bson:"l,truncate,unknown"`,
+		`bson:"omitempty"`,
+		`bson:""`,
+		`xml:"a" bson:"o,omitempty"`,
+		`xml:"a"`,
+	} {
+		fields = append(fields, reflect.StructField{
+			Name: "Field" + string(rune('A'+i)),
+			Type: reflect.TypeOf(0),
+			Tag:  reflect.StructTag(tag),
+		})
+	}
+
+	for _, parsers := range []struct {
+		name   string
+		parser mongoutil.StructTagParser
+		driver bsoncodec.StructTagParser
+	}{
+		{"default", mongoutil.DefaultStructTagParser, bsoncodec.DefaultStructTagParser},
+		{"json_fallback", mongoutil.JSONFallbackStructTagParser, bsoncodec.JSONFallbackStructTagParser},
+	} {
+		parsers := parsers
+		t.Run(parsers.name, func(t *testing.T) {
+			for _, sf := range fields {
+				tags, err := parsers.parser.ParseStructTags(sf)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				driverTags, err := parsers.driver.ParseStructTags(sf)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				// driver does not tell if name was set
+				tags.NameSet = false
+				if !reflect.DeepEqual(mongoutil.StructTags{
+					Name:      driverTags.Name,
+					OmitEmpty: driverTags.OmitEmpty,
+					MinSize:   driverTags.MinSize,
+					Truncate:  driverTags.Truncate,
+					Inline:    driverTags.Inline,
+					Skip:      driverTags.Skip,
+				}, tags) {
+					t.Errorf("tag %q: expected %+#v got %+#v", sf.Tag, driverTags, tags)
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestParseTag_NameSet(t *testing.T) {
+	for _, tc := range []struct {
+		tag     string
+		nameSet bool
+	}{
+		{"", false},
+		{",omitempty", false},
+		{"fielda", true},
+		{"fielda,inline", true},
+	} {
+		tags, err := mongoutil.ParseTag("fielda", tc.tag)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if tags.Name != "fielda" || tags.NameSet != tc.nameSet {
+			t.Errorf("tag %q: invalid tags %+#v", tc.tag, tags)
+			return
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package mttor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Engine, which is able to check mutation types against target types without applying them.
+// It's meant to be used in init or tests, so mistakes in mttor tags are found before any request is made.
+type Checker interface {
+	// Checks that every field of mutation resolves to target field, its mutator is registered
+	// and type of mutation field is compatible with target field.
+	Check(targetType, mutationType reflect.Type) (err error)
+
+	// Same as Check, but also requires mutation to be renderable as mongo update or update pipeline.
+	CheckMongo(targetType, mutationType reflect.Type) (err error)
+
+	// Same as Check, but also requires mutation to be renderable as SQL update.
+	CheckSQL(targetType, mutationType reflect.Type) (err error)
+}
+
+// Returned from Checker, when mutation type is not valid for target type.
+type CheckError struct {
+	TargetType   reflect.Type
+	MutationType reflect.Type
+
+	// Descriptions of all problems found, one per field.
+	Problems []string
+}
+
+func (err *CheckError) Error() string {
+	if err == nil {
+		return "<nil>"
+	}
+
+	return fmt.Sprintf("arcah/mttor: mutation %s can't be applied to %s: %s", err.MutationType, err.TargetType, strings.Join(err.Problems, "; "))
+}
+
+func (dm *defaultMutatorEngine) Check(targetType, mutationType reflect.Type) (err error) {
+	return dm.checkTypes(targetType, mutationType, false, false)
+}
+
+func (dm *defaultMutatorEngine) CheckMongo(targetType, mutationType reflect.Type) (err error) {
+	return dm.checkTypes(targetType, mutationType, true, false)
+}
+
+func (dm *defaultMutatorEngine) CheckSQL(targetType, mutationType reflect.Type) (err error) {
+	return dm.checkTypes(targetType, mutationType, false, true)
+}
+
+func (dm *defaultMutatorEngine) checkTypes(targetType, mutationType reflect.Type, requireMongo, requireSQL bool) (err error) {
+	ctx := context.Background()
+	if targetType == nil || mutationType == nil {
+		err = &Error{
+			Descriptorion: "Target type and mutation type have to be non-nil",
+		}
+		return
+	}
+
+	for targetType.Kind() == reflect.Ptr {
+		targetType = targetType.Elem()
+	}
+	for mutationType.Kind() == reflect.Ptr {
+		mutationType = mutationType.Elem()
+	}
+
+	targetDescriptor, err := dm.targetComputer.ComputeDescriptor(ctx, targetType)
+	if err != nil {
+		return
+	}
+
+	mutationDescriptor, err := dm.mutationComputer.ComputeDescriptor(ctx, mutationType)
+	if err != nil {
+		return
+	}
+
+	var problems []string
+	addProblem := func(fieldName string, format string, args ...interface{}) {
+		problems = append(problems, "field "+fieldName+": "+fmt.Sprintf(format, args...))
+	}
+
+	for _, mf := range sortedFields(mutationDescriptor) {
+		meta := mf.Meta.(mutatorMeta)
+
+		tf, ok := targetDescriptor.NameToField[meta.TargetFieldName]
+		if !ok {
+			addProblem(mf.Name, "target field %s does not exist", meta.TargetFieldName)
+			continue
+		}
+		targetMeta := tf.Meta.(mutatorTargetMeta)
+
+		mutator, ok := dm.mutationMap[meta.MutationName]
+		if !ok {
+			addProblem(mf.Name, "mutation %s is not registered", meta.MutationName)
+			continue
+		}
+
+		if flag := readonlyFlag(targetMeta, mutator); len(flag) > 0 {
+			addProblem(mf.Name, "target field %s is %s", meta.TargetFieldName, flag)
+		}
+
+		if typeChecker, ok := mutator.(TypeCheckingMutator); ok {
+			typeErr := typeChecker.CheckTypes(tf.Type, mf.Type)
+			if typeErr != nil {
+				addProblem(mf.Name, "%s", typeErr)
+			}
+		}
+
+		if requireMongo {
+			// pipeline mutators are rendered as stages of update pipeline
+			_, isMongo := mutator.(MongoMutator)
+			_, isPipeline := mutator.(MongoPipelineMutator)
+			if !isMongo && !isPipeline {
+				addProblem(mf.Name, "mutation %s is not mongo mutation", meta.MutationName)
+			}
+			if targetMeta.BSON.Skip {
+				addProblem(mf.Name, "target field %s is not stored in BSON", meta.TargetFieldName)
+			}
+		}
+
+		if requireSQL {
+			if _, ok := mutator.(SQLMutator); !ok {
+				addProblem(mf.Name, "mutation %s is not SQL mutation", meta.MutationName)
+			}
+			if targetMeta.SQLSkip {
+				addProblem(mf.Name, "target field %s is not stored in SQL column", meta.TargetFieldName)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		err = &CheckError{
+			TargetType:   targetType,
+			MutationType: mutationType,
+			Problems:     problems,
+		}
+		return
+	}
+
+	return
+}
@@ -0,0 +1,68 @@
+package mttor_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/mttor"
+)
+
+type DataInvalid struct {
+	Missing string
+	Text    int
+	Number  string `mttor:",inc"`
+	Ints    string `mttor:",push"`
+	Other   int    `mttor:"Number,nosuchmutation"`
+}
+
+func TestChecker(t *testing.T) {
+	checker := mttor.NewDefaultEngine().(mttor.Checker)
+
+	for _, mutation := range []interface{}{
+		DataSetText{},
+		DataIncNumber{},
+		DataCombined{},
+		DataPushInt{},
+		&DataPushInts{},
+	} {
+		err := checker.CheckMongo(reflect.TypeOf(Data{}), reflect.TypeOf(mutation))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	err := checker.Check(reflect.TypeOf(Data{}), reflect.TypeOf(DataInvalid{}))
+
+	var checkError *mttor.CheckError
+	if !errors.As(err, &checkError) {
+		t.Error("expected check error, got", err)
+		return
+	}
+
+	if len(checkError.Problems) != 5 {
+		t.Error("expected problem for each field, got", checkError.Problems)
+		return
+	}
+
+	err = checker.CheckSQL(reflect.TypeOf(Data{}), reflect.TypeOf(DataPushInt{}))
+	if !errors.As(err, &checkError) {
+		t.Error("expected check error, got", err)
+		return
+	}
+
+	// expressions are rendered as update pipelines
+	err = checker.CheckMongo(reflect.TypeOf(Order{}), reflect.TypeOf(OrderRecalculate{}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var mttorError *mttor.Error
+	err = checker.Check(nil, reflect.TypeOf(DataSetText{}))
+	if !errors.As(err, &mttorError) {
+		t.Error("expected mttor error, got", err)
+		return
+	}
+}
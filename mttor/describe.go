@@ -0,0 +1,237 @@
+package mttor
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Engine, which accepts metadata of types registered in code, rather than in struct tags.
+// It's useful for types, which can't be tagged, like ones generated by protobuf.
+type DescribingEngine interface {
+	// Returns description of type of sample, which may be value or pointer to it.
+	// Metadata set in description takes precedence over one from struct tags.
+	//
+	// Descriptions should be registered before engine is used with given type.
+	Describe(sample interface{}) *TypeDescription
+}
+
+// Metadata of single field registered in code.
+// Empty values are not set and values from tags are used instead.
+type fieldDescription struct {
+	// mutation side
+	TargetFieldName string
+	MutationName    string
+	Args            MutationArgs
+	Skip            bool
+
+	// target side
+	TargetName string
+	BSONName   string
+	SQLColumn  string
+	Readonly   bool
+	Immutable  bool
+
+	Roles []string
+}
+
+// descriptions stores metadata registered with Describe.
+type descriptions struct {
+	lock  sync.RWMutex
+	types map[reflect.Type]*TypeDescription
+
+	// called after description changes, so cached descriptors are not used anymore
+	onChange func()
+}
+
+func (ds *descriptions) describe(ty reflect.Type) *TypeDescription {
+	for ty.Kind() == reflect.Ptr {
+		ty = ty.Elem()
+	}
+
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+
+	if ds.types == nil {
+		ds.types = map[reflect.Type]*TypeDescription{}
+	}
+
+	td, ok := ds.types[ty]
+	if !ok {
+		td = &TypeDescription{
+			descriptions: ds,
+			ty:           ty,
+			fields:       map[string]*fieldDescription{},
+		}
+		ds.types[ty] = td
+	}
+	return td
+}
+
+// Returns copy of description of field of struct of given type, if any.
+// Returns error, when description of type refers to fields, which do not exist.
+func (ds *descriptions) lookup(ty reflect.Type, fieldName string) (fd fieldDescription, ok bool, err error) {
+	if ds == nil {
+		return
+	}
+
+	ds.lock.RLock()
+	defer ds.lock.RUnlock()
+
+	td, tdOk := ds.types[ty]
+	if !tdOk {
+		return
+	}
+
+	if len(td.invalidFields) > 0 {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("Description of %s refers to fields, which do not exist: %v", ty, td.invalidFields),
+		}
+		return
+	}
+
+	desc, ok := td.fields[fieldName]
+	if ok {
+		fd = *desc
+	}
+	return
+}
+
+func (ds *descriptions) update(fn func()) {
+	ds.lock.Lock()
+	fn()
+	ds.lock.Unlock()
+
+	if ds.onChange != nil {
+		ds.onChange()
+	}
+}
+
+// TypeDescription holds metadata of fields of single struct type registered in code.
+type TypeDescription struct {
+	descriptions *descriptions
+	ty           reflect.Type
+
+	fields        map[string]*fieldDescription
+	invalidFields []string
+}
+
+// Returns description of field with given go name.
+//
+// Fields, which do not exist in type, are reported as errors, when type is used.
+func (td *TypeDescription) Field(name string) *FieldDescription {
+	td.descriptions.update(func() {
+		if _, ok := td.fields[name]; ok {
+			return
+		}
+
+		sf, ok := td.ty.FieldByName(name)
+		if !ok || len(sf.Index) != 1 {
+			td.invalidFields = append(td.invalidFields, name)
+			sort.Strings(td.invalidFields)
+		}
+		td.fields[name] = &fieldDescription{}
+	})
+
+	return &FieldDescription{
+		typeDescription: td,
+		name:            name,
+	}
+}
+
+// FieldDescription sets metadata of single field.
+// All methods return receiver, so calls may be chained.
+type FieldDescription struct {
+	typeDescription *TypeDescription
+	name            string
+}
+
+func (fd *FieldDescription) set(fn func(desc *fieldDescription)) *FieldDescription {
+	fd.typeDescription.descriptions.update(func() {
+		fn(fd.typeDescription.fields[fd.name])
+	})
+	return fd
+}
+
+// Returns description of another field of same type.
+func (fd *FieldDescription) Field(name string) *FieldDescription {
+	return fd.typeDescription.Field(name)
+}
+
+// Sets name of target field, which this mutation field mutates, like target in mttor tag.
+func (fd *FieldDescription) Target(name string) *FieldDescription {
+	return fd.set(func(desc *fieldDescription) {
+		desc.TargetFieldName = name
+	})
+}
+
+// Sets name of mutation applied with this mutation field, like op in mttor tag.
+func (fd *FieldDescription) Op(name string) *FieldDescription {
+	return fd.set(func(desc *fieldDescription) {
+		desc.MutationName = name
+	})
+}
+
+// Adds argument of mutation, like omitempty.
+func (fd *FieldDescription) Arg(name string, values ...string) *FieldDescription {
+	return fd.set(func(desc *fieldDescription) {
+		if desc.Args == nil {
+			desc.Args = MutationArgs{}
+		}
+		desc.Args[name] = append(desc.Args[name], values...)
+	})
+}
+
+// Makes mutation field ignored, like "-" in mttor tag.
+func (fd *FieldDescription) Skip() *FieldDescription {
+	return fd.set(func(desc *fieldDescription) {
+		desc.Skip = true
+	})
+}
+
+// Sets name, which mutations use to refer to this target field.
+func (fd *FieldDescription) Alias(name string) *FieldDescription {
+	return fd.set(func(desc *fieldDescription) {
+		desc.TargetName = name
+	})
+}
+
+// Sets name of target field in BSON documents, like bson tag.
+func (fd *FieldDescription) BSONName(name string) *FieldDescription {
+	return fd.set(func(desc *fieldDescription) {
+		desc.BSONName = name
+	})
+}
+
+// Sets name of SQL column of target field, like db tag.
+func (fd *FieldDescription) Column(name string) *FieldDescription {
+	return fd.set(func(desc *fieldDescription) {
+		desc.SQLColumn = name
+	})
+}
+
+// Makes target field readonly.
+func (fd *FieldDescription) Readonly() *FieldDescription {
+	return fd.set(func(desc *fieldDescription) {
+		desc.Readonly = true
+	})
+}
+
+// Makes target field immutable.
+func (fd *FieldDescription) Immutable() *FieldDescription {
+	return fd.set(func(desc *fieldDescription) {
+		desc.Immutable = true
+	})
+}
+
+// Sets roles allowed to use this field, like roles tag.
+func (fd *FieldDescription) Roles(roles ...string) *FieldDescription {
+	return fd.set(func(desc *fieldDescription) {
+		desc.Roles = append([]string(nil), roles...)
+	})
+}
+
+func (dm *defaultMutatorEngine) Describe(sample interface{}) *TypeDescription {
+	return dm.descriptions.describe(reflect.TypeOf(sample))
+}
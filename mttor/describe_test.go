@@ -0,0 +1,116 @@
+package mttor_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/mttor"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Types, which can't be tagged, like generated ones.
+type ExternalUser struct {
+	ID       string
+	Username string
+	Visits   int64
+}
+
+type ExternalUserPatch struct {
+	Name   string
+	Visits int64
+	Note   string
+}
+
+func TestMutator_Describe(t *testing.T) {
+	newEngine := func() mttor.Engine {
+		engine := mttor.NewDefaultEngine()
+		describer := engine.(mttor.DescribingEngine)
+
+		describer.Describe(ExternalUser{}).
+			Field("ID").BSONName("_id").Readonly().
+			Field("Username").Alias("name").BSONName("username")
+
+		describer.Describe(&ExternalUserPatch{}).
+			Field("Name").Target("name").Arg("omitempty").
+			Field("Visits").Op("inc").
+			Field("Note").Skip()
+		return engine
+	}
+
+	t.Run("mutate", func(t *testing.T) {
+		engine := newEngine()
+
+		user := ExternalUser{Username: "a", Visits: 1}
+		err := engine.Mutate(context.Background(), &user, ExternalUserPatch{
+			Name:   "b",
+			Visits: 2,
+			Note:   "ignored",
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if user.Username != "b" || user.Visits != 3 {
+			t.Error("invalid mutation result", user)
+			return
+		}
+	})
+
+	t.Run("mongo", func(t *testing.T) {
+		engine := newEngine()
+
+		res, err := engine.(mttor.MongoEngine).RenderMongoMutation(context.Background(), reflect.TypeOf(ExternalUser{}), ExternalUserPatch{
+			Name:   "b",
+			Visits: 2,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := bson.D{
+			{Key: "$set", Value: bson.D{{Key: "username", Value: "b"}}},
+			{Key: "$inc", Value: bson.D{{Key: "visits", Value: int64(2)}}},
+		}
+		if !reflect.DeepEqual(res, expected) {
+			t.Error("invalid rendered mutation", res)
+			return
+		}
+	})
+
+	t.Run("overrides_cached", func(t *testing.T) {
+		engine := newEngine()
+
+		user := ExternalUser{}
+		err := engine.Mutate(context.Background(), &user, ExternalUserPatch{Visits: 1})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		engine.(mttor.DescribingEngine).Describe(ExternalUser{}).Field("Visits").Readonly()
+
+		err = engine.Mutate(context.Background(), &user, ExternalUserPatch{Visits: 1})
+
+		var readonlyError *mttor.ReadonlyFieldError
+		if !errors.As(err, &readonlyError) {
+			t.Error("expected readonly error, got", err)
+			return
+		}
+	})
+
+	t.Run("unknown_field", func(t *testing.T) {
+		engine := newEngine()
+		engine.(mttor.DescribingEngine).Describe(ExternalUser{}).Field("Missing").Readonly()
+
+		user := ExternalUser{}
+		err := engine.Mutate(context.Background(), &user, ExternalUserPatch{Visits: 1})
+		if err == nil {
+			t.Error("expected error")
+			return
+		}
+	})
+}
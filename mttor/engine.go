@@ -3,83 +3,149 @@ package mttor
 import (
 	"context"
 	"reflect"
-	"sync"
+	"sort"
 
-	"github.com/teawithsand/arcah/internal/refutil"
+	"github.com/teawithsand/arcah/mongoutil"
 	"github.com/teawithsand/reval/stdesc"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Engine is component responsible for applying mutations passed into it.
 // Typically, there is single global mutator handling all types.
 type Engine interface {
 	// Applies specified mutation to target provided.
+	// Mutation is either DTO, which describes mutation with its tags, or MapMutation.
+	// Fields of structures embedded with nil pointers can't be mutated, since mongo can't set fields of null subdocument.
+	//
+	// Target may be also document without go structure: bson.M or pointer to bson.M, bson.D or bson.Raw.
+	// Then target field names are keys or dotted paths in document and mutation is applied the way mongo would apply it.
+	// Documents are left intact on error regardless of EngineOptions.Atomic and can't be mutated by engine with TargetValidator.
 	Mutate(ctx context.Context, target, mutation interface{}) (err error)
 }
 
+// Engine, which creates or updates entities, like ingest endpoints do by natural keys.
+// Fields with setOnInsert mutation are set only, when entity is created; they may target immutable fields.
+type UpsertEngine interface {
+	// Applies mutation to target, if entity exists. Otherwise target is reset to zero value first and
+	// fields with setOnInsert mutation are also applied, so target is what upsert would insert.
+	// Mutate ignores setOnInsert fields, like for existing entity.
+	Upsert(ctx context.Context, target, mutation interface{}, exists bool) (err error)
+
+	// Renders mongo update, which creates document, if filter matches none, along with options enabling upsert.
+	// Fields of equality conditions of filter are copied to created document by mongo.
+	RenderMongoUpsert(ctx context.Context, targetType reflect.Type, mutation interface{}) (update interface{}, opts *options.UpdateOptions, err error)
+}
+
 // Mutator, which is able to apply mutations to mongo objects.
 type MongoEngine interface {
 	RenderMongoMutation(ctx context.Context, targetType reflect.Type, mutation interface{}) (res interface{}, err error)
 }
 
+// Mutator, which is able to render mutations as SQL UPDATE statements.
+type SQLEngine interface {
+	// Renders UPDATE statement for table, along with arguments for its placeholders.
+	// Statement has no WHERE clause, caller should append it and number its placeholders starting from len(args)+1.
+	RenderSQLMutation(ctx context.Context, dialect SQLDialect, table string, targetType reflect.Type, mutation interface{}) (query string, args []interface{}, err error)
+}
+
 func NewMongoEngine() (mutator MongoEngine) {
 	return NewDefaultEngine().(MongoEngine)
 }
 
+func NewSQLEngine() (mutator SQLEngine) {
+	return NewDefaultEngine().(SQLEngine)
+}
+
+// Options of engine created with NewEngine.
+type EngineOptions struct {
+	// If true, fields of target, which mutation changes, are restored, when any mutator fails,
+	// so target is left untouched on error.
+	//
+	// Only fields mutation targets are saved and restored, so pointers, slices and maps stored in target keep their identity.
+	Atomic bool
+
+	// If set, validates target after each mutation.
+	// Target is rolled back, when validation fails.
+	// Package validation provides one, which checks rules of valid tags.
+	//
+	// Note: targets implementing BeforeMutateHook, AfterMutateHook or Validator are always rolled back on error.
+	TargetValidator TargetValidator
+
+	// If set, it's asked if each field may be written, before mutation is applied or rendered.
+	// Fields may be restricted to roles using roles tag on target or mutation fields.
+	//
+	// When nil, roles tags are ignored.
+	PermissionChecker PermissionChecker
+
+	// Parser used to obtain BSON names of target fields.
+	// It should match one used by mongo driver, so rendered mutations use same names as encoded documents.
+	//
+	// Defaults to mongoutil.DefaultStructTagParser.
+	BSONTagParser mongoutil.StructTagParser
+
+	// Mutators registered in addition to builtin ones, by names used in mttor tags.
+	// They take precedence over builtin mutators with same names.
+	Mutators map[string]Mutator
+}
+
+func builtinMutators() map[string]Mutator {
+	return map[string]Mutator{
+		"":      &setMutation{}, // default is set
+		"set":   &setMutation{},
+		"inc":   &incMutation{},
+		"push":  &pushMutation{},
+		"unset": &unsetMutation{},
+		"expr":  &exprMutation{},
+
+		"setOnInsert": &setOnInsertMutation{},
+	}
+}
+
+// Returns names of mutations registered in engines by default, including empty one, which is alias of set.
+func BuiltinMutationNames() (names []string) {
+	for name := range builtinMutators() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
 func NewDefaultEngine() (mutator Engine) {
-	mutator = &defaultMutatorEngine{
-		mutationMap: map[string]Mutator{
-			"":     &setMutation{}, // default is set
-			"set":  &setMutation{},
-			"inc":  &incMutation{},
-			"push": &pushMutation{},
-		},
-		targetComputer: &stdesc.Computer{
-			Cache: &sync.Map{},
-			FieldProcessorFactory: stdesc.FieldProcessorFunc(func(pf stdesc.PendingFiled) (options stdesc.FieldOptions, err error) {
-				var meta mutatorTargetMeta
-				err = meta.ParseTag(pf.Field.Tag.Get(refutil.BsonTagName))
-				if err != nil {
-					return
-				}
-
-				hasNameSet := len(meta.BSONFieldName) > 0
-				if !hasNameSet && !meta.Skip {
-					meta.BSONFieldName = refutil.DefaultBsonFieldName(pf.Field.Name)
-				}
-
-				options.Name = pf.Field.Name
-				options.Meta = meta
-
-				options.Embed = (pf.Field.Anonymous && pf.Field.Type.Kind() == reflect.Struct ||
-					(pf.Field.Type.Kind() == reflect.Ptr && pf.Field.Type.Elem().Kind() == reflect.Struct)) &&
-					!hasNameSet && !meta.Skip
-				return
-			}),
+	return NewEngine(EngineOptions{})
+}
+
+func NewEngine(options EngineOptions) (mutator Engine) {
+	if options.BSONTagParser == nil {
+		options.BSONTagParser = mongoutil.DefaultStructTagParser
+	}
+	bsonTagParser := options.BSONTagParser
+
+	mutationMap := builtinMutators()
+	for name, m := range options.Mutators {
+		mutationMap[name] = m
+	}
+
+	ds := &descriptions{}
+	engine := &defaultMutatorEngine{
+		options:      options,
+		mutationMap:  mutationMap,
+		descriptions: ds,
+		targetComputer: &descriptorComputer{
+			computer: &stdesc.Computer{
+				FieldProcessorFactory: newTargetFieldProcessorFactory(bsonTagParser, ds),
+			},
 		},
-		mutationComputer: &stdesc.Computer{
-			FieldProcessorFactory: stdesc.FieldProcessorFunc(func(pf stdesc.PendingFiled) (options stdesc.FieldOptions, err error) {
-				var meta mutatorMeta
-
-				// ignore err from parsing tags for now
-				err = meta.ParseTag(pf.Field.Tag.Get(defaultMutatorTagName))
-				if err != nil {
-					return
-				}
-
-				options.Skip = !pf.Field.IsExported() || meta.TargetFieldName == "-"
-
-				if len(meta.TargetFieldName) == 0 {
-					meta.TargetFieldName = pf.Field.Name
-				}
-
-				options.Name = pf.Field.Name
-				options.Meta = meta
-				options.Embed = (pf.Field.Anonymous && pf.Field.Type.Kind() == reflect.Struct ||
-					(pf.Field.Type.Kind() == reflect.Ptr && pf.Field.Type.Elem().Kind() == reflect.Struct)) && meta.MutationName == ""
-				return
-			}),
-			Cache: &sync.Map{},
+		mutationComputer: &descriptorComputer{
+			computer: &stdesc.Computer{
+				FieldProcessorFactory: newMutationFieldProcessorFactory(ds),
+			},
 		},
 	}
+	ds.onChange = func() {
+		engine.targetComputer.Reset()
+		engine.mutationComputer.Reset()
+	}
+
+	mutator = engine
 	return
 }
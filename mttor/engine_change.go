@@ -0,0 +1,234 @@
+package mttor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/teawithsand/arcah/internal/refutil"
+	"github.com/teawithsand/arcah/mongoutil/mongoeval"
+	"github.com/teawithsand/reval/stdesc"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MongoUpdateDescription is updateDescription of mongo change stream event,
+// which describes fields changed by update.
+type MongoUpdateDescription struct {
+	// Document with dotted paths of changed fields as keys and their new values.
+	UpdatedFields   bson.Raw              `bson:"updatedFields,omitempty"`
+	RemovedFields   []string              `bson:"removedFields,omitempty"`
+	TruncatedArrays []MongoTruncatedArray `bson:"truncatedArrays,omitempty"`
+}
+
+// MongoTruncatedArray is array, which was shortened by update.
+type MongoTruncatedArray struct {
+	Field   string `bson:"field"`
+	NewSize int32  `bson:"newSize"`
+}
+
+// Engine, which applies changes of mongo documents to go structures, which documents are decoded into.
+type ChangeApplyingEngine interface {
+	// Applies change of document to target, which is pointer to structure document was decoded into.
+	//
+	// Paths of changed fields are resolved with BSON names of target fields. Changes of fields,
+	// which target has no field for, are ignored, just like decoding ignores them.
+	// Changes of whole subdocuments of structures, which are flattened into target fields, like embedded ones,
+	// replace or zero these structures.
+	// Arrays are truncated first, then fields are removed and finally updated fields are set.
+	// Target is left intact, if change can't be applied.
+	ApplyMongoChange(ctx context.Context, target interface{}, change MongoUpdateDescription) (err error)
+}
+
+// Field of target and remaining part of BSON path, when path points inside that field.
+type changedField struct {
+	// Index of field, like one of reflect.StructField.
+	Path []int
+	Rest string
+}
+
+// Resolves dotted BSON path into field of target, which path points to or into.
+//
+// Structures, which are flattened into target fields, like embedded ones, have no fields in descriptor,
+// so path, which points to whole subdocument of such structure, is resolved into its go field.
+func (dm *defaultMutatorEngine) resolveBSONPath(desc stdesc.Descriptor, rootType reflect.Type, path string) (res changedField, ok bool, err error) {
+	bestLen := -1
+	isParent := false
+	for _, f := range desc.NameToField {
+		meta := f.Meta.(mutatorTargetMeta)
+		if meta.BSON.Skip {
+			continue
+		}
+
+		fieldPath := meta.BSONPath
+		if strings.HasPrefix(fieldPath, path+".") {
+			isParent = true
+		}
+		if path != fieldPath && !strings.HasPrefix(path, fieldPath+".") {
+			continue
+		}
+
+		if len(fieldPath) > bestLen {
+			bestLen = len(fieldPath)
+			res = changedField{
+				Path: f.Path,
+				Rest: strings.TrimPrefix(strings.TrimPrefix(path, fieldPath), "."),
+			}
+			ok = true
+		}
+	}
+	if ok || !isParent {
+		return
+	}
+
+	for _, f := range desc.NameToField {
+		meta := f.Meta.(mutatorTargetMeta)
+		if meta.BSON.Skip || !strings.HasPrefix(meta.BSONPath, path+".") {
+			continue
+		}
+
+		// outermost structure stored at path holds whole subdocument
+		for i := 1; i < len(f.Path); i++ {
+			var prefix string
+			prefix, err = bsonPathPrefix(dm.options.BSONTagParser, dm.descriptions, rootType, f.Path[:i+1])
+			if err != nil {
+				return
+			}
+
+			if prefix == path+"." {
+				res = changedField{Path: f.Path[:i]}
+				ok = true
+				return
+			}
+		}
+	}
+
+	err = &Error{
+		Descriptorion: fmt.Sprintf("Path %s points to subdocument, which has no field in %s", path, rootType),
+	}
+	return
+}
+
+// Applies mongo update operator to value of field, which path points inside of.
+// Value is wrapped in document, updated with mongoeval and decoded back.
+func updateNested(value reflect.Value, operator, rest string, arg interface{}) (res reflect.Value, err error) {
+	const key = "v"
+
+	doc := bson.D{{Key: key, Value: value.Interface()}}
+	updated, err := mongoeval.ApplyUpdate(doc, bson.D{
+		{Key: operator, Value: bson.D{{Key: key + "." + rest, Value: arg}}},
+	})
+	if err != nil {
+		return
+	}
+
+	raw, err := bson.Marshal(updated)
+	if err != nil {
+		return
+	}
+
+	res = reflect.New(value.Type())
+	rawValue, err := bson.Raw(raw).LookupErr(key)
+	if err != nil {
+		err = nil
+		res = res.Elem()
+		return
+	}
+
+	err = rawValue.Unmarshal(res.Interface())
+	res = res.Elem()
+	return
+}
+
+func (dm *defaultMutatorEngine) ApplyMongoChange(ctx context.Context, target interface{}, change MongoUpdateDescription) (err error) {
+	refTarget := reflect.ValueOf(target)
+	if refTarget.Kind() != reflect.Ptr || refTarget.IsNil() {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("Target of change must be non-nil pointer, got %T", target),
+		}
+		return
+	}
+
+	desc, err := dm.targetComputer.ComputeDescriptor(ctx, refTarget.Type())
+	if err != nil {
+		return
+	}
+
+	staged := reflect.New(refTarget.Type().Elem())
+	staged.Elem().Set(refutil.CloneValue(refTarget.Elem()))
+
+	// changes fields, which path points to or into, with function given
+	applyChange := func(path string, setField func(value reflect.Value) (reflect.Value, error), operator string, arg interface{}) (err error) {
+		cf, ok, err := dm.resolveBSONPath(desc, refTarget.Type().Elem(), path)
+		if err != nil || !ok {
+			return
+		}
+
+		refutil.AllocFieldPath(staged, cf.Path)
+		value := staged.Elem().FieldByIndex(cf.Path)
+
+		var newValue reflect.Value
+		if len(cf.Rest) == 0 {
+			newValue, err = setField(value)
+		} else {
+			newValue, err = updateNested(value, operator, cf.Rest, arg)
+		}
+		if err != nil {
+			err = &Error{
+				Descriptorion: fmt.Sprintf("Can't apply change of field %s: %s", path, err),
+			}
+			return
+		}
+
+		value.Set(newValue)
+		return
+	}
+
+	for _, ta := range change.TruncatedArrays {
+		newSize := int(ta.NewSize)
+		err = applyChange(ta.Field, func(value reflect.Value) (res reflect.Value, err error) {
+			if value.Kind() != reflect.Slice || newSize < 0 || newSize > value.Len() {
+				err = fmt.Errorf("can't truncate %s to %d elements", value.Type(), newSize)
+				return
+			}
+			res = value.Slice(0, newSize)
+			return
+		}, "$push", bson.D{{Key: "$each", Value: bson.A{}}, {Key: "$slice", Value: newSize}})
+		if err != nil {
+			return
+		}
+	}
+
+	for _, path := range change.RemovedFields {
+		err = applyChange(path, func(value reflect.Value) (res reflect.Value, err error) {
+			return reflect.Zero(value.Type()), nil
+		}, "$unset", "")
+		if err != nil {
+			return
+		}
+	}
+
+	if len(change.UpdatedFields) > 0 {
+		var elements []bson.RawElement
+		elements, err = change.UpdatedFields.Elements()
+		if err != nil {
+			return
+		}
+
+		for _, e := range elements {
+			rawValue := e.Value()
+			err = applyChange(e.Key(), func(value reflect.Value) (res reflect.Value, err error) {
+				res = reflect.New(value.Type())
+				err = rawValue.Unmarshal(res.Interface())
+				res = res.Elem()
+				return
+			}, "$set", rawValue)
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	refTarget.Elem().Set(staged.Elem())
+	return
+}
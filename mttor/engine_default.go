@@ -6,164 +6,283 @@ import (
 	"reflect"
 
 	"github.com/teawithsand/arcah/internal/refutil"
-	"github.com/teawithsand/reval/stdesc"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Default implementation of Mutator, suitable for common tasks.
 // It supports some most common tasks.
 // It's also MongoMutator, with support for all mutations, which are MongoMutations.
+// It's also DescribingEngine, SchemaEngine, ExplainingEngine, RecordingEngine, ChangeApplyingEngine and UpsertEngine.
 type defaultMutatorEngine struct {
+	options     EngineOptions
 	mutationMap map[string]Mutator
 
-	targetComputer   *stdesc.Computer
-	mutationComputer *stdesc.Computer
+	descriptions *descriptions
+
+	targetComputer   *descriptorComputer
+	mutationComputer *descriptorComputer
 }
 
 func (dm *defaultMutatorEngine) Mutate(ctx context.Context, target, mutation interface{}) (err error) {
+	return dm.mutate(ctx, target, mutation, false)
+}
+
+func (dm *defaultMutatorEngine) Upsert(ctx context.Context, target, mutation interface{}, exists bool) (err error) {
+	return dm.mutate(ctx, target, mutation, !exists)
+}
+
+// Returns operations without ones, which are applied only when entity is created.
+func withoutInsertOnly(ops []operation) (res []operation) {
+	res = make([]operation, 0, len(ops))
+	for _, op := range ops {
+		if !isInsertOnly(op.Mutator) {
+			res = append(res, op)
+		}
+	}
+	return
+}
+
+// Applies mutation to target. If inserting, target is created from zero value.
+func (dm *defaultMutatorEngine) mutate(ctx context.Context, target, mutation interface{}, inserting bool) (err error) {
+	if isDocumentType(reflect.TypeOf(target)) {
+		return dm.mutateDocument(ctx, target, mutation, inserting)
+	}
+
 	refTarget := reflect.ValueOf(target)
-	refMutation := reflect.ValueOf(mutation)
 
-	targetDescriptor, err := dm.targetComputer.ComputeDescriptor(ctx, reflect.TypeOf(target))
+	ops, err := dm.planMutation(ctx, reflect.TypeOf(target), mutation)
 	if err != nil {
 		return
 	}
 
-	mutationDescriptor, err := dm.mutationComputer.ComputeDescriptor(ctx, reflect.TypeOf(mutation))
-	if err != nil {
+	if !inserting {
+		ops = withoutInsertOnly(ops)
+	} else if refTarget.Kind() != reflect.Ptr || refTarget.IsNil() {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("Upsert requires non-nil pointer target, got %T", target),
+		}
 		return
 	}
 
-	for _, mf := range mutationDescriptor.NameToField {
-		meta := mf.Meta.(mutatorMeta)
-		// meta.TargetFieldName
-		tf, ok := targetDescriptor.NameToField[meta.TargetFieldName]
-		if !ok {
-			err = &Error{
-				Descriptorion: fmt.Sprintf("Field %s is not available in target of type %s", meta.TargetFieldName, refTarget.Type()),
-			}
-			return
+	// mutation has to be staged, when target is validated, so it can be rolled back
+	isStaged := dm.options.Atomic || dm.options.TargetValidator != nil || hasMutationHooks(target)
+	if inserting {
+		// target is created as separate value, so it's left intact, when mutation fails
+		staged := reflect.New(refTarget.Type().Elem())
+		allocFieldPaths(staged, ops)
+		if isStaged {
+			err = dm.runMutation(ctx, staged, ops)
+		} else {
+			err = dm.applyOperations(ctx, staged, ops)
 		}
-
-		mutation, ok := dm.mutationMap[meta.MutationName]
-		if !ok {
-			err = &Error{
-				Descriptorion: fmt.Sprintf("Mutation %s is not registered", meta.MutationName),
-			}
+		if err != nil {
 			return
 		}
 
-		mutationFieldRefValue := mf.MustGet(refMutation)
+		refTarget.Elem().Set(staged.Elem())
+		return
+	}
 
-		if meta.TargetMutationArgs.IsSet("omitempty") {
-			if refutil.ValueIsEmpty(mutationFieldRefValue) {
-				continue
-			}
+	if !isStaged {
+		err = dm.applyOperations(ctx, refTarget, ops)
+		return
+	}
+
+	if refTarget.Kind() != reflect.Ptr || refTarget.IsNil() {
+		reason := "Atomic mutation"
+		if hasMutationHooks(target) {
+			reason = "Target implementing hooks"
+		} else if dm.options.TargetValidator != nil {
+			reason = "Validated mutation"
 		}
 
-		err = mutation.ApplyMutation(ctx, refTarget, tf, MutatorData{
-			Value:        mutationFieldRefValue.Interface(),
-			Args:         meta.TargetMutationArgs,
-			FieldName:    meta.TargetFieldName,
-			MutationName: meta.MutationName,
-		})
-		if err != nil {
-			return
+		err = &Error{
+			Descriptorion: fmt.Sprintf("%s has to be staged, so it requires non-nil pointer target, got %s", reason, refTarget.Type()),
 		}
+		return
 	}
 
+	saved := saveFields(refTarget, ops, hasMutationHooks(target))
+	err = dm.runMutation(ctx, refTarget, ops)
+	if err != nil {
+		restoreFields(saved)
+	}
 	return
 }
 
-func (dm *defaultMutatorEngine) RenderMongoMutation(ctx context.Context, targetType reflect.Type, mutation interface{}) (res interface{}, err error) {
-	refMutation := reflect.ValueOf(mutation)
+// Allocates structures, which fields operations change are embedded in, like mongo creates subdocuments of inserted document.
+func allocFieldPaths(target reflect.Value, ops []operation) {
+	for _, op := range ops {
+		refutil.AllocFieldPath(target, op.TargetField.Path)
+	}
+}
 
-	targetDescriptor, err := dm.targetComputer.ComputeDescriptor(ctx, targetType)
-	if err != nil {
-		return
+// savedField is value of field of target, which is restored, when mutation fails.
+type savedField struct {
+	field reflect.Value
+	value reflect.Value
+}
+
+// Saves fields of target, which operations change, or nil pointers on their paths, if fields can't be reached.
+// If whole is true, structure of target is saved as well, so changes made by hooks to its fields are rolled back.
+//
+// Values are copied shallowly, so pointers, slices and maps held by target keep their identity.
+func saveFields(target reflect.Value, ops []operation, whole bool) (saved []savedField) {
+	for target.Kind() == reflect.Ptr {
+		target = target.Elem()
 	}
 
-	mutationDescriptor, err := dm.mutationComputer.ComputeDescriptor(ctx, reflect.TypeOf(mutation))
-	if err != nil {
-		return
+	save := func(field reflect.Value) {
+		value := reflect.New(field.Type()).Elem()
+		value.Set(field)
+		saved = append(saved, savedField{field: field, value: value})
 	}
 
-	mutationRegistry := map[string]bson.D{}
+	if whole {
+		save(target)
+	}
 
-	for _, mf := range mutationDescriptor.NameToField {
-		meta := mf.Meta.(mutatorMeta)
+	for _, op := range ops {
+		v := target
+		for i, index := range op.TargetField.Path {
+			v = v.Field(index)
+			if i == len(op.TargetField.Path)-1 || v.Kind() == reflect.Ptr && v.IsNil() {
+				save(v)
+				break
+			}
 
-		mutation, ok := dm.mutationMap[meta.MutationName]
-		if !ok {
-			err = &Error{
-				Descriptorion: fmt.Sprintf("Mutation %s is not registered", meta.MutationName),
+			if v.Kind() == reflect.Ptr {
+				v = v.Elem()
 			}
-			return
 		}
+	}
+	return
+}
 
-		tf, ok := targetDescriptor.NameToField[meta.TargetFieldName]
-		if !ok {
+// Restores fields in reverse order they were saved in.
+func restoreFields(saved []savedField) {
+	for i := len(saved) - 1; i >= 0; i-- {
+		saved[i].field.Set(saved[i].value)
+	}
+}
+
+func (dm *defaultMutatorEngine) applyOperations(ctx context.Context, target reflect.Value, ops []operation) (err error) {
+	for _, op := range ops {
+		// mongo can't set fields of subdocument, which is null, so such fields can't be set here either
+		if _, ok := refutil.FieldByPath(target, op.TargetField.Path); !ok {
 			err = &Error{
-				Descriptorion: fmt.Sprintf("Field %s is not available in target of type %s", meta.TargetFieldName, targetType),
+				Descriptorion: fmt.Sprintf("Field %s can't be mutated, since structure it's embedded in is nil", op.TargetField.Name),
 			}
 			return
 		}
 
-		mongoMutation, ok := mutation.(MongoMutator)
-		if !ok {
-			err = &Error{
-				Descriptorion: fmt.Sprintf("Registered mutation %s is not mongo mutation", meta.MutationName),
-			}
+		err = op.Mutator.ApplyMutation(ctx, target, op.TargetField, op.Data)
+		if err != nil {
 			return
 		}
+	}
+	return
+}
+
+func (dm *defaultMutatorEngine) RenderMongoMutation(ctx context.Context, targetType reflect.Type, mutation interface{}) (res interface{}, err error) {
+	// rendering doesn't need target, so documents held by value are fine
+	if isDocumentType(targetType) || (targetType != nil && isDocumentType(reflect.PtrTo(targetType))) {
+		return dm.renderDocumentMutation(ctx, targetType, mutation)
+	}
 
-		mfd := tf.Meta.(mutatorTargetMeta)
+	ops, err := dm.planMutation(ctx, targetType, mutation)
+	if err != nil {
+		return
+	}
 
-		if mfd.Skip {
+	for _, op := range ops {
+		if _, ok := op.Mutator.(MongoMutator); ok {
 			continue
 		}
+		if _, ok := op.Mutator.(MongoPipelineMutator); ok {
+			return dm.renderMongoPipeline(ctx, ops)
+		}
+	}
 
-		mutationFieldRefValue := mf.MustGet(refMutation)
+	var ub mongoUpdateBuilder
 
-		if meta.TargetMutationArgs.IsSet("omitempty") {
-			if refutil.ValueIsEmpty(mutationFieldRefValue) {
-				continue
+	for _, op := range ops {
+		mongoMutation, ok := op.Mutator.(MongoMutator)
+		if !ok {
+			err = &Error{
+				Descriptorion: fmt.Sprintf("Registered mutation %s is not mongo mutation", op.Data.MutationName),
 			}
+			return
 		}
 
-		mutationName := mongoMutation.MongoMutationName()
-		_, ok = mutationRegistry[mutationName]
-		if !ok {
-			mutationRegistry[mutationName] = bson.D{}
+		if op.TargetMeta.BSON.Skip {
+			continue
 		}
 
 		var entry bson.E
 		entry, err = mongoMutation.RenderMongoDoc(ctx, MongoMutatorData{
-			MutatorData: MutatorData{
-				Value:        mutationFieldRefValue.Interface(),
-				Args:         meta.TargetMutationArgs,
-				FieldName:    meta.TargetFieldName,
-				MutationName: meta.MutationName,
-			},
-			BSONFieldName: mfd.BSONFieldName,
+			MutatorData:   op.Data,
+			BSONFieldName: op.TargetMeta.BSONPath,
 		})
-
 		if err != nil {
 			return
 		}
 
-		mutationRegistry[mutationName] = append(mutationRegistry[mutationName], entry)
+		ub.add(mongoMutation.MongoMutationName(), entry)
 	}
 
-	innerRes := bson.D{}
+	res = ub.result()
+	return
+}
+
+// Renders operations as mongo update pipeline, with $set stage for each of them.
+func (dm *defaultMutatorEngine) renderMongoPipeline(ctx context.Context, ops []operation) (res interface{}, err error) {
+	stages := bson.A{}
+	for _, op := range ops {
+		pipelineMutator, ok := op.Mutator.(MongoPipelineMutator)
+		if !ok || isInsertOnly(op.Mutator) {
+			err = &Error{
+				Descriptorion: fmt.Sprintf("Registered mutation %s can't be rendered as stage of update pipeline", op.Data.MutationName),
+			}
+			return
+		}
 
-	for k, v := range mutationRegistry {
-		innerRes = append(innerRes, bson.E{
-			Key:   k,
-			Value: v,
+		if op.TargetMeta.BSON.Skip {
+			continue
+		}
+
+		var expr interface{}
+		expr, err = pipelineMutator.RenderMongoPipelineField(ctx, MongoMutatorData{
+			MutatorData:   op.Data,
+			BSONFieldName: op.TargetMeta.BSONPath,
 		})
+		if err != nil {
+			return
+		}
+
+		stages = append(stages, bson.D{
+			{Key: "$set", Value: bson.D{{Key: op.TargetMeta.BSONPath, Value: expr}}},
+		})
+	}
+
+	res = stages
+	return
+}
+
+func (dm *defaultMutatorEngine) RenderMongoUpsert(ctx context.Context, targetType reflect.Type, mutation interface{}) (update interface{}, opts *options.UpdateOptions, err error) {
+	update, err = dm.RenderMongoMutation(ctx, targetType, mutation)
+	if err != nil {
+		return
+	}
+
+	// mongo rejects empty updates, even if they would insert document
+	if d, ok := update.(bson.D); ok && len(d) == 0 {
+		update = nil
+		err = ErrEmptyMutation
+		return
 	}
 
-	res = innerRes
+	opts = options.Update().SetUpsert(true)
 	return
 }
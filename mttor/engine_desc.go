@@ -0,0 +1,230 @@
+package mttor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/teawithsand/arcah/internal/refutil"
+	"github.com/teawithsand/arcah/mongoutil"
+	"github.com/teawithsand/reval/stdesc"
+)
+
+// descriptorComputer computes descriptors and caches them by type they were computed for.
+//
+// Cache of stdesc.Computer is not used, since it stores descriptors of embedded structures under their own types,
+// which breaks descriptors of types embedding them.
+// Also, metadata of fields of embedded structures depends on type they are embedded in.
+type descriptorComputer struct {
+	computer *stdesc.Computer
+	cache    sync.Map
+}
+
+func (dc *descriptorComputer) ComputeDescriptor(ctx context.Context, ty reflect.Type) (desc stdesc.Descriptor, err error) {
+	for ty.Kind() == reflect.Ptr {
+		ty = ty.Elem()
+	}
+
+	cached, ok := dc.cache.Load(ty)
+	if ok {
+		desc = cached.(stdesc.Descriptor)
+		return
+	}
+
+	if ty.Kind() != reflect.Struct {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("Type %s is not struct", ty),
+		}
+		return
+	}
+
+	desc, err = dc.computer.ComputeDescriptor(ctx, ty)
+	if err != nil {
+		return
+	}
+
+	// stdesc does not skip unexported fields, even if it's asked to
+	for name, f := range desc.NameToField {
+		if !ty.FieldByIndex(f.Path).IsExported() {
+			delete(desc.NameToField, name)
+		}
+	}
+
+	dc.cache.Store(ty, desc)
+	return
+}
+
+// Drops all cached descriptors.
+func (dc *descriptorComputer) Reset() {
+	dc.cache.Range(func(key, value interface{}) bool {
+		dc.cache.Delete(key)
+		return true
+	})
+}
+
+// Returns type of struct, which directly contains field with specified path.
+func fieldOwnerType(rootType reflect.Type, path []int) reflect.Type {
+	ty := rootType
+	for _, i := range path[:len(path)-1] {
+		for ty.Kind() == reflect.Ptr {
+			ty = ty.Elem()
+		}
+		ty = ty.Field(i).Type
+	}
+
+	for ty.Kind() == reflect.Ptr {
+		ty = ty.Elem()
+	}
+	return ty
+}
+
+// Parses BSON tags of field of owner type, taking BSON name registered with Describe into account.
+func parseBSONTags(
+	bsonTagParser mongoutil.StructTagParser,
+	ds *descriptions,
+	owner reflect.Type,
+	sf reflect.StructField,
+) (tags mongoutil.StructTags, err error) {
+	tags, err = bsonTagParser.ParseStructTags(sf)
+	if err != nil {
+		return
+	}
+
+	fd, ok, err := ds.lookup(owner, sf.Name)
+	if err != nil || !ok {
+		return
+	}
+
+	if len(fd.BSONName) > 0 {
+		tags.Name = fd.BSONName
+	}
+	return
+}
+
+// Returns true, if field is struct or pointer to struct, so it may be embedded.
+func isStructField(sf reflect.StructField) bool {
+	return sf.Type.Kind() == reflect.Struct ||
+		(sf.Type.Kind() == reflect.Ptr && sf.Type.Elem().Kind() == reflect.Struct)
+}
+
+// Returns BSON path of structure, which contains field with specified path, including trailing dot.
+// Structures, which are not inlined, are stored as subdocuments, so their names are part of path.
+func bsonPathPrefix(
+	bsonTagParser mongoutil.StructTagParser,
+	ds *descriptions,
+	rootType reflect.Type,
+	path []int,
+) (prefix string, err error) {
+	ty := rootType
+	for _, i := range path[:len(path)-1] {
+		for ty.Kind() == reflect.Ptr {
+			ty = ty.Elem()
+		}
+
+		sf := ty.Field(i)
+
+		var tags mongoutil.StructTags
+		tags, err = parseBSONTags(bsonTagParser, ds, ty, sf)
+		if err != nil {
+			return
+		}
+
+		if !tags.Inline {
+			prefix += tags.Name + "."
+		}
+
+		ty = sf.Type
+	}
+	return
+}
+
+func newTargetFieldProcessorFactory(bsonTagParser mongoutil.StructTagParser, ds *descriptions) stdesc.FieldProcessorFactory {
+	return stdesc.FieldProcessorFactoryFunc(func(ctx context.Context, rootType reflect.Type) (fp stdesc.FieldProcessor, err error) {
+		fp = stdesc.FieldProcessorFunc(func(pf stdesc.PendingFiled) (options stdesc.FieldOptions, err error) {
+			var meta mutatorTargetMeta
+			err = meta.ParseField(bsonTagParser, pf.Field)
+			if err != nil {
+				err = &Error{
+					Descriptorion: fmt.Sprintf("Invalid tags of field %s in %s: %s", pf.Field.Name, rootType, err),
+				}
+				return
+			}
+
+			fd, ok, err := ds.lookup(fieldOwnerType(rootType, pf.Path), pf.Field.Name)
+			if err != nil {
+				return
+			}
+			if ok {
+				meta.applyDescription(fd)
+			}
+
+			prefix, err := bsonPathPrefix(bsonTagParser, ds, rootType, pf.Path)
+			if err != nil {
+				return
+			}
+			meta.BSONPath = prefix + meta.BSON.Name
+
+			if len(meta.SQLColumnName) == 0 && !meta.SQLSkip {
+				meta.SQLColumnName = refutil.DefaultSQLColumnName(pf.Field.Name)
+			}
+
+			options.Name = pf.Field.Name
+			if len(meta.TargetName) > 0 {
+				options.Name = meta.TargetName
+			}
+			options.Meta = meta
+
+			// Fields of embedded structures are accessible as if they were part of outer structure,
+			// but unless inlined, they are stored in subdocument, see bsonPathPrefix.
+			isEmbeddable := pf.Field.Anonymous || meta.BSON.Inline || pf.Field.Type.Kind() == reflect.Ptr
+			options.Embed = isStructField(pf.Field) && isEmbeddable &&
+				(meta.BSON.Inline || !meta.BSON.NameSet) && !meta.BSON.Skip
+			return
+		})
+		return
+	})
+}
+
+func newMutationFieldProcessorFactory(ds *descriptions) stdesc.FieldProcessorFactory {
+	return stdesc.FieldProcessorFactoryFunc(func(ctx context.Context, rootType reflect.Type) (fp stdesc.FieldProcessor, err error) {
+		fp = newMutationFieldProcessor(ds, rootType)
+		return
+	})
+}
+
+func newMutationFieldProcessor(ds *descriptions, rootType reflect.Type) stdesc.FieldProcessor {
+	return stdesc.FieldProcessorFunc(func(pf stdesc.PendingFiled) (options stdesc.FieldOptions, err error) {
+		var meta mutatorMeta
+
+		err = meta.ParseTag(pf.Field.Tag.Get(defaultMutatorTagName))
+		if err != nil {
+			err = &Error{
+				Descriptorion: fmt.Sprintf("Invalid mttor tag of field %s in %s: %s", pf.Field.Name, rootType, err),
+			}
+			return
+		}
+
+		meta.Roles = parseRolesTag(pf.Field.Tag.Get(rolesTagName))
+
+		fd, ok, err := ds.lookup(fieldOwnerType(rootType, pf.Path), pf.Field.Name)
+		if err != nil {
+			return
+		}
+		if ok {
+			meta.applyDescription(fd)
+		}
+
+		options.Skip = !pf.Field.IsExported() || meta.TargetFieldName == "-" || fd.Skip
+
+		if len(meta.TargetFieldName) == 0 {
+			meta.TargetFieldName = pf.Field.Name
+		}
+
+		options.Name = pf.Field.Name
+		options.Meta = meta
+		options.Embed = (pf.Field.Anonymous && pf.Field.Type.Kind() == reflect.Struct ||
+			(pf.Field.Type.Kind() == reflect.Ptr && pf.Field.Type.Elem().Kind() == reflect.Struct)) && meta.MutationName == ""
+		return
+	})
+}
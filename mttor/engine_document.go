@@ -0,0 +1,384 @@
+package mttor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/teawithsand/arcah/internal/refutil"
+	"github.com/teawithsand/arcah/internal/tagparse"
+	"github.com/teawithsand/arcah/mongoutil/mongoeval"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Types of documents, which can be mutated.
+// Documents, which aren't maps, can be changed only through pointer.
+var documentTypes = []reflect.Type{
+	reflect.TypeOf(bson.M{}),
+	reflect.TypeOf(map[string]interface{}{}),
+	reflect.TypeOf(&bson.M{}),
+	reflect.TypeOf(&map[string]interface{}{}),
+	reflect.TypeOf(&bson.D{}),
+	reflect.TypeOf(&bson.Raw{}),
+}
+
+// Returns true, if values of given type are documents without go structure, like bson.M or pointer to bson.D or bson.Raw.
+//
+// Target field names of mutations of documents are keys or dotted paths of fields in document.
+func isDocumentType(ty reflect.Type) bool {
+	if ty == nil {
+		return false
+	}
+
+	for _, documentType := range documentTypes {
+		if ty == documentType {
+			return true
+		}
+	}
+	return false
+}
+
+// mongoUpdateBuilder groups fields of mongo update by their operators,
+// in order operators first appear in.
+type mongoUpdateBuilder struct {
+	update  bson.D
+	indices map[string]int
+}
+
+func (ub *mongoUpdateBuilder) add(operator string, entry bson.E) {
+	if ub.indices == nil {
+		ub.update = bson.D{}
+		ub.indices = map[string]int{}
+	}
+
+	i, ok := ub.indices[operator]
+	if !ok {
+		i = len(ub.update)
+		ub.indices[operator] = i
+		ub.update = append(ub.update, bson.E{
+			Key:   operator,
+			Value: bson.D{},
+		})
+	}
+
+	ub.update[i].Value = append(ub.update[i].Value.(bson.D), entry)
+}
+
+func (ub *mongoUpdateBuilder) result() bson.D {
+	if ub.update == nil {
+		return bson.D{}
+	}
+	return ub.update
+}
+
+// documentMutationField is single field of mutation of document.
+type documentMutationField struct {
+	Meta  mutatorMeta
+	Value reflect.Value
+}
+
+// Returns fields of mutation, which is applied to document.
+func (dm *defaultMutatorEngine) documentMutationFields(ctx context.Context, mutation interface{}) (fields []documentMutationField, err error) {
+	if mapMutation, ok := asMapMutation(mutation); ok {
+		keys := make([]string, 0, len(mapMutation))
+		for k := range mapMutation {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			var tag tagparse.MutationTag
+			tag, err = tagparse.ParseMutationTag(key)
+			if err != nil {
+				err = &Error{
+					Descriptorion: fmt.Sprintf("Invalid key %q of map mutation: %s", key, err),
+				}
+				return
+			}
+
+			fields = append(fields, documentMutationField{
+				Meta: mutatorMeta{
+					TargetFieldName:    tag.TargetFieldName,
+					MutationName:       tag.MutationName,
+					TargetMutationArgs: MutationArgs(tag.Args),
+				},
+				Value: reflect.ValueOf(mapMutation[key]),
+			})
+		}
+		return
+	}
+
+	mutationDescriptor, err := dm.mutationComputer.ComputeDescriptor(ctx, reflect.TypeOf(mutation))
+	if err != nil {
+		return
+	}
+
+	refMutation := reflect.ValueOf(mutation)
+	for _, mf := range sortedFields(mutationDescriptor) {
+		value, _ := refutil.FieldByPath(refMutation, mf.Path)
+		fields = append(fields, documentMutationField{
+			Meta:  mf.Meta.(mutatorMeta),
+			Value: value,
+		})
+	}
+	return
+}
+
+// Renders mongo update of document of given type.
+// Documents have no metadata, so types of values are not checked and no field is readonly.
+func (dm *defaultMutatorEngine) renderDocumentMutation(ctx context.Context, documentType reflect.Type, mutation interface{}) (update bson.D, err error) {
+	fields, err := dm.documentMutationFields(ctx, mutation)
+	if err != nil {
+		return
+	}
+
+	var ub mongoUpdateBuilder
+	var forbiddenFields []string
+	for _, f := range fields {
+		mutator, ok := dm.mutationMap[f.Meta.MutationName]
+		if !ok {
+			err = &Error{
+				Descriptorion: fmt.Sprintf("Mutation %s is not registered", f.Meta.MutationName),
+			}
+			return
+		}
+
+		mongoMutator, ok := mutator.(MongoMutator)
+		if !ok {
+			err = &Error{
+				Descriptorion: fmt.Sprintf("Registered mutation %s is not mongo mutation", f.Meta.MutationName),
+			}
+			return
+		}
+
+		if !f.Value.IsValid() {
+			continue
+		}
+
+		if f.Meta.TargetMutationArgs.IsSet("omitempty") && refutil.ValueIsEmpty(f.Value) {
+			continue
+		}
+
+		if dm.options.PermissionChecker != nil {
+			var canWrite bool
+			canWrite, err = dm.options.PermissionChecker.CanWrite(ctx, FieldPermission{
+				TargetType:    documentType,
+				FieldName:     f.Meta.TargetFieldName,
+				MutationRoles: f.Meta.Roles,
+			})
+			if err != nil {
+				return
+			}
+
+			if !canWrite {
+				forbiddenFields = append(forbiddenFields, f.Meta.TargetFieldName)
+				continue
+			}
+		}
+
+		var entry bson.E
+		entry, err = mongoMutator.RenderMongoDoc(ctx, MongoMutatorData{
+			MutatorData: MutatorData{
+				Value:        f.Value.Interface(),
+				Args:         f.Meta.TargetMutationArgs,
+				FieldName:    f.Meta.TargetFieldName,
+				MutationName: f.Meta.MutationName,
+			},
+			BSONFieldName: f.Meta.TargetFieldName,
+		})
+		if err != nil {
+			return
+		}
+
+		ub.add(mongoMutator.MongoMutationName(), entry)
+	}
+
+	if len(forbiddenFields) > 0 {
+		err = &PermissionError{
+			TargetType: documentType,
+			Fields:     forbiddenFields,
+		}
+		return
+	}
+
+	update = ub.result()
+	return
+}
+
+// Returns update, which inserts document: fields of $setOnInsert are set along with ones of $set.
+func insertUpdate(update bson.D) (res bson.D) {
+	var setFields bson.D
+	for _, e := range update {
+		if e.Key == "$set" || e.Key == "$setOnInsert" {
+			setFields = append(setFields, e.Value.(bson.D)...)
+			continue
+		}
+		res = append(res, e)
+	}
+
+	if len(setFields) > 0 {
+		res = append(bson.D{{Key: "$set", Value: setFields}}, res...)
+	}
+	return
+}
+
+// Returns document, which target holds.
+func documentOf(target interface{}) (doc interface{}, ok bool) {
+	switch t := target.(type) {
+	case bson.M:
+		return t, t != nil
+	case map[string]interface{}:
+		return t, t != nil
+	case *bson.Raw:
+		if t != nil {
+			return *t, true
+		}
+	case *bson.M:
+		if t != nil {
+			return *t, true
+		}
+	case *map[string]interface{}:
+		if t != nil {
+			return *t, true
+		}
+	case *bson.D:
+		if t != nil {
+			return t, true
+		}
+	}
+	return
+}
+
+// Applies mutation to document.
+// Mutation is rendered as mongo update, which is evaluated in memory, so document changes the way it would in database.
+// If inserting, document is created from empty one.
+//
+// Document is left intact, if mutation fails, so it's always atomic.
+// Documents can't implement hooks and aren't validated, so engine with TargetValidator rejects them.
+func (dm *defaultMutatorEngine) mutateDocument(ctx context.Context, target, mutation interface{}, inserting bool) (err error) {
+	if dm.options.TargetValidator != nil {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("Document target %T can't be validated", target),
+		}
+		return
+	}
+
+	update, err := dm.renderDocumentMutation(ctx, reflect.TypeOf(target), mutation)
+	if err != nil {
+		return
+	}
+
+	doc, ok := documentOf(target)
+	if !ok {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("Document target must be non-nil map or pointer to document, got %T", target),
+		}
+		return
+	}
+
+	if inserting {
+		doc = nil
+		update = insertUpdate(update)
+	}
+
+	// mongo rejects empty updates, but there is nothing to change anyway
+	res := bson.D{}
+	if len(update) > 0 {
+		res, err = mongoeval.ApplyUpdate(doc, update)
+		if err != nil {
+			return
+		}
+	} else if !inserting {
+		return
+	}
+
+	raw, err := bson.Marshal(res)
+	if err != nil {
+		return
+	}
+
+	switch t := target.(type) {
+	case bson.M:
+		return setDocumentMap(t, raw, update, inserting)
+	case map[string]interface{}:
+		return setDocumentMap(t, raw, update, inserting)
+	case *bson.M:
+		return setDocumentMapPtr((*map[string]interface{})(t), raw, update, inserting)
+	case *map[string]interface{}:
+		return setDocumentMapPtr(t, raw, update, inserting)
+	case *bson.Raw:
+		*t = raw
+		return
+	}
+
+	// target is pointer to bson.D, see documentOf
+	refTarget := reflect.ValueOf(target)
+	refTarget.Elem().Set(reflect.Zero(refTarget.Type().Elem()))
+	return bson.Unmarshal(raw, target)
+}
+
+// Sets map, which pointer points to, creating it if it's nil. See setDocumentMap.
+func setDocumentMapPtr(target *map[string]interface{}, raw bson.Raw, update bson.D, inserting bool) (err error) {
+	if *target == nil {
+		*target = map[string]interface{}{}
+	}
+	return setDocumentMap(*target, raw, update, inserting)
+}
+
+// Writes paths of update from updated document to map, so untouched values keep their go types.
+// If inserting, all other keys are removed.
+func setDocumentMap(target map[string]interface{}, raw bson.Raw, update bson.D, inserting bool) (err error) {
+	var resMap bson.M
+	err = bson.Unmarshal(raw, &resMap)
+	if err != nil {
+		return
+	}
+
+	if inserting {
+		for k := range target {
+			delete(target, k)
+		}
+	}
+
+	for _, operator := range update {
+		for _, e := range operator.Value.(bson.D) {
+			setDocumentPath(target, resMap, strings.Split(e.Key, "."))
+		}
+	}
+	return
+}
+
+// Sets value at path of target to one of updated document or removes it, if updated document doesn't have it.
+// Maps on path are descended into, other values are replaced as whole.
+func setDocumentPath(target map[string]interface{}, res bson.M, path []string) {
+	key := path[0]
+	value, ok := res[key]
+	if !ok {
+		delete(target, key)
+		return
+	}
+
+	if len(path) > 1 {
+		child, isMap := documentMap(target[key])
+		resChild, isResMap := value.(bson.M)
+		if isMap && isResMap {
+			setDocumentPath(child, resChild, path[1:])
+			return
+		}
+	}
+
+	target[key] = value
+}
+
+// Returns value as map, if it's non-nil map document.
+func documentMap(v interface{}) (m map[string]interface{}, ok bool) {
+	switch t := v.(type) {
+	case bson.M:
+		return t, t != nil
+	case map[string]interface{}:
+		return t, t != nil
+	}
+	return
+}
@@ -0,0 +1,182 @@
+package mttor_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/mttor"
+	"github.com/teawithsand/arcah/validation"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type DocumentMutation struct {
+	Name   string `mttor:"profile.name"`
+	Visits int32  `mttor:"visits,inc"`
+	Tag    string `mttor:"tags,push,omitempty"`
+}
+
+func TestMutator_Document(t *testing.T) {
+	engine := mttor.NewDefaultEngine()
+	mutation := DocumentMutation{Name: "asdf", Visits: 2, Tag: "new"}
+
+	t.Run("map", func(t *testing.T) {
+		doc := bson.M{"visits": int32(1), "other": "x"}
+		err := engine.Mutate(context.Background(), doc, mutation)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := bson.M{
+			"visits":  int32(3),
+			"other":   "x",
+			"profile": bson.M{"name": "asdf"},
+			"tags":    bson.A{"new"},
+		}
+		if !reflect.DeepEqual(doc, expected) {
+			t.Error("invalid document", doc)
+			return
+		}
+	})
+
+	t.Run("d", func(t *testing.T) {
+		doc := bson.D{{Key: "visits", Value: int32(1)}}
+		err := engine.Mutate(context.Background(), &doc, mttor.MapMutation{
+			"visits,inc": int32(1),
+			"name":       "fdsa",
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := bson.D{{Key: "visits", Value: int32(2)}, {Key: "name", Value: "fdsa"}}
+		if !reflect.DeepEqual(doc, expected) {
+			t.Error("invalid document", doc)
+			return
+		}
+	})
+
+	t.Run("raw", func(t *testing.T) {
+		doc, err := bson.Marshal(bson.D{{Key: "visits", Value: int32(1)}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		raw := bson.Raw(doc)
+		err = engine.Mutate(context.Background(), &raw, mutation)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if raw.Lookup("visits").Int32() != 3 || raw.Lookup("profile", "name").StringValue() != "asdf" {
+			t.Error("invalid document", raw)
+			return
+		}
+	})
+
+	t.Run("untouched_types", func(t *testing.T) {
+		doc := map[string]interface{}{
+			"n":       5,
+			"tags":    []string{"x"},
+			"profile": map[string]interface{}{"age": 7},
+		}
+		err := engine.Mutate(context.Background(), doc, mttor.MapMutation{
+			"other":        1,
+			"profile.name": "asdf",
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := map[string]interface{}{
+			"n":       5,
+			"tags":    []string{"x"},
+			"profile": map[string]interface{}{"age": 7, "name": "asdf"},
+			"other":   int32(1),
+		}
+		if !reflect.DeepEqual(doc, expected) {
+			t.Error("invalid document", doc)
+			return
+		}
+	})
+
+	t.Run("nil_map_pointer", func(t *testing.T) {
+		var doc bson.M
+		err := engine.Mutate(context.Background(), &doc, mttor.MapMutation{
+			"name": "fdsa",
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if !reflect.DeepEqual(doc, bson.M{"name": "fdsa"}) {
+			t.Error("invalid document", doc)
+			return
+		}
+	})
+
+	t.Run("invalid_update", func(t *testing.T) {
+		doc := bson.M{"visits": "not a number"}
+		err := engine.Mutate(context.Background(), doc, mutation)
+		if err == nil {
+			t.Error("expected error")
+			return
+		}
+
+		if doc["visits"] != "not a number" {
+			t.Error("document was changed", doc)
+			return
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		doc := bson.M{"visits": int32(1)}
+		err := engine.Mutate(context.Background(), doc, struct {
+			Name string `mttor:"name,,omitempty"`
+		}{})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if !reflect.DeepEqual(doc, bson.M{"visits": int32(1)}) {
+			t.Error("document was changed", doc)
+			return
+		}
+	})
+
+	t.Run("validator", func(t *testing.T) {
+		engine := mttor.NewEngine(mttor.EngineOptions{
+			TargetValidator: validation.NewValidator(),
+		})
+
+		var mttorError *mttor.Error
+		err := engine.Mutate(context.Background(), bson.M{}, mutation)
+		if !errors.As(err, &mttorError) {
+			t.Error("expected mttor error, got", err)
+			return
+		}
+	})
+
+	t.Run("not_pointer", func(t *testing.T) {
+		err := engine.Mutate(context.Background(), bson.D{}, mutation)
+		if err == nil {
+			t.Error("expected error")
+			return
+		}
+
+		// rendering doesn't need target, so value type is fine
+		_, err = engine.(mttor.MongoEngine).RenderMongoMutation(context.Background(), reflect.TypeOf(bson.D{}), mutation)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	})
+}
@@ -0,0 +1,250 @@
+package mttor_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/teawithsand/arcah/mttor"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type Address struct {
+	Street string
+	City   string
+}
+
+type Audit struct {
+	Revision int64
+}
+
+type Labels struct {
+	Label string `bson:"label"`
+}
+
+type Company struct {
+	Name string
+
+	Audit
+	Labels `bson:",inline"`
+
+	HQ     *Address
+	Branch *Address `bson:"branch_office"`
+}
+
+type Office struct {
+	Name string
+	// name equals default one, but it's set, so it's stored as subdocument
+	Site *Address `bson:"site"`
+}
+
+type CompanyUpdate struct {
+	Name     string   `mttor:",,omitempty"`
+	Revision int64    `mttor:",inc,omitempty"`
+	Label    string   `mttor:",,omitempty"`
+	Street   string   `mttor:",,omitempty"`
+	Branch   *Address `mttor:",set"`
+}
+
+// Checks that every key of rendered mutation points to value, which local mutation has set in BSON document.
+func checkRenderedPaths(t *testing.T, target interface{}, rendered bson.D) {
+	raw, err := bson.Marshal(target)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, op := range rendered {
+		for _, e := range op.Value.(bson.D) {
+			value, err := bson.Raw(raw).LookupErr(strings.Split(e.Key, ".")...)
+			if err != nil {
+				t.Error("rendered path", e.Key, "not found in document", bson.Raw(raw))
+				return
+			}
+
+			if op.Key != "$set" {
+				continue
+			}
+
+			_, expected, err := bson.MarshalValue(e.Value)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if !reflect.DeepEqual(expected, value.Value) {
+				t.Error("rendered value of", e.Key, "does not match document", value)
+				return
+			}
+		}
+	}
+}
+
+func TestMutator_EmbeddedPaths(t *testing.T) {
+	engine := mttor.NewDefaultEngine()
+	mongoEngine := mttor.NewMongoEngine()
+
+	for _, tc := range []struct {
+		name     string
+		target   Company
+		mutation CompanyUpdate
+		expected bson.D
+	}{
+		{
+			name:     "anonymous",
+			mutation: CompanyUpdate{Revision: 1},
+			expected: bson.D{
+				{Key: "$inc", Value: bson.D{{Key: "audit.revision", Value: int64(1)}}},
+				{Key: "$set", Value: bson.D{{Key: "branch_office", Value: (*Address)(nil)}}},
+			},
+		},
+		{
+			name:     "inline",
+			mutation: CompanyUpdate{Label: "l"},
+			expected: bson.D{
+				{Key: "$set", Value: bson.D{{Key: "label", Value: "l"}, {Key: "branch_office", Value: (*Address)(nil)}}},
+			},
+		},
+		{
+			name:     "pointer",
+			target:   Company{HQ: &Address{}},
+			mutation: CompanyUpdate{Street: "s"},
+			expected: bson.D{
+				{Key: "$set", Value: bson.D{{Key: "hq.street", Value: "s"}, {Key: "branch_office", Value: (*Address)(nil)}}},
+			},
+		},
+		{
+			name:     "named_pointer",
+			mutation: CompanyUpdate{Name: "n", Branch: &Address{City: "c"}},
+			expected: bson.D{
+				{Key: "$set", Value: bson.D{{Key: "name", Value: "n"}, {Key: "branch_office", Value: &Address{City: "c"}}}},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			rendered, err := mongoEngine.RenderMongoMutation(context.Background(), reflect.TypeOf(Company{}), tc.mutation)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if !reflect.DeepEqual(rendered, tc.expected) {
+				t.Errorf("expected %+v got %+v", tc.expected, rendered)
+				return
+			}
+
+			company := tc.target
+			err = engine.Mutate(context.Background(), &company, tc.mutation)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			checkRenderedPaths(t, company, rendered.(bson.D))
+		})
+	}
+
+	t.Run("nil_pointer", func(t *testing.T) {
+		// mongo rejects setting hq.street, when hq is null
+		company := Company{}
+		err := engine.Mutate(context.Background(), &company, CompanyUpdate{Street: "s"})
+
+		var mttorError *mttor.Error
+		if !errors.As(err, &mttorError) {
+			t.Error("expected mttor error, got", err)
+			return
+		}
+
+		if company.HQ != nil {
+			t.Error("data was changed, while expected it not to", company)
+			return
+		}
+	})
+}
+
+func TestMutator_NamedPointerWithDefaultName(t *testing.T) {
+	mongoEngine := mttor.NewMongoEngine()
+
+	rendered, err := mongoEngine.RenderMongoMutation(context.Background(), reflect.TypeOf(Office{}), mttor.MapMutation{
+		"Site": &Address{City: "c"},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	expected := bson.D{{Key: "$set", Value: bson.D{{Key: "site", Value: &Address{City: "c"}}}}}
+	if !reflect.DeepEqual(rendered, expected) {
+		t.Errorf("expected %+v got %+v", expected, rendered)
+		return
+	}
+
+	_, err = mongoEngine.RenderMongoMutation(context.Background(), reflect.TypeOf(Office{}), mttor.MapMutation{
+		"City": "c",
+	})
+	if err == nil {
+		t.Error("expected error, since fields of named structure are not flattened")
+		return
+	}
+}
+
+func TestMutator_ApplyMongoChange_Subdocuments(t *testing.T) {
+	engine := mttor.NewDefaultEngine().(mttor.ChangeApplyingEngine)
+
+	mustMarshal := func(v interface{}) bson.Raw {
+		raw, err := bson.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	for _, tc := range []struct {
+		name     string
+		target   Company
+		change   mttor.MongoUpdateDescription
+		expected Company
+	}{
+		{
+			name: "set_pointer",
+			change: mttor.MongoUpdateDescription{
+				UpdatedFields: mustMarshal(bson.D{{Key: "hq", Value: bson.D{{Key: "street", Value: "s"}}}}),
+			},
+			expected: Company{HQ: &Address{Street: "s"}},
+		},
+		{
+			name:   "set_anonymous",
+			target: Company{Audit: Audit{Revision: 1}},
+			change: mttor.MongoUpdateDescription{
+				UpdatedFields: mustMarshal(bson.D{{Key: "audit", Value: bson.D{{Key: "revision", Value: int64(9)}}}}),
+			},
+			expected: Company{Audit: Audit{Revision: 9}},
+		},
+		{
+			name:   "unset_pointer",
+			target: Company{Name: "n", HQ: &Address{Street: "s"}},
+			change: mttor.MongoUpdateDescription{
+				RemovedFields: []string{"hq"},
+			},
+			expected: Company{Name: "n"},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			company := tc.target
+			err := engine.ApplyMongoChange(context.Background(), &company, tc.change)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if !reflect.DeepEqual(company, tc.expected) {
+				t.Errorf("expected %+v got %+v", tc.expected, company)
+				return
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package mttor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/teawithsand/arcah/internal/tagparse"
+	"github.com/teawithsand/reval/stdesc"
+)
+
+// MapMutation is mutation built at runtime, rather than declared as DTO type.
+// It may be passed to Mutate and rendering methods of engine anywhere, where DTO is accepted.
+//
+// Keys are names of target fields, which may be followed by mutation name and args,
+// using grammar of mttor tag, like "Counter,inc" or "Name,set,omitempty". Mutation defaults to set.
+//
+// Values are coerced to types accepted by mutator for target field, so values decoded from JSON may be used directly.
+type MapMutation map[string]interface{}
+
+func asMapMutation(mutation interface{}) (res MapMutation, ok bool) {
+	switch m := mutation.(type) {
+	case MapMutation:
+		return m, true
+	case map[string]interface{}:
+		return MapMutation(m), true
+	}
+	return
+}
+
+type mapMutationEntry struct {
+	Key   string
+	Meta  mutatorMeta
+	Field stdesc.Field
+	Value interface{}
+}
+
+// Adds entries of map mutation to plan builder.
+// Operations are ordered by position of their target fields in target structure.
+func (dm *defaultMutatorEngine) buildMapPlan(
+	ctx context.Context,
+	pb *planBuilder,
+	targetDescriptor stdesc.Descriptor,
+	mutation MapMutation,
+) (err error) {
+	targetType := pb.targetType
+	entries := make([]mapMutationEntry, 0, len(mutation))
+	for key, value := range mutation {
+		var tag tagparse.MutationTag
+		tag, err = tagparse.ParseMutationTag(key)
+		if err != nil {
+			err = &Error{
+				Descriptorion: fmt.Sprintf("Invalid key %q of map mutation: %s", key, err),
+			}
+			return
+		}
+
+		meta := mutatorMeta{
+			TargetFieldName:    tag.TargetFieldName,
+			MutationName:       tag.MutationName,
+			TargetMutationArgs: MutationArgs(tag.Args),
+		}
+
+		tf, ok := targetDescriptor.NameToField[meta.TargetFieldName]
+		if !ok {
+			err = &Error{
+				Descriptorion: fmt.Sprintf("Field %s is not available in target of type %s", meta.TargetFieldName, targetType),
+			}
+			return
+		}
+
+		entries = append(entries, mapMutationEntry{
+			Key:   key,
+			Meta:  meta,
+			Field: tf,
+			Value: value,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		lhs, rhs := entries[i].Field.Path, entries[j].Field.Path
+		for k := 0; k < len(lhs) && k < len(rhs); k++ {
+			if lhs[k] != rhs[k] {
+				return lhs[k] < rhs[k]
+			}
+		}
+		if len(lhs) != len(rhs) {
+			return len(lhs) < len(rhs)
+		}
+		return entries[i].Key < entries[j].Key
+	})
+
+	for _, e := range entries {
+		mutator, ok := dm.mutationMap[e.Meta.MutationName]
+		if !ok {
+			err = &Error{
+				Descriptorion: fmt.Sprintf("Mutation %s is not registered", e.Meta.MutationName),
+			}
+			return
+		}
+
+		var value reflect.Value
+		value, err = coerceMutationValue(mutator, e.Field.Type, e.Value)
+		if err != nil {
+			err = &Error{
+				Descriptorion: fmt.Sprintf("Invalid value of field %s for mutation %s: %s", e.Meta.TargetFieldName, e.Meta.MutationName, err),
+			}
+			return
+		}
+
+		err = pb.add(ctx, e.Key, e.Field, mutator, e.Meta, value)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Converts value to type, which mutator accepts for target field of given type.
+//
+// Value is used as-is, when mutator accepts it. Otherwise it's converted to type of target field
+// or, for slices, to type of their elements, whichever mutator accepts first.
+// Mutators, which do not check types, are given value as-is.
+func coerceMutationValue(mutator Mutator, targetType reflect.Type, value interface{}) (res reflect.Value, err error) {
+	typeChecker, ok := mutator.(TypeCheckingMutator)
+
+	res = reflect.ValueOf(value)
+	if !ok {
+		return
+	}
+
+	if res.IsValid() {
+		err = typeChecker.CheckTypes(targetType, res.Type())
+		if err == nil {
+			return
+		}
+	}
+
+	candidates := []reflect.Type{targetType}
+	if targetType.Kind() == reflect.Slice {
+		candidates = append(candidates, targetType.Elem())
+	}
+
+	for _, ty := range candidates {
+		converted, convErr := convertValue(value, ty)
+		if convErr != nil {
+			err = convErr
+			continue
+		}
+
+		checkErr := typeChecker.CheckTypes(targetType, ty)
+		if checkErr != nil {
+			err = checkErr
+			continue
+		}
+
+		res = converted
+		err = nil
+		return
+	}
+	return
+}
+
+// Converts value to given type.
+// Value is encoded as JSON and decoded into new value, so numbers have to be represented exactly
+// and maps may be used in place of structures.
+func convertValue(value interface{}, ty reflect.Type) (res reflect.Value, err error) {
+	if value == nil {
+		res = reflect.Zero(ty)
+		return
+	}
+
+	refValue := reflect.ValueOf(value)
+	if refValue.Type().AssignableTo(ty) {
+		res = reflect.New(ty).Elem()
+		res.Set(refValue)
+		return
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+
+	ptr := reflect.New(ty)
+	err = json.Unmarshal(raw, ptr.Interface())
+	if err != nil {
+		return
+	}
+
+	res = ptr.Elem()
+	return
+}
@@ -0,0 +1,105 @@
+package mttor_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/mttor"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMutator_Map(t *testing.T) {
+	engine := mttor.NewDefaultEngine()
+
+	t.Run("json", func(t *testing.T) {
+		var mutation map[string]interface{}
+		err := json.Unmarshal([]byte(`{"Text": "asdf", "Number,inc": 2, "Ints,push": 4}`), &mutation)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		data := Data{Number: 1, Ints: []int{3}}
+		err = engine.Mutate(context.Background(), &data, mutation)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := Data{Number: 3, Text: "asdf", Ints: []int{3, 4}}
+		if !reflect.DeepEqual(data, expected) {
+			t.Error("invalid mutation result", data)
+			return
+		}
+	})
+
+	t.Run("omitempty", func(t *testing.T) {
+		data := Data{Text: "asdf"}
+		err := engine.Mutate(context.Background(), &data, mttor.MapMutation{
+			"Text,,omitempty": "",
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if data.Text != "asdf" {
+			t.Error("empty value wasn't omitted", data)
+			return
+		}
+	})
+
+	t.Run("mongo", func(t *testing.T) {
+		res, err := mttor.NewMongoEngine().RenderMongoMutation(context.Background(), reflect.TypeOf(Data{}), mttor.MapMutation{
+			"Ints,push":  []interface{}{1.0, 2.0},
+			"Number,inc": 2.0,
+			"Text":       "asdf",
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "number", Value: int64(2)}}},
+			{Key: "$set", Value: bson.D{{Key: "text", Value: "asdf"}}},
+			{Key: "$push", Value: bson.D{{Key: "ints", Value: bson.D{{Key: "$each", Value: []int{1, 2}}}}}},
+		}
+		if !reflect.DeepEqual(res, expected) {
+			t.Error("invalid rendered mutation", res)
+			return
+		}
+	})
+
+	t.Run("readonly", func(t *testing.T) {
+		err := engine.Mutate(context.Background(), &Document{}, mttor.MapMutation{
+			"ID": "x",
+		})
+
+		var readonlyError *mttor.ReadonlyFieldError
+		if !errors.As(err, &readonlyError) {
+			t.Error("expected readonly error, got", err)
+			return
+		}
+	})
+
+	for name, mutation := range map[string]mttor.MapMutation{
+		"unknown_field":    {"Missing": 1},
+		"unknown_mutation": {"Text,missing": "asdf"},
+		"invalid_type":     {"Text": 1},
+		"inexact_number":   {"Number,inc": 1.5},
+	} {
+		mutation := mutation
+		t.Run(name, func(t *testing.T) {
+			data := Data{}
+			err := engine.Mutate(context.Background(), &data, mutation)
+			if err == nil {
+				t.Error("expected error")
+				return
+			}
+		})
+	}
+}
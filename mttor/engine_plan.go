@@ -0,0 +1,233 @@
+package mttor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/teawithsand/arcah/internal/refutil"
+	"github.com/teawithsand/reval/stdesc"
+)
+
+// operation is single field of mutation resolved against descriptor of target.
+type operation struct {
+	TargetField stdesc.Field
+	TargetMeta  mutatorTargetMeta
+
+	Mutator Mutator
+	Data    MutatorData
+}
+
+// Returns fields of descriptor ordered by their position in structure,
+// so that mutations are applied and rendered in deterministic order.
+func sortedFields(desc stdesc.Descriptor) (fields []stdesc.Field) {
+	fields = make([]stdesc.Field, 0, len(desc.NameToField))
+	for _, f := range desc.NameToField {
+		fields = append(fields, f)
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		lhs, rhs := fields[i].Path, fields[j].Path
+		for k := 0; k < len(lhs) && k < len(rhs); k++ {
+			if lhs[k] != rhs[k] {
+				return lhs[k] < rhs[k]
+			}
+		}
+		return len(lhs) < len(rhs)
+	})
+	return
+}
+
+// planBuilder collects operations of mutation, along with fields, which can't be written.
+type planBuilder struct {
+	engine           *defaultMutatorEngine
+	targetType       reflect.Type
+	targetDescriptor stdesc.Descriptor
+
+	ops             []operation
+	readonlyFields  []string
+	readonlyFlags   []string
+	forbiddenFields []string
+
+	// If true, steps are recorded for Explain.
+	explain bool
+	steps   []PlanStep
+}
+
+// Adds operation of mutator on target field, unless it's omitted or rejected.
+// Source is name of mutation field or key of map mutation, which is used by Explain.
+// Value is invalid, when field of mutation is not set at all.
+func (pb *planBuilder) add(ctx context.Context, source string, tf stdesc.Field, mutator Mutator, meta mutatorMeta, value reflect.Value) (err error) {
+	status := StepApplied
+	defer func() {
+		if pb.explain && err == nil {
+			pb.steps = append(pb.steps, newPlanStep(pb.targetType, source, tf, meta, status))
+		}
+	}()
+
+	targetMeta := tf.Meta.(mutatorTargetMeta)
+	if flag := readonlyFlag(targetMeta, mutator); len(flag) > 0 {
+		status = StepReadonly
+		pb.readonlyFields = append(pb.readonlyFields, meta.TargetFieldName)
+		pb.readonlyFlags = append(pb.readonlyFlags, flag)
+		return
+	}
+
+	if !value.IsValid() {
+		status = StepNotSet
+		return
+	}
+
+	if meta.TargetMutationArgs.IsSet("omitempty") {
+		if refutil.ValueIsEmpty(value) {
+			status = StepOmitted
+			return
+		}
+	}
+
+	if pb.engine.options.PermissionChecker != nil {
+		var canWrite bool
+		canWrite, err = pb.engine.options.PermissionChecker.CanWrite(ctx, FieldPermission{
+			TargetType:    pb.targetType,
+			FieldName:     meta.TargetFieldName,
+			TargetRoles:   targetMeta.Roles,
+			MutationRoles: meta.Roles,
+		})
+		if err != nil {
+			return
+		}
+
+		if !canWrite {
+			status = StepForbidden
+			pb.forbiddenFields = append(pb.forbiddenFields, meta.TargetFieldName)
+			return
+		}
+	}
+
+	mutationValue := value.Interface()
+	if bm, ok := mutator.(bindingMutator); ok {
+		mutationValue, err = bm.bindValue(pb.targetDescriptor, mutationValue)
+		if err != nil {
+			return
+		}
+	}
+
+	pb.ops = append(pb.ops, operation{
+		TargetField: tf,
+		TargetMeta:  targetMeta,
+		Mutator:     mutator,
+		Data: MutatorData{
+			Value:        mutationValue,
+			Args:         meta.TargetMutationArgs,
+			FieldName:    meta.TargetFieldName,
+			MutationName: meta.MutationName,
+		},
+	})
+	return
+}
+
+// Returns operations collected or error, if any field can't be written.
+func (pb *planBuilder) finish() (ops []operation, err error) {
+	if len(pb.readonlyFields) > 0 {
+		err = &ReadonlyFieldError{
+			TargetType: pb.targetType,
+			Fields:     pb.readonlyFields,
+			Flags:      pb.readonlyFlags,
+		}
+		return
+	}
+
+	if len(pb.forbiddenFields) > 0 {
+		err = &PermissionError{
+			TargetType: pb.targetType,
+			Fields:     pb.forbiddenFields,
+		}
+		return
+	}
+
+	ops = pb.ops
+	return
+}
+
+// Resolves all fields of mutation into operations on target of given type.
+// Fields, which are omitted due to omitempty are not returned.
+func (dm *defaultMutatorEngine) planMutation(ctx context.Context, targetType reflect.Type, mutation interface{}) (ops []operation, err error) {
+	if targetType == nil {
+		err = &Error{
+			Descriptorion: "Target type is nil",
+		}
+		return
+	}
+
+	for targetType.Kind() == reflect.Ptr {
+		targetType = targetType.Elem()
+	}
+
+	pb := planBuilder{
+		engine:     dm,
+		targetType: targetType,
+	}
+
+	err = dm.buildPlan(ctx, &pb, mutation)
+	if err != nil {
+		return
+	}
+
+	return pb.finish()
+}
+
+// Adds all fields of mutation to plan builder.
+func (dm *defaultMutatorEngine) buildPlan(ctx context.Context, pb *planBuilder, mutation interface{}) (err error) {
+	refMutation := reflect.ValueOf(mutation)
+	targetType := pb.targetType
+
+	targetDescriptor, err := dm.targetComputer.ComputeDescriptor(ctx, targetType)
+	if err != nil {
+		return
+	}
+	pb.targetDescriptor = targetDescriptor
+
+	if mapMutation, ok := asMapMutation(mutation); ok {
+		return dm.buildMapPlan(ctx, pb, targetDescriptor, mapMutation)
+	}
+
+	mutationDescriptor, err := dm.mutationComputer.ComputeDescriptor(ctx, reflect.TypeOf(mutation))
+	if err != nil {
+		return
+	}
+
+	for _, mf := range sortedFields(mutationDescriptor) {
+		meta := mf.Meta.(mutatorMeta)
+
+		tf, ok := targetDescriptor.NameToField[meta.TargetFieldName]
+		if !ok {
+			err = &Error{
+				Descriptorion: fmt.Sprintf("Field %s is not available in target of type %s", meta.TargetFieldName, targetType),
+			}
+			return
+		}
+
+		mutator, ok := dm.mutationMap[meta.MutationName]
+		if !ok {
+			err = &Error{
+				Descriptorion: fmt.Sprintf("Mutation %s is not registered", meta.MutationName),
+			}
+			return
+		}
+
+		// fields of nil embedded structures are not set, so they are treated as omitted
+		mutationFieldRefValue, _ := refutil.FieldByPath(refMutation, mf.Path)
+
+		var source string
+		if pb.explain {
+			source = goFieldPath(refMutation.Type(), mf.Path)
+		}
+
+		err = pb.add(ctx, source, tf, mutator, meta, mutationFieldRefValue)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
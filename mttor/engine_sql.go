@@ -0,0 +1,132 @@
+package mttor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SQLDialect abstracts away differences between SQL databases, which matter for rendering mutations.
+type SQLDialect interface {
+	// Returns placeholder for n-th argument of statement, counting from one.
+	Placeholder(n int) string
+}
+
+type SQLDialectFunc func(n int) string
+
+func (f SQLDialectFunc) Placeholder(n int) string {
+	return f(n)
+}
+
+// Dialect using $1, $2, ... placeholders.
+var PostgresDialect SQLDialect = SQLDialectFunc(func(n int) string {
+	return "$" + strconv.Itoa(n)
+})
+
+// Dialect using ? placeholders, like SQLite and MySQL.
+var QuestionDialect SQLDialect = SQLDialectFunc(func(n int) string {
+	return "?"
+})
+
+// SQLDialect, which casts to float in other way than standard CAST(expr AS DOUBLE PRECISION).
+type SQLFloatCastDialect interface {
+	SQLDialect
+	// Returns expression cast to double precision float.
+	FloatCast(expr string) string
+}
+
+type mysqlDialect struct {
+	SQLDialect
+}
+
+func (mysqlDialect) FloatCast(expr string) string {
+	return "CAST(" + expr + " AS DOUBLE)"
+}
+
+var SQLiteDialect = QuestionDialect
+
+// Dialect using ? placeholders, which casts to DOUBLE, since MySQL has no DOUBLE PRECISION type in CAST.
+var MySQLDialect SQLDialect = mysqlDialect{SQLDialect: QuestionDialect}
+
+// Plain identifier, optionally qualified with schema, which needs no quoting in any dialect.
+var sqlIdentifierRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// Identifiers are put into statements as they are, so ones, which would need quoting, are rejected.
+func checkSQLIdentifier(name string) (err error) {
+	if !sqlIdentifierRegexp.MatchString(name) {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("Invalid SQL identifier %q", name),
+		}
+	}
+	return
+}
+
+func (dm *defaultMutatorEngine) RenderSQLMutation(
+	ctx context.Context,
+	dialect SQLDialect,
+	table string,
+	targetType reflect.Type,
+	mutation interface{},
+) (query string, args []interface{}, err error) {
+	err = checkSQLIdentifier(table)
+	if err != nil {
+		return
+	}
+
+	ops, err := dm.planMutation(ctx, targetType, mutation)
+	if err != nil {
+		return
+	}
+
+	var assignments []string
+	for _, op := range ops {
+		// UPDATE never creates rows
+		if isInsertOnly(op.Mutator) {
+			continue
+		}
+
+		sqlMutation, ok := op.Mutator.(SQLMutator)
+		if !ok {
+			err = &Error{
+				Descriptorion: fmt.Sprintf("Registered mutation %s is not SQL mutation", op.Data.MutationName),
+			}
+			return
+		}
+
+		if op.TargetMeta.SQLSkip {
+			continue
+		}
+
+		err = checkSQLIdentifier(op.TargetMeta.SQLColumnName)
+		if err != nil {
+			return
+		}
+
+		var expr string
+		var exprArgs []interface{}
+		expr, exprArgs, err = sqlMutation.RenderSQLAssignment(ctx, SQLMutatorData{
+			MutatorData:   op.Data,
+			SQLColumnName: op.TargetMeta.SQLColumnName,
+			TargetType:    op.TargetField.Type,
+			Dialect:       dialect,
+			ArgOffset:     len(args),
+		})
+		if err != nil {
+			return
+		}
+
+		assignments = append(assignments, op.TargetMeta.SQLColumnName+" = "+expr)
+		args = append(args, exprArgs...)
+	}
+
+	if len(assignments) == 0 {
+		err = ErrEmptyMutation
+		return
+	}
+
+	query = "UPDATE " + table + " SET " + strings.Join(assignments, ", ")
+	return
+}
@@ -0,0 +1,155 @@
+package mttor_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/mttor"
+)
+
+type SQLData struct {
+	Name    string `db:"user_name"`
+	Counter int64  `sql:"cnt"`
+	Avatar  string
+	Ints    []int
+}
+
+type SQLDataMutation struct {
+	Name        string `mttor:",,omitempty"`
+	Counter     int64  `mttor:",inc"`
+	ClearAvatar bool   `mttor:"Avatar,unset,omitempty"`
+}
+
+type SQLRow struct {
+	A int `db:",omitempty"`
+	B int `db:"b"`
+	C int `db:"-"`
+}
+
+type SQLRowMutation struct {
+	A int
+	B int
+}
+
+type SQLDataRename struct {
+	Name string `mttor:",,omitempty"`
+}
+
+func TestSQLEngine_Render(t *testing.T) {
+	engine := mttor.NewSQLEngine()
+
+	t.Run("postgres", func(t *testing.T) {
+		query, args, err := engine.RenderSQLMutation(context.Background(), mttor.PostgresDialect, "users", reflect.TypeOf(SQLData{}), SQLDataMutation{
+			Name:        "asdf",
+			Counter:     2,
+			ClearAvatar: true,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if query != "UPDATE users SET user_name = $1, cnt = cnt + $2, avatar = $3" {
+			t.Error("invalid query", query)
+			return
+		}
+
+		if !reflect.DeepEqual(args, []interface{}{"asdf", int64(2), ""}) {
+			t.Error("invalid args", args)
+			return
+		}
+	})
+
+	t.Run("sqlite_omitempty", func(t *testing.T) {
+		query, args, err := engine.RenderSQLMutation(context.Background(), mttor.SQLiteDialect, "users", reflect.TypeOf(SQLData{}), SQLDataMutation{
+			Counter: 2,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if query != "UPDATE users SET cnt = cnt + ?" {
+			t.Error("invalid query", query)
+			return
+		}
+
+		if !reflect.DeepEqual(args, []interface{}{int64(2)}) {
+			t.Error("invalid args", args)
+			return
+		}
+	})
+
+	t.Run("tag_without_name", func(t *testing.T) {
+		query, args, err := engine.RenderSQLMutation(context.Background(), mttor.PostgresDialect, "t", reflect.TypeOf(SQLRow{}), SQLRowMutation{A: 1, B: 2})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if query != "UPDATE t SET a = $1, b = $2" || !reflect.DeepEqual(args, []interface{}{1, 2}) {
+			t.Error("invalid query", query, args)
+			return
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, _, err := engine.RenderSQLMutation(context.Background(), mttor.PostgresDialect, "users", reflect.TypeOf(SQLData{}), SQLDataRename{})
+		if !errors.Is(err, mttor.ErrEmptyMutation) {
+			t.Error("expected empty mutation error, got", err)
+			return
+		}
+	})
+
+	t.Run("invalid_table", func(t *testing.T) {
+		_, _, err := engine.RenderSQLMutation(context.Background(), mttor.PostgresDialect, "users; DROP TABLE users", reflect.TypeOf(SQLData{}), SQLDataRename{
+			Name: "asdf",
+		})
+		if err == nil {
+			t.Error("expected error for invalid table name")
+			return
+		}
+	})
+
+	t.Run("nil_target_type", func(t *testing.T) {
+		_, _, err := engine.RenderSQLMutation(context.Background(), mttor.PostgresDialect, "users", nil, SQLDataRename{
+			Name: "asdf",
+		})
+		if err == nil {
+			t.Error("expected error for nil target type")
+			return
+		}
+	})
+
+	t.Run("no_sql_support", func(t *testing.T) {
+		_, _, err := engine.RenderSQLMutation(context.Background(), mttor.PostgresDialect, "users", reflect.TypeOf(SQLData{}), DataPushInt{
+			Value: 1,
+		})
+		if err == nil {
+			t.Error("expected error for mutation without SQL support")
+			return
+		}
+	})
+}
+
+func TestMutator_Unset(t *testing.T) {
+	engine := mttor.NewDefaultEngine()
+
+	data := SQLData{
+		Avatar: "avatar.png",
+	}
+	err := engine.Mutate(context.Background(), &data, SQLDataMutation{
+		ClearAvatar: true,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if data.Avatar != "" {
+		t.Error("data wasn't mutated", "got", data.Avatar)
+		return
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/teawithsand/arcah/mongoutil/mongoeval"
 	"github.com/teawithsand/arcah/mttor"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -55,6 +56,14 @@ type DataPushInts struct {
 	Values []int `mttor:"Ints,push"`
 }
 
+type DataInt32 struct {
+	Number int32
+}
+
+type DataIncInt32 struct {
+	Number int32 `mttor:",inc"`
+}
+
 func TestMutator_OnObject(t *testing.T) {
 	engine := mttor.NewDefaultEngine()
 	t.Run("set", func(t *testing.T) {
@@ -138,9 +147,169 @@ func TestMutator_OnObject(t *testing.T) {
 			return
 		}
 	})
+
+	t.Run("inc_int32", func(t *testing.T) {
+		data := DataInt32{
+			Number: 31,
+		}
+		err := engine.Mutate(context.Background(), &data, DataIncInt32{
+			Number: 11,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if data.Number != 42 {
+			t.Error("data wasn't mutated", "got", data.Number)
+			return
+		}
+	})
+}
+
+type DataInvalidInc struct {
+	Text   string
+	Number float64 `mttor:",inc"`
+}
+
+func TestMutator_Atomic(t *testing.T) {
+	t.Run("non_atomic", func(t *testing.T) {
+		engine := mttor.NewDefaultEngine()
+		data := Data{
+			Number: 31,
+			Text:   "fdsa",
+		}
+		err := engine.Mutate(context.Background(), &data, DataInvalidInc{
+			Text:   "asdf",
+			Number: 11,
+		})
+		if err == nil {
+			t.Error("expected error")
+			return
+		}
+
+		if data.Text != "asdf" {
+			t.Error("expected partial mutation", "got", data.Text)
+			return
+		}
+	})
+
+	t.Run("atomic_error", func(t *testing.T) {
+		engine := mttor.NewEngine(mttor.EngineOptions{
+			Atomic: true,
+		})
+		data := Data{
+			Number: 31,
+			Text:   "fdsa",
+			Ints:   []int{1, 2, 3},
+		}
+		err := engine.Mutate(context.Background(), &data, DataInvalidInc{
+			Text:   "asdf",
+			Number: 11,
+		})
+		if err == nil {
+			t.Error("expected error")
+			return
+		}
+
+		if !reflect.DeepEqual(data, Data{Number: 31, Text: "fdsa", Ints: []int{1, 2, 3}}) {
+			t.Error("data was changed, while expected it not to", data)
+			return
+		}
+	})
+
+	t.Run("atomic_success", func(t *testing.T) {
+		engine := mttor.NewEngine(mttor.EngineOptions{
+			Atomic: true,
+		})
+		data := Data{
+			Number: 31,
+			Text:   "fdsa",
+		}
+		err := engine.Mutate(context.Background(), &data, DataCombined{
+			Text:   "asdf",
+			Number: 11,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if data.Text != "asdf" || data.Number != 42 {
+			t.Error("data wasn't mutated", data)
+			return
+		}
+	})
+
+	t.Run("atomic_keeps_identity", func(t *testing.T) {
+		engine := mttor.NewEngine(mttor.EngineOptions{
+			Atomic: true,
+		})
+		ints := []int{1, 2, 3}
+		data := Data{
+			Text: "fdsa",
+			Ints: ints,
+		}
+		err := engine.Mutate(context.Background(), &data, DataCombined{
+			Text: "asdf",
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if &data.Ints[0] != &ints[0] {
+			t.Error("field, which mutation does not change, was replaced")
+			return
+		}
+	})
+}
+
+// Checks that rendered mutation, applied with mongoeval, gives same result as Mutate.
+func DoTestMutationOffline(t *testing.T, engine mttor.Engine, mongoEngine mttor.MongoEngine, data, mutation interface{}) {
+	raw, err := bson.Marshal(data)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	local := reflect.New(reflect.TypeOf(data).Elem()).Interface()
+	evaluated := reflect.New(reflect.TypeOf(data).Elem()).Interface()
+	for _, target := range []interface{}{local, evaluated} {
+		err = bson.Unmarshal(raw, target)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	err = engine.Mutate(context.Background(), local, mutation)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	renderedMutation, err := mongoEngine.RenderMongoMutation(context.Background(), reflect.TypeOf(data), mutation)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = mongoeval.ApplyUpdateTo(evaluated, renderedMutation)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !reflect.DeepEqual(local, evaluated) {
+		t.Error(fmt.Errorf("mutation mismatch in mongoeval and local one: expected %+#v got %+#v", local, evaluated))
+		return
+	}
 }
 
 func DoTestMutationOnMongo(t *testing.T, engine mttor.Engine, mongoEngine mttor.MongoEngine, data, mutation interface{}) {
+	DoTestMutationOffline(t, engine, mongoEngine, data, mutation)
+
 	uri := os.Getenv("ARCAH_TEST_MONGO")
 	if len(uri) > 0 {
 		client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
@@ -213,8 +382,7 @@ func DoTestMutationOnMongo(t *testing.T, engine mttor.Engine, mongoEngine mttor.
 func TestMutator_WithMongo(t *testing.T) {
 	uri := os.Getenv("ARCAH_TEST_MONGO")
 	if len(uri) == 0 {
-		log.Default().Println("Note: omitting non-mongo tests")
-		return
+		log.Default().Println("Note: testing mongo mutations with mongoeval only")
 	}
 	engine := mttor.NewDefaultEngine()
 	mongoEngine := mttor.NewMongoEngine()
@@ -261,4 +429,16 @@ func TestMutator_WithMongo(t *testing.T) {
 			Values: []int{4, 5, 6},
 		})
 	})
+
+	// Note: HQ is set, since mongo can't set field in subdocument, which is null
+	t.Run("embedded", func(t *testing.T) {
+		DoTestMutationOnMongo(t, engine, mongoEngine, &Company{
+			HQ: &Address{},
+		}, CompanyUpdate{
+			Revision: 2,
+			Label:    "l",
+			Street:   "s",
+			Branch:   &Address{City: "c"},
+		})
+	})
 }
@@ -1,5 +1,10 @@
 package mttor
 
+import "errors"
+
+// Returned, when rendered mutation would not change any field, so it can't be expressed as valid statement.
+var ErrEmptyMutation = errors.New("arcah/mttor: mutation does not change any field")
+
 type Error struct {
 	Descriptorion string
 }
@@ -0,0 +1,212 @@
+package mttor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/teawithsand/arcah/internal/tagparse"
+	"github.com/teawithsand/reval/stdesc"
+)
+
+// Engine, which explains how mutations are applied, for debugging, logs and admin endpoints.
+type ExplainingEngine interface {
+	// Returns plan of mutation applied to target of given type.
+	//
+	// Mutations rejected due to readonly or forbidden fields are explained rather than returned as errors,
+	// see Plan.Rejected.
+	Explain(ctx context.Context, targetType reflect.Type, mutation interface{}) (plan *Plan, err error)
+}
+
+// StepStatus tells what happens with single field of mutation.
+type StepStatus string
+
+const (
+	// Field is applied to target.
+	StepApplied StepStatus = "applied"
+	// Field is empty and has omitempty arg.
+	StepOmitted StepStatus = "omitempty"
+	// Field belongs to nil embedded structure.
+	StepNotSet StepStatus = "not set"
+	// Field is skipped with "-" tag or Skip of Describe, so it's never applied.
+	StepSkipped StepStatus = "skipped"
+	// Target field is readonly or immutable, so whole mutation is rejected.
+	StepReadonly StepStatus = "readonly"
+	// Permission checker does not allow writing target field, so whole mutation is rejected.
+	StepForbidden StepStatus = "forbidden"
+)
+
+// PlanStep describes single field of mutation.
+// Only MutationField and Status are set for skipped fields.
+type PlanStep struct {
+	// Go path of mutation field, like "Address.City", or key of map mutation.
+	MutationField string `json:"mutationField"`
+
+	// Name, which mutations use to refer to target field.
+	TargetField string `json:"targetField,omitempty"`
+	// Go path of target field.
+	TargetPath string `json:"targetPath,omitempty"`
+	BSONPath   string `json:"bsonPath,omitempty"`
+	SQLColumn  string `json:"sqlColumn,omitempty"`
+
+	Mutation string       `json:"mutation,omitempty"`
+	Args     MutationArgs `json:"args,omitempty"`
+
+	Status StepStatus `json:"status"`
+}
+
+// Plan describes how mutation is applied to target, field by field.
+// It may be encoded as JSON or printed.
+type Plan struct {
+	TargetType   string     `json:"targetType"`
+	MutationType string     `json:"mutationType"`
+	Steps        []PlanStep `json:"steps"`
+}
+
+// Returns true, if mutation is rejected, since some of its fields can't be written.
+func (p *Plan) Rejected() bool {
+	for _, s := range p.Steps {
+		if s.Status == StepReadonly || s.Status == StepForbidden {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns plan formatted as table.
+func (p *Plan) String() string {
+	if p == nil {
+		return "<nil>"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s -> %s\n", p.MutationType, p.TargetType)
+
+	w := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FIELD\tMUTATION\tARGS\tTARGET\tBSON\tSQL\tSTATUS")
+	for _, s := range p.Steps {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			s.MutationField, s.Mutation, formatArgs(s.Args), s.TargetPath, s.BSONPath, s.SQLColumn, s.Status)
+	}
+	w.Flush()
+	return sb.String()
+}
+
+// Formats args the way they are written in mttor tag.
+func formatArgs(args MutationArgs) string {
+	// target and op are empty, so tag starts with two commas
+	return strings.TrimPrefix(tagparse.FormatMutationTag(tagparse.MutationTag{Args: args}), ",,")
+}
+
+func newPlanStep(targetType reflect.Type, source string, tf stdesc.Field, meta mutatorMeta, status StepStatus) PlanStep {
+	targetMeta := tf.Meta.(mutatorTargetMeta)
+
+	mutationName := meta.MutationName
+	if len(mutationName) == 0 {
+		mutationName = "set"
+	}
+
+	step := PlanStep{
+		MutationField: source,
+		TargetField:   meta.TargetFieldName,
+		TargetPath:    goFieldPath(targetType, tf.Path),
+		BSONPath:      targetMeta.BSONPath,
+		Mutation:      mutationName,
+		Args:          meta.TargetMutationArgs,
+		Status:        status,
+	}
+	if !targetMeta.SQLSkip {
+		step.SQLColumn = targetMeta.SQLColumnName
+	}
+	return step
+}
+
+// Returns dotted names of fields on given path in struct of given type.
+func goFieldPath(rootType reflect.Type, path []int) string {
+	names := make([]string, 0, len(path))
+	ty := rootType
+	for _, i := range path {
+		for ty.Kind() == reflect.Ptr {
+			ty = ty.Elem()
+		}
+		sf := ty.Field(i)
+		names = append(names, sf.Name)
+		ty = sf.Type
+	}
+	return strings.Join(names, ".")
+}
+
+func (dm *defaultMutatorEngine) Explain(ctx context.Context, targetType reflect.Type, mutation interface{}) (plan *Plan, err error) {
+	for targetType.Kind() == reflect.Ptr {
+		targetType = targetType.Elem()
+	}
+
+	pb := planBuilder{
+		engine:     dm,
+		targetType: targetType,
+		explain:    true,
+	}
+
+	err = dm.buildPlan(ctx, &pb, mutation)
+	if err != nil {
+		return
+	}
+
+	plan = &Plan{
+		TargetType:   targetType.String(),
+		MutationType: fmt.Sprintf("%T", mutation),
+		Steps:        pb.steps,
+	}
+
+	if _, ok := asMapMutation(mutation); ok {
+		return
+	}
+
+	mutationDescriptor, err := dm.mutationComputer.ComputeDescriptor(ctx, reflect.TypeOf(mutation))
+	if err != nil {
+		plan = nil
+		return
+	}
+
+	plan.Steps = append(plan.Steps, skippedSteps(reflect.TypeOf(mutation), mutationDescriptor)...)
+	return
+}
+
+// Returns steps of exported fields of mutation, which are not in its descriptor.
+func skippedSteps(mutationType reflect.Type, desc stdesc.Descriptor) (steps []PlanStep) {
+	leaves := map[string]bool{}
+	prefixes := map[string]bool{}
+	for _, f := range desc.NameToField {
+		leaves[fmt.Sprint(f.Path)] = true
+		for i := 1; i < len(f.Path); i++ {
+			prefixes[fmt.Sprint(f.Path[:i])] = true
+		}
+	}
+
+	var walk func(ty reflect.Type, path []int)
+	walk = func(ty reflect.Type, path []int) {
+		for ty.Kind() == reflect.Ptr {
+			ty = ty.Elem()
+		}
+
+		for i := 0; i < ty.NumField(); i++ {
+			fieldPath := append(append([]int{}, path...), i)
+			key := fmt.Sprint(fieldPath)
+
+			switch {
+			case leaves[key]:
+			case prefixes[key]:
+				walk(ty.Field(i).Type, fieldPath)
+			case ty.Field(i).IsExported():
+				steps = append(steps, PlanStep{
+					MutationField: goFieldPath(mutationType, fieldPath),
+					Status:        StepSkipped,
+				})
+			}
+		}
+	}
+	walk(mutationType, nil)
+	return
+}
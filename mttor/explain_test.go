@@ -0,0 +1,102 @@
+package mttor_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/teawithsand/arcah/mttor"
+)
+
+type ExplainMutation struct {
+	Text    string `mttor:",,omitempty"`
+	Number  int64  `mttor:",inc"`
+	Ignored string `mttor:"-"`
+}
+
+type ExplainPush struct {
+	Ints []int `mttor:",push,max:10,omitempty"`
+}
+
+func TestExplain(t *testing.T) {
+	engine := mttor.NewDefaultEngine().(mttor.ExplainingEngine)
+
+	t.Run("struct", func(t *testing.T) {
+		plan, err := engine.Explain(context.Background(), reflect.TypeOf(Data{}), ExplainMutation{Number: 1})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := []mttor.PlanStep{
+			{
+				MutationField: "Text",
+				TargetField:   "Text",
+				TargetPath:    "Text",
+				BSONPath:      "text",
+				SQLColumn:     "text",
+				Mutation:      "set",
+				Args:          mttor.MutationArgs{"omitempty": {""}},
+				Status:        mttor.StepOmitted,
+			},
+			{
+				MutationField: "Number",
+				TargetField:   "Number",
+				TargetPath:    "Number",
+				BSONPath:      "number",
+				SQLColumn:     "number",
+				Mutation:      "inc",
+				Args:          mttor.MutationArgs{},
+				Status:        mttor.StepApplied,
+			},
+			{
+				MutationField: "Ignored",
+				Status:        mttor.StepSkipped,
+			},
+		}
+		if !reflect.DeepEqual(plan.Steps, expected) {
+			t.Errorf("invalid plan %#v", plan.Steps)
+			return
+		}
+
+		if plan.Rejected() {
+			t.Error("plan is rejected")
+			return
+		}
+
+		if !strings.Contains(plan.String(), "Number   inc") {
+			t.Errorf("invalid formatted plan\n%s", plan)
+			return
+		}
+	})
+
+	t.Run("args", func(t *testing.T) {
+		plan, err := engine.Explain(context.Background(), reflect.TypeOf(Data{}), ExplainPush{Ints: []int{1}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		// args are formatted like in tag
+		if !strings.Contains(plan.String(), "max:10,omitempty") {
+			t.Errorf("invalid formatted plan\n%s", plan)
+			return
+		}
+	})
+
+	t.Run("readonly", func(t *testing.T) {
+		plan, err := engine.Explain(context.Background(), reflect.TypeOf(Document{}), mttor.MapMutation{
+			"CreatedAt": int64(1),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if len(plan.Steps) != 1 || plan.Steps[0].Status != mttor.StepReadonly || !plan.Rejected() {
+			t.Errorf("invalid plan\n%s", plan)
+			return
+		}
+	})
+}
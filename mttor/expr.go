@@ -0,0 +1,475 @@
+package mttor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/teawithsand/arcah/internal/refutil"
+	"github.com/teawithsand/reval/stdesc"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Expr is expression over fields of target, which expr mutation assigns to its target field,
+// like `mttor:"Total,expr"` with value Mul(Field("Price"), Field("Qty")).
+//
+// Expressions are evaluated against target with changes made by preceding fields of mutation,
+// both in go and in mongo, where mutation is rendered as update pipeline with stage for each field.
+// SQL assignments see row as it was before update, so there expressions should not use fields mutation changes.
+// Arithmetic follows mongo: integers stay integers, unless mixed with floats, but Div always yields float.
+// Results are assigned to fields only if they can be represented exactly, so non-integral float can't be assigned to integer field.
+type Expr interface {
+	exprNode()
+}
+
+type fieldExpr struct {
+	Name string
+}
+
+type literalExpr struct {
+	Value interface{}
+}
+
+type operatorExpr struct {
+	// Name of mongo aggregation operator, which expression is rendered as.
+	Operator string
+	Operands []Expr
+}
+
+func (*fieldExpr) exprNode()    {}
+func (*literalExpr) exprNode()  {}
+func (*operatorExpr) exprNode() {}
+
+// Returns value of target field with given name.
+func Field(name string) Expr {
+	return &fieldExpr{Name: name}
+}
+
+// Returns constant value.
+func Literal(value interface{}) Expr {
+	return &literalExpr{Value: value}
+}
+
+func Add(lhs, rhs Expr) Expr {
+	return &operatorExpr{Operator: "$add", Operands: []Expr{lhs, rhs}}
+}
+
+func Sub(lhs, rhs Expr) Expr {
+	return &operatorExpr{Operator: "$subtract", Operands: []Expr{lhs, rhs}}
+}
+
+func Mul(lhs, rhs Expr) Expr {
+	return &operatorExpr{Operator: "$multiply", Operands: []Expr{lhs, rhs}}
+}
+
+// Returns quotient of numbers, which is always float.
+func Div(lhs, rhs Expr) Expr {
+	return &operatorExpr{Operator: "$divide", Operands: []Expr{lhs, rhs}}
+}
+
+// Returns negation of truthiness of value, so Not(Field("Active")) toggles Active.
+// Like in mongo, false, nil and zero numbers are false and all other values are true.
+// In SQL it's rendered as NOT, so some databases, like Postgres, require bool operand.
+func Not(e Expr) Expr {
+	return &operatorExpr{Operator: "$not", Operands: []Expr{e}}
+}
+
+// boundExpr is expression with fields resolved against descriptor of target.
+type boundExpr struct {
+	source Expr
+
+	// Set for field expressions.
+	field    *stdesc.Field
+	bsonPath string
+	column   string
+
+	// Set for literal expressions.
+	value interface{}
+
+	// Set for operator expressions.
+	operator string
+	operands []*boundExpr
+}
+
+func bindExpr(desc stdesc.Descriptor, e Expr) (res *boundExpr, err error) {
+	res = &boundExpr{source: e}
+	switch e := e.(type) {
+	case *fieldExpr:
+		f, ok := desc.NameToField[e.Name]
+		if !ok {
+			res = nil
+			err = &Error{
+				Descriptorion: fmt.Sprintf("Field %s of expression is not available in target", e.Name),
+			}
+			return
+		}
+
+		meta := f.Meta.(mutatorTargetMeta)
+		res.field = &f
+		res.bsonPath = meta.BSONPath
+		res.column = meta.SQLColumnName
+	case *literalExpr:
+		res.value = e.Value
+	case *operatorExpr:
+		res.operator = e.Operator
+		for _, operand := range e.Operands {
+			var bound *boundExpr
+			bound, err = bindExpr(desc, operand)
+			if err != nil {
+				res = nil
+				return
+			}
+			res.operands = append(res.operands, bound)
+		}
+	default:
+		res = nil
+		err = &Error{
+			Descriptorion: fmt.Sprintf("Expression of type %T is not supported", e),
+		}
+	}
+	return
+}
+
+// Converts number to int64 or float64.
+// Unsigned numbers, which don't fit int64, are rejected, since mongo has no unsigned integers.
+func exprNumber(v interface{}) (res interface{}, err error) {
+	switch n := refutil.ValueToNumber(reflect.ValueOf(v)).(type) {
+	case int64, float64:
+		res = n
+	case uint64:
+		if n > math.MaxInt64 {
+			err = &Error{
+				Descriptorion: fmt.Sprintf("Operand %d of expression overflows int64", n),
+			}
+			return
+		}
+		res = int64(n)
+	default:
+		err = &Error{
+			Descriptorion: fmt.Sprintf("Operand of expression is %T, not number", v),
+		}
+	}
+	return
+}
+
+// Returns truthiness of value the way mongo does: false, nil and zero numbers are false, everything else is true.
+func exprTruthy(v interface{}) bool {
+	refV := reflect.ValueOf(v)
+	switch n := refutil.ValueToNumber(refV).(type) {
+	case int64:
+		return n != 0
+	case uint64:
+		return n != 0
+	case float64:
+		return n != 0
+	}
+
+	switch refV.Kind() {
+	case reflect.Invalid:
+		return false
+	case reflect.Bool:
+		return refV.Bool()
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		// nils are encoded as null
+		return !refV.IsNil()
+	}
+	return true
+}
+
+// Converts number to number type ty, failing if it can't be represented exactly.
+func convertExprNumber(value reflect.Value, ty reflect.Type) (res reflect.Value, err error) {
+	res = reflect.New(ty).Elem()
+
+	overflow := false
+	switch n := refutil.ValueToNumber(value).(type) {
+	case int64:
+		switch refutil.NumberKind(ty) {
+		case reflect.Int64:
+			overflow = res.OverflowInt(n)
+			res.SetInt(n)
+		case reflect.Uint64:
+			overflow = n < 0 || res.OverflowUint(uint64(n))
+			res.SetUint(uint64(n))
+		case reflect.Float64:
+			res.SetFloat(float64(n))
+		}
+	case uint64:
+		switch refutil.NumberKind(ty) {
+		case reflect.Int64:
+			overflow = n > math.MaxInt64 || res.OverflowInt(int64(n))
+			res.SetInt(int64(n))
+		case reflect.Uint64:
+			overflow = res.OverflowUint(n)
+			res.SetUint(n)
+		case reflect.Float64:
+			res.SetFloat(float64(n))
+		}
+	case float64:
+		integral := n == math.Trunc(n)
+		switch refutil.NumberKind(ty) {
+		case reflect.Int64:
+			overflow = !integral || n < math.MinInt64 || n >= math.MaxInt64 || res.OverflowInt(int64(n))
+			res.SetInt(int64(n))
+		case reflect.Uint64:
+			overflow = !integral || n < 0 || n >= math.MaxUint64 || res.OverflowUint(uint64(n))
+			res.SetUint(uint64(n))
+		case reflect.Float64:
+			res.SetFloat(n)
+		}
+	}
+
+	if overflow {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("value %v of expression can't be represented exactly by field of type %s", value, ty),
+		}
+	}
+	return
+}
+
+func exprFloat(v interface{}) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
+
+func (be *boundExpr) eval(target reflect.Value) (res interface{}, err error) {
+	switch {
+	case be.field != nil:
+		value, ok := refutil.FieldByPath(target, be.field.Path)
+		if !ok {
+			value = reflect.Zero(be.field.Type)
+		}
+		res = value.Interface()
+		return
+	case len(be.operator) == 0:
+		res = be.value
+		return
+	}
+
+	operands := make([]interface{}, 0, len(be.operands))
+	for _, o := range be.operands {
+		var v interface{}
+		v, err = o.eval(target)
+		if err != nil {
+			return
+		}
+		operands = append(operands, v)
+	}
+
+	if be.operator == "$not" {
+		res = !exprTruthy(operands[0])
+		return
+	}
+
+	lhs, err := exprNumber(operands[0])
+	if err != nil {
+		return
+	}
+	rhs, err := exprNumber(operands[1])
+	if err != nil {
+		return
+	}
+
+	li, lIsInt := lhs.(int64)
+	ri, rIsInt := rhs.(int64)
+	if be.operator == "$divide" {
+		if exprFloat(rhs) == 0 {
+			err = &Error{
+				Descriptorion: "Division by zero in expression",
+			}
+			return
+		}
+		res = exprFloat(lhs) / exprFloat(rhs)
+		return
+	}
+
+	if lIsInt && rIsInt {
+		switch be.operator {
+		case "$add":
+			res = li + ri
+		case "$subtract":
+			res = li - ri
+		case "$multiply":
+			res = li * ri
+		}
+		return
+	}
+
+	lf, rf := exprFloat(lhs), exprFloat(rhs)
+	switch be.operator {
+	case "$add":
+		res = lf + rf
+	case "$subtract":
+		res = lf - rf
+	case "$multiply":
+		res = lf * rf
+	}
+	return
+}
+
+// Renders expression as mongo aggregation expression.
+func (be *boundExpr) renderMongo() interface{} {
+	switch {
+	case be.field != nil:
+		return "$" + be.bsonPath
+	case len(be.operator) == 0:
+		return bson.D{{Key: "$literal", Value: be.value}}
+	}
+
+	operands := bson.A{}
+	for _, o := range be.operands {
+		operands = append(operands, o.renderMongo())
+	}
+	return bson.D{{Key: be.operator, Value: operands}}
+}
+
+var sqlOperators = map[string]string{
+	"$add":      "+",
+	"$subtract": "-",
+	"$multiply": "*",
+	"$divide":   "/",
+}
+
+// Renders expression as SQL expression, with placeholders for literals.
+func (be *boundExpr) renderSQL(data *SQLMutatorData, args *[]interface{}) (expr string, err error) {
+	switch {
+	case be.field != nil:
+		if len(be.column) == 0 {
+			err = &Error{
+				Descriptorion: fmt.Sprintf("Field %s of expression has no SQL column", be.field.Name),
+			}
+			return
+		}
+
+		err = checkSQLIdentifier(be.column)
+		if err != nil {
+			return
+		}
+		expr = be.column
+		return
+	case len(be.operator) == 0:
+		expr = data.Placeholder(len(*args))
+		*args = append(*args, be.value)
+		return
+	}
+
+	operands := make([]string, 0, len(be.operands))
+	for _, o := range be.operands {
+		var operand string
+		operand, err = o.renderSQL(data, args)
+		if err != nil {
+			return
+		}
+		operands = append(operands, operand)
+	}
+
+	if be.operator == "$not" {
+		expr = "(NOT " + operands[0] + ")"
+		return
+	}
+
+	if be.operator == "$divide" {
+		// division of integers truncates in SQL, but Div always yields float
+		for i, operand := range operands {
+			operands[i] = data.FloatCast(operand)
+		}
+	}
+
+	expr = "(" + strings.Join(operands, " "+sqlOperators[be.operator]+" ") + ")"
+	return
+}
+
+var exprType = reflect.TypeOf((*Expr)(nil)).Elem()
+
+// Assigns value of expression to target field.
+type exprMutation struct {
+}
+
+func (sm *exprMutation) bindValue(desc stdesc.Descriptor, value interface{}) (res interface{}, err error) {
+	e, ok := value.(Expr)
+	if !ok || e == nil {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("value of expr mutation must be non-nil Expr, got %T", value),
+		}
+		return
+	}
+	return bindExpr(desc, e)
+}
+
+func (sm *exprMutation) ApplyMutation(ctx context.Context, target reflect.Value, field stdesc.Field, data MutatorData) (err error) {
+	be, ok := data.Value.(*boundExpr)
+	if !ok {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("value of expr mutation is not bound expression, got %T", data.Value),
+		}
+		return
+	}
+
+	res, err := be.eval(target)
+	if err != nil {
+		return
+	}
+
+	value := reflect.ValueOf(res)
+	switch {
+	case !value.IsValid():
+		value = reflect.Zero(field.Type)
+	case value.Type().AssignableTo(field.Type):
+	case refutil.NumberKind(value.Type()) != reflect.Invalid && refutil.NumberKind(field.Type) != reflect.Invalid:
+		value, err = convertExprNumber(value, field.Type)
+		if err != nil {
+			return
+		}
+	default:
+		err = &Error{
+			Descriptorion: fmt.Sprintf("value of expression of type %s is not assignable to field of type %s", value.Type(), field.Type),
+		}
+		return
+	}
+
+	field.MustSet(target, value)
+	return
+}
+
+func (sm *exprMutation) CheckTypes(targetType, valueType reflect.Type) (err error) {
+	if !valueType.Implements(exprType) {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("value of type %s is not expression", valueType),
+		}
+		return
+	}
+	return
+}
+
+func (sm *exprMutation) DescribeMutation(targetFieldName string) string {
+	return "Expression assigned to " + targetFieldName
+}
+
+func (sm *exprMutation) RenderMongoPipelineField(ctx context.Context, data MongoMutatorData) (expr interface{}, err error) {
+	be, ok := data.Value.(*boundExpr)
+	if !ok {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("value of expr mutation is not bound expression, got %T", data.Value),
+		}
+		return
+	}
+
+	expr = be.renderMongo()
+	return
+}
+
+func (sm *exprMutation) RenderSQLAssignment(ctx context.Context, data SQLMutatorData) (expr string, args []interface{}, err error) {
+	be, ok := data.Value.(*boundExpr)
+	if !ok {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("value of expr mutation is not bound expression, got %T", data.Value),
+		}
+		return
+	}
+
+	expr, err = be.renderSQL(&data, &args)
+	return
+}
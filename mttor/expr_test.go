@@ -0,0 +1,202 @@
+package mttor_test
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/mttor"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type Order struct {
+	Price  int64
+	Qty    int32
+	Total  int64
+	Ratio  float64
+	Active bool
+}
+
+type OrderRecalculate struct {
+	Price  int64      `mttor:",,omitempty"`
+	Total  mttor.Expr `mttor:"Total,expr"`
+	Active mttor.Expr `mttor:"Active,expr"`
+}
+
+func newOrderRecalculate(price int64) OrderRecalculate {
+	return OrderRecalculate{
+		Price:  price,
+		Total:  mttor.Mul(mttor.Field("Price"), mttor.Field("Qty")),
+		Active: mttor.Not(mttor.Field("Active")),
+	}
+}
+
+func TestMutator_Expr(t *testing.T) {
+	ctx := context.Background()
+	engine := mttor.NewDefaultEngine()
+
+	t.Run("mutate", func(t *testing.T) {
+		order := Order{Price: 2, Qty: 3}
+		err := engine.Mutate(ctx, &order, newOrderRecalculate(5))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		// price is set before total is computed
+		expected := Order{Price: 5, Qty: 3, Total: 15, Active: true}
+		if order != expected {
+			t.Error("invalid order", order)
+			return
+		}
+	})
+
+	t.Run("div", func(t *testing.T) {
+		order := Order{Price: 3, Qty: 2}
+		err := engine.Mutate(ctx, &order, mttor.MapMutation{
+			"Ratio,expr": mttor.Div(mttor.Field("Price"), mttor.Field("Qty")),
+			"Total,expr": mttor.Add(mttor.Field("Price"), mttor.Literal(1)),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if order.Ratio != 1.5 || order.Total != 4 {
+			t.Error("invalid order", order)
+			return
+		}
+	})
+
+	t.Run("div_into_int", func(t *testing.T) {
+		order := Order{Price: 4, Qty: 2}
+		err := engine.Mutate(ctx, &order, mttor.MapMutation{
+			"Total,expr": mttor.Div(mttor.Field("Price"), mttor.Field("Qty")),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if order.Total != 2 {
+			t.Error("invalid order", order)
+			return
+		}
+
+		order = Order{Price: 3, Qty: 2}
+		err = engine.Mutate(ctx, &order, mttor.MapMutation{
+			"Total,expr": mttor.Div(mttor.Field("Price"), mttor.Field("Qty")),
+		})
+		if err == nil {
+			t.Error("expected error, got order", order)
+			return
+		}
+	})
+
+	t.Run("uint_overflow", func(t *testing.T) {
+		err := engine.Mutate(ctx, &Order{}, mttor.MapMutation{
+			"Total,expr": mttor.Add(mttor.Literal(uint64(math.MaxUint64)), mttor.Literal(1)),
+		})
+		if err == nil {
+			t.Error("expected error")
+			return
+		}
+	})
+
+	t.Run("not_truthiness", func(t *testing.T) {
+		for _, tc := range []struct {
+			Operand  mttor.Expr
+			Expected bool
+		}{
+			{mttor.Field("Qty"), true},
+			{mttor.Literal(nil), true},
+			{mttor.Literal(0.0), true},
+			{mttor.Literal("x"), false},
+			{mttor.Literal(1), false},
+		} {
+			order := Order{}
+			err := engine.Mutate(ctx, &order, mttor.MapMutation{
+				"Active,expr": mttor.Not(tc.Operand),
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if order.Active != tc.Expected {
+				t.Error("invalid negation of", tc.Operand, order.Active)
+				return
+			}
+		}
+	})
+
+	t.Run("unknown_field", func(t *testing.T) {
+		err := engine.Mutate(ctx, &Order{}, mttor.MapMutation{
+			"Total,expr": mttor.Field("Missing"),
+		})
+		if err == nil {
+			t.Error("expected error")
+			return
+		}
+	})
+
+	t.Run("mongo", func(t *testing.T) {
+		res, err := engine.(mttor.MongoEngine).RenderMongoMutation(ctx, reflect.TypeOf(Order{}), newOrderRecalculate(5))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := bson.A{
+			bson.D{{Key: "$set", Value: bson.D{{Key: "price", Value: bson.D{{Key: "$literal", Value: int64(5)}}}}}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "total", Value: bson.D{{Key: "$multiply", Value: bson.A{"$price", "$qty"}}}}}}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "active", Value: bson.D{{Key: "$not", Value: bson.A{"$active"}}}}}}},
+		}
+		if !reflect.DeepEqual(res, expected) {
+			t.Error("invalid pipeline", res)
+			return
+		}
+	})
+
+	t.Run("sql", func(t *testing.T) {
+		query, args, err := engine.(mttor.SQLEngine).RenderSQLMutation(ctx, mttor.PostgresDialect, "orders", reflect.TypeOf(Order{}), mttor.MapMutation{
+			"Total,expr": mttor.Mul(mttor.Field("Price"), mttor.Add(mttor.Field("Qty"), mttor.Literal(1))),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if query != "UPDATE orders SET total = (price * (qty + $1))" || !reflect.DeepEqual(args, []interface{}{1}) {
+			t.Error("invalid query", query, args)
+			return
+		}
+	})
+
+	t.Run("sql_div", func(t *testing.T) {
+		query, _, err := engine.(mttor.SQLEngine).RenderSQLMutation(ctx, mttor.PostgresDialect, "orders", reflect.TypeOf(Order{}), mttor.MapMutation{
+			"Ratio,expr": mttor.Div(mttor.Field("Price"), mttor.Field("Qty")),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if query != "UPDATE orders SET ratio = (CAST(price AS DOUBLE PRECISION) / CAST(qty AS DOUBLE PRECISION))" {
+			t.Error("invalid query", query)
+			return
+		}
+
+		query, _, err = engine.(mttor.SQLEngine).RenderSQLMutation(ctx, mttor.MySQLDialect, "orders", reflect.TypeOf(Order{}), mttor.MapMutation{
+			"Ratio,expr": mttor.Div(mttor.Field("Price"), mttor.Field("Qty")),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if query != "UPDATE orders SET ratio = (CAST(price AS DOUBLE) / CAST(qty AS DOUBLE))" {
+			t.Error("invalid mysql query", query)
+			return
+		}
+	})
+}
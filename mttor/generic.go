@@ -0,0 +1,35 @@
+package mttor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Returns type of T, which works for interface types as well.
+func typeFor[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Apply applies mutation to target using engine.
+// Unlike Engine.Mutate, target is required to be pointer at compile time, so mutation is never applied to copy.
+func Apply[T, M any](ctx context.Context, e Engine, target *T, mutation M) (err error) {
+	if target == nil {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("Target of type %s is nil", typeFor[*T]()),
+		}
+		return
+	}
+
+	return e.Mutate(ctx, target, mutation)
+}
+
+// RenderFor renders mutation as mongo update of documents of type T.
+func RenderFor[T, M any](ctx context.Context, e MongoEngine, mutation M) (res interface{}, err error) {
+	return e.RenderMongoMutation(ctx, typeFor[T](), mutation)
+}
+
+// RenderSQLFor renders mutation as SQL UPDATE statement of table storing values of type T.
+func RenderSQLFor[T, M any](ctx context.Context, e SQLEngine, dialect SQLDialect, table string, mutation M) (query string, args []interface{}, err error) {
+	return e.RenderSQLMutation(ctx, dialect, table, typeFor[T](), mutation)
+}
@@ -0,0 +1,68 @@
+package mttor_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/mttor"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestApply(t *testing.T) {
+	engine := mttor.NewDefaultEngine()
+
+	t.Run("mutate", func(t *testing.T) {
+		data := Data{Number: 1}
+		err := mttor.Apply(context.Background(), engine, &data, DataIncNumber{Number: 2})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if data.Number != 3 {
+			t.Error("data wasn't mutated", data)
+			return
+		}
+	})
+
+	t.Run("nil_target", func(t *testing.T) {
+		var data *Data
+		err := mttor.Apply(context.Background(), engine, data, DataIncNumber{Number: 2})
+		if err == nil {
+			t.Error("expected error")
+			return
+		}
+	})
+}
+
+func TestRenderFor(t *testing.T) {
+	t.Run("mongo", func(t *testing.T) {
+		res, err := mttor.RenderFor[Data](context.Background(), mttor.NewMongoEngine(), DataSetText{Text: "asdf"})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := bson.D{{Key: "$set", Value: bson.D{{Key: "text", Value: "asdf"}}}}
+		if !reflect.DeepEqual(res, expected) {
+			t.Error("invalid rendered mutation", res)
+			return
+		}
+	})
+
+	t.Run("sql", func(t *testing.T) {
+		query, args, err := mttor.RenderSQLFor[SQLData](context.Background(), mttor.NewSQLEngine(), mttor.PostgresDialect, "users", SQLDataMutation{
+			Counter: 2,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if query != "UPDATE users SET cnt = cnt + $1" || !reflect.DeepEqual(args, []interface{}{int64(2)}) {
+			t.Error("invalid rendered mutation", query, args)
+			return
+		}
+	})
+}
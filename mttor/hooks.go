@@ -0,0 +1,107 @@
+package mttor
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/teawithsand/reval"
+)
+
+// Implemented by targets, which want to be notified before mutation is applied to them.
+type BeforeMutateHook interface {
+	BeforeMutate(ctx context.Context) (err error)
+}
+
+// Implemented by targets, which want to be notified after mutation was applied to them.
+// It's called before target is validated, so it may be used to update fields like modification time.
+type AfterMutateHook interface {
+	AfterMutate(ctx context.Context) (err error)
+}
+
+// Implemented by targets, which check their invariants after mutation was applied to them.
+type Validator interface {
+	Validate() (err error)
+}
+
+// Validates whole target after mutation was applied to it.
+// Target is wrapped with reval, so validators do not depend on its go type.
+type TargetValidator interface {
+	ValidateTarget(ctx context.Context, target reval.Value) (err error)
+}
+
+type TargetValidatorFunc func(ctx context.Context, target reval.Value) (err error)
+
+func (f TargetValidatorFunc) ValidateTarget(ctx context.Context, target reval.Value) (err error) {
+	return f(ctx, target)
+}
+
+// Returned, when target is not valid after mutation was applied to it.
+type ValidationError struct {
+	Err error
+}
+
+func (err *ValidationError) Error() string {
+	if err == nil {
+		return "<nil>"
+	}
+
+	return "arcah/mttor: target is not valid after mutation: " + err.Err.Error()
+}
+
+func (err *ValidationError) Unwrap() error {
+	return err.Err
+}
+
+// Returns true, if given target implements any of hooks, which are called around mutation.
+func hasMutationHooks(target interface{}) bool {
+	_, isBefore := target.(BeforeMutateHook)
+	_, isAfter := target.(AfterMutateHook)
+	_, isValidator := target.(Validator)
+	return isBefore || isAfter || isValidator
+}
+
+// Applies operations to target, calling hooks and validators around it.
+func (dm *defaultMutatorEngine) runMutation(ctx context.Context, target reflect.Value, ops []operation) (err error) {
+	if hook, ok := target.Interface().(BeforeMutateHook); ok {
+		err = hook.BeforeMutate(ctx)
+		if err != nil {
+			return
+		}
+	}
+
+	err = dm.applyOperations(ctx, target, ops)
+	if err != nil {
+		return
+	}
+
+	if hook, ok := target.Interface().(AfterMutateHook); ok {
+		err = hook.AfterMutate(ctx)
+		if err != nil {
+			return
+		}
+	}
+
+	if validator, ok := target.Interface().(Validator); ok {
+		err = validator.Validate()
+		if err != nil {
+			err = &ValidationError{Err: err}
+			return
+		}
+	}
+
+	if dm.options.TargetValidator != nil {
+		var wrapped reval.Value
+		wrapped, err = (&reval.DefaultWrapper{}).Wrap(target.Interface())
+		if err != nil {
+			return
+		}
+
+		err = dm.options.TargetValidator.ValidateTarget(ctx, wrapped)
+		if err != nil {
+			err = &ValidationError{Err: err}
+			return
+		}
+	}
+
+	return
+}
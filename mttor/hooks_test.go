@@ -0,0 +1,159 @@
+package mttor_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/teawithsand/arcah/mttor"
+	"github.com/teawithsand/arcah/validation"
+	"github.com/teawithsand/reval"
+)
+
+type Event struct {
+	StartDate int64
+	EndDate   int64
+
+	Revision int
+	calls    []string
+}
+
+func (e *Event) BeforeMutate(ctx context.Context) (err error) {
+	e.calls = append(e.calls, "before")
+	return
+}
+
+func (e *Event) AfterMutate(ctx context.Context) (err error) {
+	e.calls = append(e.calls, "after")
+	e.Revision++
+	return
+}
+
+func (e *Event) Validate() (err error) {
+	if e.EndDate < e.StartDate {
+		err = errors.New("event ends before it starts")
+	}
+	return
+}
+
+type ValueEvent struct {
+	EndDate int64
+}
+
+func (e ValueEvent) Validate() (err error) {
+	return
+}
+
+type ValidatedData struct {
+	Text string `valid:"required"`
+}
+
+type EventSetEnd struct {
+	EndDate int64
+}
+
+func TestMutator_Hooks(t *testing.T) {
+	engine := mttor.NewDefaultEngine()
+
+	t.Run("valid", func(t *testing.T) {
+		event := Event{
+			StartDate: 10,
+			EndDate:   20,
+		}
+		err := engine.Mutate(context.Background(), &event, EventSetEnd{
+			EndDate: 30,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if event.EndDate != 30 || event.Revision != 1 {
+			t.Error("data wasn't mutated", event)
+			return
+		}
+	})
+
+	t.Run("invalid_rolls_back", func(t *testing.T) {
+		event := Event{
+			StartDate: 10,
+			EndDate:   20,
+		}
+		err := engine.Mutate(context.Background(), &event, EventSetEnd{
+			EndDate: 5,
+		})
+
+		var validationError *mttor.ValidationError
+		if !errors.As(err, &validationError) {
+			t.Error("expected validation error, got", err)
+			return
+		}
+
+		if event.EndDate != 20 || event.Revision != 0 || len(event.calls) != 0 {
+			t.Error("data was changed, while expected it not to", event)
+			return
+		}
+	})
+
+	t.Run("validator", func(t *testing.T) {
+		engine := mttor.NewEngine(mttor.EngineOptions{
+			TargetValidator: validation.NewValidator(),
+		})
+
+		data := ValidatedData{
+			Text: "fdsa",
+		}
+		err := engine.Mutate(context.Background(), &data, &DataSetText{})
+
+		var validationError *validation.Error
+		if !errors.As(err, &validationError) {
+			t.Error("expected validation error, got", err)
+			return
+		}
+
+		if data.Text != "fdsa" {
+			t.Error("data was changed, while expected it not to", data)
+			return
+		}
+	})
+
+	t.Run("validator_func", func(t *testing.T) {
+		engine := mttor.NewEngine(mttor.EngineOptions{
+			TargetValidator: mttor.TargetValidatorFunc(func(ctx context.Context, target reval.Value) (err error) {
+				text, err := target.(reval.KeyedValue).GetField("Text")
+				if err != nil {
+					return
+				}
+				if text.Raw() == "" {
+					err = errors.New("text is empty")
+				}
+				return
+			}),
+		})
+
+		data := ValidatedData{
+			Text: "fdsa",
+		}
+		err := engine.Mutate(context.Background(), &data, &DataSetText{})
+
+		var validationError *mttor.ValidationError
+		if !errors.As(err, &validationError) {
+			t.Error("expected validation error, got", err)
+			return
+		}
+
+		if data.Text != "fdsa" {
+			t.Error("data was changed, while expected it not to", data)
+			return
+		}
+	})
+
+	t.Run("value_target", func(t *testing.T) {
+		err := engine.Mutate(context.Background(), ValueEvent{}, EventSetEnd{})
+		if err == nil || !strings.Contains(err.Error(), "hooks") {
+			t.Error("expected error naming hooks, got", err)
+			return
+		}
+	})
+}
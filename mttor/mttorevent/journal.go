@@ -0,0 +1,169 @@
+package mttorevent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/teawithsand/arcah/internal/refutil"
+	"github.com/teawithsand/arcah/mttor"
+	"github.com/teawithsand/arcah/mttor/mttorlog"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Returned by Journal.Mutate along with new version, when mutation was appended, but snapshot can't be stored.
+// Event is already stored, so mutation must not be retried.
+var ErrSnapshotFailed = errors.New("arcah/mttorevent: mutation was appended, but snapshot can't be stored")
+
+// Engine, which applies and records mutations, like one returned by mttor.NewDefaultEngine.
+type Engine interface {
+	mttor.Engine
+	mttor.RecordingEngine
+}
+
+// Journal applies mutations to entities and records them as events in store.
+type Journal struct {
+	Engine   Engine
+	Registry *mttorlog.Registry
+	Store    Store
+
+	// If not zero, snapshot of entity is stored, whenever its version is multiple of it.
+	SnapshotEvery uint64
+}
+
+func checkTarget(target interface{}) (refTarget reflect.Value, err error) {
+	refTarget = reflect.ValueOf(target)
+	if refTarget.Kind() != reflect.Ptr || refTarget.IsNil() {
+		err = &mttor.Error{
+			Descriptorion: fmt.Sprintf("Target must be non-nil pointer, got %T", target),
+		}
+	}
+	return
+}
+
+// Applies mutation to target, which is entity of stream at given version, and appends it as event.
+// Returns version of entity after mutation.
+//
+// Mutation is recorded, encoded as BSON and decoded back, then replayed on copy of target,
+// so target has exactly state, which Rebuild restores.
+// Target is left intact, if mutation can't be applied or appended.
+// If it was appended, but snapshot can't be stored, new version is returned with ErrSnapshotFailed.
+func (j *Journal) Mutate(ctx context.Context, streamID string, version uint64, target, mutation interface{}) (newVersion uint64, err error) {
+	refTarget, err := checkTarget(target)
+	if err != nil {
+		return
+	}
+
+	recorded, err := mttorlog.Record(ctx, j.Engine, j.Registry, refTarget.Type(), mutation)
+	if err != nil {
+		return
+	}
+
+	// log is replayed the way it's read from store, since in-memory values may have types, which encoding changes
+	encoded, err := bson.Marshal(recorded)
+	if err != nil {
+		return
+	}
+	log, err := j.Registry.DecodeBSON(encoded)
+	if err != nil {
+		return
+	}
+
+	staged := reflect.New(refTarget.Type().Elem())
+	staged.Elem().Set(refutil.CloneValue(refTarget.Elem()))
+
+	err = mttorlog.Replay(ctx, j.Engine, j.Registry, log, staged.Interface())
+	if err != nil {
+		return
+	}
+
+	event := Event{
+		StreamID: streamID,
+		Version:  version + 1,
+		Log:      *log,
+	}
+	err = j.Store.Append(ctx, streamID, version, []Event{event})
+	if err != nil {
+		return
+	}
+
+	refTarget.Elem().Set(staged.Elem())
+	newVersion = event.Version
+
+	if j.SnapshotEvery != 0 && newVersion%j.SnapshotEvery == 0 {
+		err = j.Snapshot(ctx, streamID, newVersion, target)
+		if err != nil {
+			err = fmt.Errorf("%w: %s", ErrSnapshotFailed, err)
+		}
+	}
+	return
+}
+
+// Stores snapshot of target, which is entity of stream at given version.
+func (j *Journal) Snapshot(ctx context.Context, streamID string, version uint64, target interface{}) (err error) {
+	state, err := bson.Marshal(target)
+	if err != nil {
+		return
+	}
+
+	return j.Store.SaveSnapshot(ctx, Snapshot{
+		StreamID: streamID,
+		Version:  version,
+		State:    state,
+	})
+}
+
+// Restores target from latest snapshot and events of stream, which follow it.
+// Returns version of restored entity, which is zero for streams without events.
+// Target is left intact, if it can't be restored.
+func (j *Journal) Rebuild(ctx context.Context, streamID string, target interface{}) (version uint64, err error) {
+	refTarget, err := checkTarget(target)
+	if err != nil {
+		return
+	}
+
+	staged := reflect.New(refTarget.Type().Elem())
+	version, err = j.rebuild(ctx, streamID, staged.Interface())
+	if err != nil {
+		version = 0
+		return
+	}
+
+	refTarget.Elem().Set(staged.Elem())
+	return
+}
+
+func (j *Journal) rebuild(ctx context.Context, streamID string, target interface{}) (version uint64, err error) {
+	snapshot, err := j.Store.LoadSnapshot(ctx, streamID)
+	if err != nil {
+		return
+	}
+	if snapshot != nil {
+		err = bson.Unmarshal(snapshot.State, target)
+		if err != nil {
+			return
+		}
+		version = snapshot.Version
+	}
+
+	events, err := j.Store.Load(ctx, streamID, version)
+	if err != nil {
+		return
+	}
+
+	err = checkEvents(streamID, version, events)
+	if err != nil {
+		return
+	}
+
+	for _, e := range events {
+		log := e.Log
+		err = mttorlog.Replay(ctx, j.Engine, j.Registry, &log, target)
+		if err != nil {
+			return
+		}
+		version = e.Version
+	}
+	return
+}
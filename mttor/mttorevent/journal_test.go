@@ -0,0 +1,273 @@
+package mttorevent_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/teawithsand/arcah/mttor"
+	"github.com/teawithsand/arcah/mttor/mttorevent"
+	"github.com/teawithsand/arcah/mttor/mttorlog"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type Account struct {
+	Owner   string
+	Balance int64
+	History []int64
+	Opened  time.Time
+}
+
+type Deposit struct {
+	Amount  int64 `mttor:"Balance,inc"`
+	History int64 `mttor:"History,push"`
+}
+
+type Rename struct {
+	Owner string
+}
+
+type Open struct {
+	Opened time.Time
+}
+
+// faultyStore fails storing snapshots and loading events.
+type faultyStore struct {
+	mttorevent.MemoryStore
+}
+
+var errFault = errors.New("fault")
+
+func (s *faultyStore) SaveSnapshot(ctx context.Context, snapshot mttorevent.Snapshot) (err error) {
+	return errFault
+}
+
+func (s *faultyStore) Load(ctx context.Context, streamID string, afterVersion uint64) (events []mttorevent.Event, err error) {
+	err = errFault
+	return
+}
+
+// encodingStore stores events encoded as BSON, like mongo does.
+type encodingStore struct {
+	mttorevent.MemoryStore
+}
+
+func (s *encodingStore) Append(ctx context.Context, streamID string, expectedVersion uint64, events []mttorevent.Event) (err error) {
+	decoded := make([]mttorevent.Event, len(events))
+	for i, e := range events {
+		var raw []byte
+		raw, err = bson.Marshal(e)
+		if err != nil {
+			return
+		}
+
+		err = bson.Unmarshal(raw, &decoded[i])
+		if err != nil {
+			return
+		}
+	}
+	return s.MemoryStore.Append(ctx, streamID, expectedVersion, decoded)
+}
+
+func newJournal(t *testing.T) *mttorevent.Journal {
+	registry := &mttorlog.Registry{}
+	err := registry.Register("account", Account{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &mttorevent.Journal{
+		Engine:        mttor.NewDefaultEngine().(mttorevent.Engine),
+		Registry:      registry,
+		Store:         &mttorevent.MemoryStore{},
+		SnapshotEvery: 2,
+	}
+}
+
+func TestJournal(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("rebuild", func(t *testing.T) {
+		journal := newJournal(t)
+
+		var account Account
+		var version uint64
+		mutations := []interface{}{
+			Rename{Owner: "asdf"},
+			Deposit{Amount: 10, History: 10},
+			Deposit{Amount: 5, History: 5},
+		}
+		for _, m := range mutations {
+			var err error
+			version, err = journal.Mutate(ctx, "a", version, &account, m)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+
+		expected := Account{Owner: "asdf", Balance: 15, History: []int64{10, 5}}
+		if version != 3 || !reflect.DeepEqual(account, expected) {
+			t.Error("invalid account", version, account)
+			return
+		}
+
+		snapshot, err := journal.Store.LoadSnapshot(ctx, "a")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if snapshot == nil || snapshot.Version != 2 {
+			t.Error("invalid snapshot", snapshot)
+			return
+		}
+
+		rebuilt := Account{Owner: "garbage"}
+		version, err = journal.Rebuild(ctx, "a", &rebuilt)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if version != 3 || !reflect.DeepEqual(rebuilt, expected) {
+			t.Error("invalid rebuilt account", version, rebuilt)
+			return
+		}
+	})
+
+	t.Run("decoded_values", func(t *testing.T) {
+		journal := newJournal(t)
+		journal.Store = &encodingStore{}
+
+		var account Account
+		// BSON keeps milliseconds only
+		_, err := journal.Mutate(ctx, "a", 0, &account, Open{
+			Opened: time.Date(2020, 1, 2, 3, 4, 5, 123456789, time.UTC),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		var rebuilt Account
+		_, err = journal.Rebuild(ctx, "a", &rebuilt)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if !reflect.DeepEqual(account, rebuilt) {
+			t.Error("mutated account differs from rebuilt one", account, rebuilt)
+			return
+		}
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		journal := newJournal(t)
+
+		var account Account
+		_, err := journal.Mutate(ctx, "a", 0, &account, Rename{Owner: "asdf"})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		stale := Account{}
+		_, err = journal.Mutate(ctx, "a", 0, &stale, Rename{Owner: "fdsa"})
+		if !errors.Is(err, mttorevent.ErrVersionConflict) {
+			t.Error("expected version conflict, got", err)
+			return
+		}
+
+		if stale.Owner != "" {
+			t.Error("target was mutated", stale)
+			return
+		}
+	})
+}
+
+func TestJournal_Faults(t *testing.T) {
+	ctx := context.Background()
+	journal := newJournal(t)
+	journal.Store = &faultyStore{}
+	journal.SnapshotEvery = 1
+
+	var account Account
+	version, err := journal.Mutate(ctx, "a", 0, &account, Rename{Owner: "asdf"})
+	if !errors.Is(err, mttorevent.ErrSnapshotFailed) {
+		t.Error("expected snapshot error, got", err)
+		return
+	}
+	if version != 1 || account.Owner != "asdf" {
+		t.Error("appended mutation wasn't applied", version, account)
+		return
+	}
+
+	rebuilt := Account{Owner: "fdsa"}
+	_, err = journal.Rebuild(ctx, "a", &rebuilt)
+	if !errors.Is(err, errFault) {
+		t.Error("expected fault, got", err)
+		return
+	}
+	if rebuilt.Owner != "fdsa" {
+		t.Error("target was changed", rebuilt)
+		return
+	}
+}
+
+func TestRenderMongoAppend(t *testing.T) {
+	ctx := context.Background()
+	journal := newJournal(t)
+
+	var account Account
+	_, err := journal.Mutate(ctx, "a", 0, &account, Deposit{Amount: 1 << 40, History: 1})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	events, err := journal.Store.Load(ctx, "a", 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	docs, err := mttorevent.RenderMongoAppend("a", 0, events)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	raw, err := bson.Marshal(docs[0])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var decoded mttorevent.Event
+	err = bson.Unmarshal(raw, &decoded)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var replayed Account
+	err = mttorlog.Replay(ctx, journal.Engine, journal.Registry, &decoded.Log, &replayed)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if decoded.Version != 1 || !reflect.DeepEqual(replayed, account) {
+		t.Error("invalid decoded event", decoded, replayed)
+		return
+	}
+
+	_, err = mttorevent.RenderMongoAppend("a", 1, events)
+	if !errors.Is(err, mttorevent.ErrCorruptStream) {
+		t.Error("expected corrupt stream, got", err)
+		return
+	}
+}
@@ -0,0 +1,46 @@
+package mttorevent
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Returns ID of document of event in mongo collection.
+func eventDocumentID(streamID string, version uint64) bson.D {
+	return bson.D{
+		{Key: "stream", Value: streamID},
+		{Key: "version", Value: int64(version)},
+	}
+}
+
+// Returns documents, which append events with InsertMany.
+//
+// IDs of documents consist of stream ID and version, so appending event with version, which already exists,
+// fails with duplicate key error. Such errors should be reported as ErrVersionConflict.
+func RenderMongoAppend(streamID string, expectedVersion uint64, events []Event) (docs []interface{}, err error) {
+	err = checkEvents(streamID, expectedVersion, events)
+	if err != nil {
+		return
+	}
+
+	docs = make([]interface{}, 0, len(events))
+	for _, e := range events {
+		docs = append(docs, bson.D{
+			{Key: "_id", Value: eventDocumentID(e.StreamID, e.Version)},
+			{Key: "streamId", Value: e.StreamID},
+			{Key: "version", Value: int64(e.Version)},
+			{Key: "log", Value: e.Log},
+		})
+	}
+	return
+}
+
+// Returns filter and sort of Find, which loads events of stream with versions greater than afterVersion.
+// Found documents may be decoded as Event.
+func RenderMongoLoad(streamID string, afterVersion uint64) (filter, sort bson.D) {
+	filter = bson.D{
+		{Key: "streamId", Value: streamID},
+		{Key: "version", Value: bson.D{{Key: "$gt", Value: int64(afterVersion)}}},
+	}
+	sort = bson.D{{Key: "version", Value: 1}}
+	return
+}
@@ -0,0 +1,130 @@
+// Package mttorevent stores history of entities as streams of mutation events and rebuilds entities by replaying them.
+//
+// Each mutation applied with Journal is recorded as mttorlog.Log and appended to stream of entity
+// with next version. Snapshots of entity may be stored every few events, so that rebuilding does not
+// replay whole stream.
+package mttorevent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/teawithsand/arcah/mttor/mttorlog"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var ErrVersionConflict = errors.New("arcah/mttorevent: stream was changed concurrently")
+var ErrCorruptStream = errors.New("arcah/mttorevent: versions of events of stream are not contiguous")
+
+// Event is single mutation of entity.
+type Event struct {
+	StreamID string `json:"streamId" bson:"streamId"`
+	// Version of entity after event is applied, versions of stream start with 1.
+	Version uint64       `json:"version" bson:"version"`
+	Log     mttorlog.Log `json:"log" bson:"log"`
+}
+
+// Snapshot is state of entity at given version, encoded as BSON document.
+type Snapshot struct {
+	StreamID string   `json:"streamId" bson:"streamId"`
+	Version  uint64   `json:"version" bson:"version"`
+	State    bson.Raw `json:"state" bson:"state"`
+}
+
+// Store persists streams of events and snapshots of their entities.
+type Store interface {
+	// Appends events to stream, which must have version expectedVersion before append.
+	// Versions of events must follow expectedVersion. Returns ErrVersionConflict if stream has other version.
+	Append(ctx context.Context, streamID string, expectedVersion uint64, events []Event) (err error)
+
+	// Returns events of stream with versions greater than afterVersion, in order of versions.
+	Load(ctx context.Context, streamID string, afterVersion uint64) (events []Event, err error)
+
+	// Stores snapshot, replacing older ones of same stream.
+	SaveSnapshot(ctx context.Context, snapshot Snapshot) (err error)
+
+	// Returns latest snapshot of stream or nil, if there is none.
+	LoadSnapshot(ctx context.Context, streamID string) (snapshot *Snapshot, err error)
+}
+
+// Checks, that versions of events follow version of stream and all events belong to it.
+func checkEvents(streamID string, version uint64, events []Event) (err error) {
+	for i, e := range events {
+		if e.StreamID != streamID || e.Version != version+uint64(i)+1 {
+			err = fmt.Errorf("%w: event %d of stream %s has version %d", ErrCorruptStream, i, e.StreamID, e.Version)
+			return
+		}
+	}
+	return
+}
+
+// MemoryStore is Store, which keeps events in memory. It's meant for tests.
+// Zero value is ready to use.
+type MemoryStore struct {
+	lock      sync.Mutex
+	streams   map[string][]Event
+	snapshots map[string]Snapshot
+}
+
+func (ms *MemoryStore) Append(ctx context.Context, streamID string, expectedVersion uint64, events []Event) (err error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	stream := ms.streams[streamID]
+	if uint64(len(stream)) != expectedVersion {
+		err = fmt.Errorf("%w: stream %s has version %d, expected %d", ErrVersionConflict, streamID, len(stream), expectedVersion)
+		return
+	}
+
+	err = checkEvents(streamID, expectedVersion, events)
+	if err != nil {
+		return
+	}
+
+	if ms.streams == nil {
+		ms.streams = map[string][]Event{}
+	}
+	ms.streams[streamID] = append(stream, events...)
+	return
+}
+
+func (ms *MemoryStore) Load(ctx context.Context, streamID string, afterVersion uint64) (events []Event, err error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	stream := ms.streams[streamID]
+	if afterVersion >= uint64(len(stream)) {
+		return
+	}
+
+	events = append([]Event(nil), stream[afterVersion:]...)
+	return
+}
+
+func (ms *MemoryStore) SaveSnapshot(ctx context.Context, snapshot Snapshot) (err error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	if ms.snapshots == nil {
+		ms.snapshots = map[string]Snapshot{}
+	}
+
+	snapshot.State = append(bson.Raw(nil), snapshot.State...)
+	ms.snapshots[snapshot.StreamID] = snapshot
+	return
+}
+
+func (ms *MemoryStore) LoadSnapshot(ctx context.Context, streamID string) (snapshot *Snapshot, err error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	s, ok := ms.snapshots[streamID]
+	if !ok {
+		return
+	}
+
+	snapshot = &s
+	return
+}
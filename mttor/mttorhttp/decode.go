@@ -0,0 +1,163 @@
+package mttorhttp
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/teawithsand/arcah/jsonschema"
+)
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// Decodes JSON body into value pointed by dst.
+// Unlike json.Decoder with DisallowUnknownFields, all unknown fields are reported along with their paths.
+func decodeJSON(body []byte, dst interface{}) (problem *Problem) {
+	var tree interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	err := decoder.Decode(&tree)
+	if err == nil && decoder.More() {
+		err = errors.New("unexpected data after JSON value")
+	}
+	if err != nil {
+		return newProblem(http.StatusBadRequest, "Request body is not valid JSON: "+err.Error())
+	}
+
+	var unknown []string
+	collectUnknownFields(tree, reflect.TypeOf(dst), "", &unknown)
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fieldsProblem(http.StatusBadRequest, "Request body contains unknown fields", unknown, "unknown field")
+	}
+
+	err = json.Unmarshal(body, dst)
+
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		p := newProblem(http.StatusBadRequest, "Request body contains fields of invalid types")
+		p.InvalidParams = []InvalidParam{
+			{
+				Name:   typeErr.Field,
+				Reason: fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value),
+			},
+		}
+		return p
+	case err != nil:
+		return newProblem(http.StatusBadRequest, "Request body can't be decoded: "+err.Error())
+	}
+	return
+}
+
+func joinPath(path, key string) string {
+	if len(path) == 0 {
+		return key
+	}
+	return path + "." + key
+}
+
+// Appends paths of fields present in JSON tree, which value of given type does not have.
+func collectUnknownFields(tree interface{}, ty reflect.Type, path string, unknown *[]string) {
+	for ty.Kind() == reflect.Ptr {
+		if ty.Implements(jsonUnmarshalerType) || ty.Implements(textUnmarshalerType) {
+			return
+		}
+		ty = ty.Elem()
+	}
+
+	if reflect.PtrTo(ty).Implements(jsonUnmarshalerType) || reflect.PtrTo(ty).Implements(textUnmarshalerType) {
+		return
+	}
+
+	switch ty.Kind() {
+	case reflect.Struct:
+		object, ok := tree.(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		fields := jsonschema.JSONFields(ty)
+		for key, value := range object {
+			f, ok := findJSONField(fields, key)
+			if !ok {
+				*unknown = append(*unknown, joinPath(path, key))
+				continue
+			}
+			collectUnknownFields(value, f.Field.Type, joinPath(path, key), unknown)
+		}
+	case reflect.Map:
+		object, ok := tree.(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		for key, value := range object {
+			collectUnknownFields(value, ty.Elem(), joinPath(path, key), unknown)
+		}
+	case reflect.Slice, reflect.Array:
+		array, ok := tree.([]interface{})
+		if !ok {
+			return
+		}
+
+		for i, value := range array {
+			collectUnknownFields(value, ty.Elem(), fmt.Sprintf("%s[%d]", path, i), unknown)
+		}
+	}
+}
+
+// Finds field with given name, preferring exact match, like encoding/json does.
+func findJSONField(fields []jsonschema.JSONField, key string) (res jsonschema.JSONField, ok bool) {
+	for _, f := range fields {
+		if f.Name == key {
+			return f, true
+		}
+	}
+
+	for _, f := range fields {
+		if strings.EqualFold(f.Name, key) {
+			return f, true
+		}
+	}
+	return
+}
+
+// Converts dotted path of go fields, like one of validation.FieldError, to path of field in JSON body.
+func jsonPath(ty reflect.Type, path string) (res string) {
+	for _, name := range strings.Split(path, ".") {
+		for ty.Kind() == reflect.Ptr {
+			ty = ty.Elem()
+		}
+
+		if ty.Kind() != reflect.Struct {
+			return joinPath(res, name)
+		}
+		sf, ok := ty.FieldByName(name)
+		if !ok {
+			return joinPath(res, name)
+		}
+
+		jsonName := ""
+		for _, f := range jsonschema.JSONFields(ty) {
+			if len(f.Index) == 1 && f.Field.Name == name {
+				jsonName = f.Name
+				break
+			}
+		}
+		ty = sf.Type
+
+		// fields of embedded structures are part of outer object
+		if len(jsonName) > 0 {
+			res = joinPath(res, jsonName)
+		}
+	}
+	return
+}
@@ -0,0 +1,206 @@
+// Package mttorhttp serves HTTP PATCH requests, which carry mutation DTOs encoded as JSON.
+//
+// Errors are sent as RFC 7807 problem details, mapped from mttor error types.
+package mttorhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+
+	"github.com/teawithsand/arcah/mttor"
+	"github.com/teawithsand/arcah/validation"
+	"github.com/teawithsand/reval"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Default limit of size of request body.
+const DefaultMaxBodySize = 1 << 20
+
+// Applies mutation decoded from request.
+// Result, if not nil, is encoded as JSON response, otherwise response has no content.
+type ApplyFunc[M any] func(ctx context.Context, r *http.Request, mutation M) (res interface{}, err error)
+
+// Loads target of request. It should return ErrNotFound, if there is no such target.
+type LoadFunc[T any] func(ctx context.Context, r *http.Request) (target *T, err error)
+
+// Stores target, after mutation was applied to it.
+type SaveFunc[T any] func(ctx context.Context, r *http.Request, target *T) (err error)
+
+// Returns filter, which selects document, that request mutates.
+type FilterFunc func(r *http.Request) (filter interface{}, err error)
+
+// Collection, which mongo handler updates documents in. It's implemented by *mongo.Collection.
+type Collection interface {
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+}
+
+// Handler decodes PATCH requests into mutations of type M and applies them.
+type Handler[M any] struct {
+	Apply ApplyFunc[M]
+
+	// Limit of size of request body, defaults to DefaultMaxBodySize.
+	MaxBodySize int64
+
+	// Validates decoded mutation, before it's applied.
+	// Defaults to validation.DefaultValidator, so rules of valid tags of M are checked.
+	Validator mttor.TargetValidator
+}
+
+// NewHandler returns handler, which loads target, applies mutation to it with engine and saves it.
+// Target is sent as response.
+func NewHandler[T, M any](engine mttor.Engine, load LoadFunc[T], save SaveFunc[T]) *Handler[M] {
+	return &Handler[M]{
+		Apply: func(ctx context.Context, r *http.Request, mutation M) (res interface{}, err error) {
+			target, err := load(ctx, r)
+			if err != nil {
+				return
+			}
+
+			err = mttor.Apply(ctx, engine, target, mutation)
+			if err != nil {
+				return
+			}
+
+			err = save(ctx, r, target)
+			if err != nil {
+				return
+			}
+
+			res = target
+			return
+		},
+	}
+}
+
+// NewMongoHandler returns handler, which renders mutation as update of documents of type T
+// and applies it to single document of collection, selected by filter.
+func NewMongoHandler[T, M any](engine mttor.MongoEngine, collection Collection, filter FilterFunc) *Handler[M] {
+	return &Handler[M]{
+		Apply: func(ctx context.Context, r *http.Request, mutation M) (res interface{}, err error) {
+			f, err := filter(r)
+			if err != nil {
+				return
+			}
+
+			update, err := mttor.RenderFor[T](ctx, engine, mutation)
+			if err != nil {
+				return
+			}
+
+			// mongo rejects empty updates, but there is nothing to do anyway
+			if d, ok := update.(bson.D); ok && len(d) == 0 {
+				return
+			}
+
+			updateRes, err := collection.UpdateOne(ctx, f, update)
+			if err != nil {
+				return
+			}
+
+			if updateRes.MatchedCount == 0 {
+				err = ErrNotFound
+				return
+			}
+			return
+		},
+	}
+}
+
+func (h *Handler[M]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		w.Header().Set("Allow", http.MethodPatch)
+		WriteProblem(w, newProblem(http.StatusMethodNotAllowed, ""))
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "application/json" && mediaType != "application/merge-patch+json") {
+		WriteProblem(w, newProblem(http.StatusUnsupportedMediaType, "Request body must be JSON"))
+		return
+	}
+
+	maxBodySize := h.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+
+	// single byte more than limit is read to find out, whether body exceeds it
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		WriteProblem(w, newProblem(http.StatusBadRequest, "Request body can't be read"))
+		return
+	}
+	if int64(len(body)) > maxBodySize {
+		WriteProblem(w, newProblem(http.StatusRequestEntityTooLarge, ""))
+		return
+	}
+
+	var mutation M
+	problem := decodeJSON(body, &mutation)
+	if problem != nil {
+		WriteProblem(w, problem)
+		return
+	}
+
+	problem = h.validate(r.Context(), &mutation)
+	if problem != nil {
+		WriteProblem(w, problem)
+		return
+	}
+
+	res, err := h.Apply(r.Context(), r, mutation)
+	if err != nil {
+		errProblem := *ProblemFromError(err)
+		if len(errProblem.Instance) == 0 {
+			errProblem.Instance = r.URL.Path
+		}
+		WriteProblem(w, &errProblem)
+		return
+	}
+
+	if res == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func (h *Handler[M]) validate(ctx context.Context, mutation *M) (problem *Problem) {
+	validator := h.Validator
+	if validator == nil {
+		validator = validation.DefaultValidator
+	}
+
+	wrapped, err := (&reval.DefaultWrapper{}).Wrap(mutation)
+	if err != nil {
+		return ProblemFromError(err)
+	}
+
+	err = validator.ValidateTarget(ctx, wrapped)
+	if err == nil {
+		return
+	}
+
+	var validationErr *validation.Error
+	if !errors.As(err, &validationErr) {
+		return ProblemFromError(&mttor.ValidationError{Err: err})
+	}
+
+	problem = newProblem(http.StatusUnprocessableEntity, "Request body violates validation rules")
+	for _, f := range validationErr.Fields {
+		problem.InvalidParams = append(problem.InvalidParams, InvalidParam{
+			Name:   jsonPath(reflect.TypeOf(mutation), f.Path),
+			Reason: "field violates rule " + f.Rule,
+		})
+	}
+	return
+}
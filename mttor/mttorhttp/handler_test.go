@@ -0,0 +1,279 @@
+package mttorhttp_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/teawithsand/arcah/mttor"
+	"github.com/teawithsand/arcah/mttor/mttorhttp"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Address struct {
+	Street string `json:"street"`
+}
+
+type User struct {
+	ID       string `mttor:",readonly"`
+	Name     string
+	Visits   int64
+	Address  Address
+	Password string `roles:"admin"`
+}
+
+type UserPatch struct {
+	Name    string  `json:"name" mttor:",,omitempty"`
+	Visits  int64   `json:"visits" mttor:",inc,omitempty"`
+	Address Address `json:"address" mttor:",set"`
+}
+
+type UserChangeID struct {
+	ID string `json:"id"`
+}
+
+type ValidatedAddress struct {
+	Street string `json:"street" valid:"required"`
+}
+
+type UserValidatedPatch struct {
+	Name    string           `json:"name" mttor:",,omitempty" valid:"max:3"`
+	Address ValidatedAddress `json:"address" mttor:",set"`
+}
+
+type UserRename struct {
+	Name int `json:"name"`
+}
+
+type UserChangePassword struct {
+	Password string `json:"password"`
+}
+
+type fakeCollection struct {
+	Filter  interface{}
+	Update  interface{}
+	Matched int64
+}
+
+func (c *fakeCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	c.Filter, c.Update = filter, update
+	return &mongo.UpdateResult{MatchedCount: c.Matched}, nil
+}
+
+func doRequest(handler http.Handler, method, contentType, body string) (res *httptest.ResponseRecorder, problem mttorhttp.Problem) {
+	req := httptest.NewRequest(method, "/users/1", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+
+	res = httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if res.Header().Get("Content-Type") == mttorhttp.ProblemContentType {
+		json.Unmarshal(res.Body.Bytes(), &problem)
+	}
+	return
+}
+
+func newUserHandler[M any](users map[string]*User, engine mttor.Engine) *mttorhttp.Handler[M] {
+	return mttorhttp.NewHandler[User, M](
+		engine,
+		func(ctx context.Context, r *http.Request) (target *User, err error) {
+			target, ok := users[strings.TrimPrefix(r.URL.Path, "/users/")]
+			if !ok {
+				err = mttorhttp.ErrNotFound
+			}
+			return
+		},
+		func(ctx context.Context, r *http.Request, target *User) (err error) {
+			return
+		},
+	)
+}
+
+func TestHandler(t *testing.T) {
+	engine := mttor.NewEngine(mttor.EngineOptions{
+		PermissionChecker: mttor.RolesPermissionChecker,
+	})
+
+	limited := newUserHandler[UserPatch](map[string]*User{"1": {}}, engine)
+	limited.MaxBodySize = 8
+
+	t.Run("patch", func(t *testing.T) {
+		users := map[string]*User{"1": {ID: "1", Name: "a", Visits: 1}}
+		res, _ := doRequest(newUserHandler[UserPatch](users, engine), http.MethodPatch, "application/json", `{"name": "b", "visits": 2, "address": {"street": "s"}}`)
+		if res.Code != http.StatusOK {
+			t.Error("invalid status", res.Code, res.Body.String())
+			return
+		}
+
+		expected := User{ID: "1", Name: "b", Visits: 3, Address: Address{Street: "s"}}
+		if !reflect.DeepEqual(*users["1"], expected) {
+			t.Error("invalid mutation result", users["1"])
+			return
+		}
+	})
+
+	for _, tc := range []struct {
+		Name        string
+		Handler     http.Handler
+		Method      string
+		ContentType string
+		Body        string
+
+		Status        int
+		InvalidParams []string
+	}{
+		{
+			Name:    "unknown_fields",
+			Handler: newUserHandler[UserPatch](map[string]*User{"1": {}}, engine),
+			Body:    `{"name": "b", "nmae": "c", "address": {"street": "s", "city": "c"}}`,
+			Status:  http.StatusBadRequest, InvalidParams: []string{"address.city", "nmae"},
+		},
+		{
+			Name:    "invalid_type",
+			Handler: newUserHandler[UserPatch](map[string]*User{"1": {}}, engine),
+			Body:    `{"visits": "many"}`,
+			Status:  http.StatusBadRequest, InvalidParams: []string{"visits"},
+		},
+		{
+			Name:    "malformed",
+			Handler: newUserHandler[UserPatch](map[string]*User{"1": {}}, engine),
+			Body:    `{"name": `,
+			Status:  http.StatusBadRequest,
+		},
+		{
+			Name:    "not_found",
+			Handler: newUserHandler[UserPatch](map[string]*User{}, engine),
+			Body:    `{"name": "b"}`,
+			Status:  http.StatusNotFound,
+		},
+		{
+			Name:    "readonly",
+			Handler: newUserHandler[UserChangeID](map[string]*User{"1": {}}, engine),
+			Body:    `{"id": "2"}`,
+			Status:  http.StatusUnprocessableEntity, InvalidParams: []string{"ID"},
+		},
+		{
+			Name:    "forbidden",
+			Handler: newUserHandler[UserChangePassword](map[string]*User{"1": {}}, engine),
+			Body:    `{"password": "p"}`,
+			Status:  http.StatusForbidden, InvalidParams: []string{"Password"},
+		},
+		{
+			// mutation, which does not match target, is mistake of server
+			Name:    "invalid_mutation",
+			Handler: newUserHandler[UserRename](map[string]*User{"1": {}}, engine),
+			Body:    `{"name": 1}`,
+			Status:  http.StatusInternalServerError,
+		},
+		{
+			Name:    "invalid_body",
+			Handler: newUserHandler[UserValidatedPatch](map[string]*User{"1": {}}, engine),
+			Body:    `{"name": "abcd", "address": {}}`,
+			Status:  http.StatusUnprocessableEntity, InvalidParams: []string{"name", "address.street"},
+		},
+		{
+			Name:    "too_large",
+			Handler: limited,
+			Body:    `{"name": "b"}`,
+			Status:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			Name:    "method",
+			Handler: newUserHandler[UserPatch](map[string]*User{"1": {}}, engine),
+			Method:  http.MethodPost,
+			Body:    `{}`,
+			Status:  http.StatusMethodNotAllowed,
+		},
+		{
+			Name:        "content_type",
+			Handler:     newUserHandler[UserPatch](map[string]*User{"1": {}}, engine),
+			ContentType: "text/plain",
+			Body:        `{}`,
+			Status:      http.StatusUnsupportedMediaType,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			method := tc.Method
+			if len(method) == 0 {
+				method = http.MethodPatch
+			}
+			contentType := tc.ContentType
+			if len(contentType) == 0 {
+				contentType = "application/json"
+			}
+
+			res, problem := doRequest(tc.Handler, method, contentType, tc.Body)
+			if res.Code != tc.Status || problem.Status != tc.Status {
+				t.Error("invalid status", res.Code, res.Body.String())
+				return
+			}
+
+			var invalidParams []string
+			for _, p := range problem.InvalidParams {
+				invalidParams = append(invalidParams, p.Name)
+			}
+			if !reflect.DeepEqual(invalidParams, tc.InvalidParams) {
+				t.Error("invalid params", invalidParams)
+				return
+			}
+		})
+	}
+}
+
+func TestMongoHandler(t *testing.T) {
+	filter := func(r *http.Request) (interface{}, error) {
+		return bson.D{{Key: "_id", Value: strings.TrimPrefix(r.URL.Path, "/users/")}}, nil
+	}
+
+	t.Run("update", func(t *testing.T) {
+		collection := &fakeCollection{Matched: 1}
+		handler := mttorhttp.NewMongoHandler[User, UserPatch](mttor.NewMongoEngine(), collection, filter)
+
+		res, _ := doRequest(handler, http.MethodPatch, "application/json", `{"visits": 2}`)
+		if res.Code != http.StatusNoContent {
+			t.Error("invalid status", res.Code, res.Body.String())
+			return
+		}
+
+		expected := bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "visits", Value: int64(2)}}},
+			{Key: "$set", Value: bson.D{{Key: "address", Value: Address{}}}},
+		}
+		if !reflect.DeepEqual(collection.Update, expected) {
+			t.Error("invalid update", collection.Update)
+			return
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		collection := &fakeCollection{Matched: 1}
+		handler := mttorhttp.NewMongoHandler[User, UserValidatedPatch](mttor.NewMongoEngine(), collection, filter)
+
+		res, problem := doRequest(handler, http.MethodPatch, "application/json", `{"address": {}}`)
+		if res.Code != http.StatusUnprocessableEntity || len(problem.InvalidParams) != 1 || problem.InvalidParams[0].Name != "address.street" {
+			t.Error("invalid response", res.Code, res.Body.String())
+			return
+		}
+
+		if collection.Update != nil {
+			t.Error("invalid mutation was applied", collection.Update)
+			return
+		}
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		handler := mttorhttp.NewMongoHandler[User, UserPatch](mttor.NewMongoEngine(), &fakeCollection{}, filter)
+
+		res, _ := doRequest(handler, http.MethodPatch, "application/json", `{"visits": 2}`)
+		if res.Code != http.StatusNotFound {
+			t.Error("invalid status", res.Code, res.Body.String())
+			return
+		}
+	})
+}
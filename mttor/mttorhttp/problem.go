@@ -0,0 +1,106 @@
+package mttorhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/teawithsand/arcah/mttor"
+)
+
+// Returned by LoadFunc, when target of request does not exist.
+var ErrNotFound = errors.New("arcah/mttorhttp: target not found")
+
+const ProblemContentType = "application/problem+json"
+
+// Problem is RFC 7807 problem details object.
+// It's error, so it may be returned from functions passed to handler to send custom response.
+type Problem struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Fields of request, which caused problem.
+	InvalidParams []InvalidParam `json:"invalid-params,omitempty"`
+}
+
+// Single field of request, which is not valid.
+type InvalidParam struct {
+	// Path of field in request body, like "address.street" or "tags[1]".
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+func (p *Problem) Error() string {
+	if p == nil {
+		return "<nil>"
+	}
+
+	if len(p.Detail) > 0 {
+		return "arcah/mttorhttp: " + p.Title + ": " + p.Detail
+	}
+	return "arcah/mttorhttp: " + p.Title
+}
+
+func newProblem(status int, detail string) *Problem {
+	return &Problem{
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func fieldsProblem(status int, detail string, fields []string, reason string) *Problem {
+	p := newProblem(status, detail)
+	for _, f := range fields {
+		p.InvalidParams = append(p.InvalidParams, InvalidParam{
+			Name:   f,
+			Reason: reason,
+		})
+	}
+	return p
+}
+
+// ProblemFromError maps errors returned by engine and handler functions to problems.
+// Only errors caused by client, like readonly, permission and validation errors, are mapped to client errors.
+// Other errors, including mttor.Error, which is caused by invalid mutation types or tags, are mapped to internal server error
+// without details, so they are not leaked to client.
+func ProblemFromError(err error) *Problem {
+	var problem *Problem
+	var readonlyErr *mttor.ReadonlyFieldError
+	var permissionErr *mttor.PermissionError
+	var validationErr *mttor.ValidationError
+	var checkErr *mttor.CheckError
+
+	switch {
+	case errors.As(err, &problem):
+		return problem
+	case errors.Is(err, ErrNotFound):
+		return newProblem(http.StatusNotFound, "")
+	case errors.As(err, &readonlyErr):
+		p := fieldsProblem(http.StatusUnprocessableEntity, "Mutation changes readonly fields", readonlyErr.Fields, "")
+		for i := range p.InvalidParams {
+			p.InvalidParams[i].Reason = "field is " + readonlyErr.Flags[i]
+		}
+		return p
+	case errors.As(err, &permissionErr):
+		return fieldsProblem(http.StatusForbidden, "Writing some fields is forbidden", permissionErr.Fields, "field can't be written")
+	case errors.As(err, &validationErr):
+		return newProblem(http.StatusUnprocessableEntity, validationErr.Err.Error())
+	case errors.Is(err, mttor.ErrEmptyMutation):
+		return newProblem(http.StatusBadRequest, "Mutation does not change any field")
+	case errors.As(err, &checkErr):
+		return newProblem(http.StatusUnprocessableEntity, strings.Join(checkErr.Problems, "; "))
+	}
+	return newProblem(http.StatusInternalServerError, "")
+}
+
+// WriteProblem writes problem as response.
+func WriteProblem(w http.ResponseWriter, problem *Problem) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
@@ -0,0 +1,100 @@
+// Package mttorlog serializes applied mutations, so they can be queued and replayed later, possibly by another service.
+//
+// Log holds ID of target type and operations of mutation with names of target fields, mutations, args and values.
+// Its JSON and BSON encodings are stable, so logs written by one version of program may be replayed by another one,
+// as long as target fields with given names exist.
+package mttorlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/teawithsand/arcah/mttor"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Version of log format, which is written to logs.
+const FormatVersion = 1
+
+var ErrUnsupportedVersion = errors.New("arcah/mttorlog: unsupported log format version")
+var ErrDuplicateOperation = errors.New("arcah/mttorlog: operation on same field with same mutation and args found twice")
+
+// Log is mutation of target, which was recorded to be applied later.
+type Log struct {
+	Version int `json:"v" bson:"v"`
+
+	// ID of type of target, assigned with Registry.
+	TargetType string      `json:"targetType" bson:"targetType"`
+	Operations []Operation `json:"operations" bson:"operations"`
+}
+
+// Operation is single field of recorded mutation.
+type Operation struct {
+	// Name, which mutations use to refer to target field.
+	Field string `json:"field" bson:"field"`
+	// Dotted path of target field in BSON document at time of recording.
+	// It's informational, field is resolved by its name on replay.
+	Path string `json:"path,omitempty" bson:"path,omitempty"`
+
+	// Name of mutation, empty for set.
+	Op    string              `json:"op,omitempty" bson:"op,omitempty"`
+	Args  map[string][]string `json:"args,omitempty" bson:"args,omitempty"`
+	Value interface{}         `json:"value" bson:"value"`
+}
+
+type plainOperation Operation
+
+// Decodes operation, keeping numbers as json.Number, so they are converted to types of target fields exactly.
+func (op *Operation) UnmarshalJSON(data []byte) (err error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode((*plainOperation)(op))
+}
+
+// Decodes operation, decoding embedded documents of value as bson.M,
+// so they can be converted to structures of target fields.
+func (op *Operation) UnmarshalBSON(data []byte) (err error) {
+	err = bson.Unmarshal(data, (*plainOperation)(op))
+	if err != nil {
+		return
+	}
+
+	var fields bson.M
+	err = bson.Unmarshal(data, &fields)
+	if err != nil {
+		return
+	}
+
+	op.Value = fields["value"]
+	return
+}
+
+// Returns map mutation, which applies operations of log.
+func (log *Log) Mutation() (mutation mttor.MapMutation, err error) {
+	mutation = mttor.MapMutation{}
+	for _, op := range log.Operations {
+		recorded := mttor.RecordedOperation{
+			TargetFieldName: op.Field,
+			MutationName:    op.Op,
+			Args:            op.Args,
+		}
+
+		key := recorded.MapKey()
+		if _, ok := mutation[key]; ok {
+			mutation = nil
+			err = fmt.Errorf("%w: %s", ErrDuplicateOperation, key)
+			return
+		}
+		mutation[key] = op.Value
+	}
+	return
+}
+
+func checkVersion(log *Log) (err error) {
+	if log.Version != FormatVersion {
+		err = fmt.Errorf("%w: %d", ErrUnsupportedVersion, log.Version)
+	}
+	return
+}
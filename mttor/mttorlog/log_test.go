@@ -0,0 +1,156 @@
+package mttorlog_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/mttor"
+	"github.com/teawithsand/arcah/mttor/mttorlog"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type Address struct {
+	City string
+}
+
+type User struct {
+	Name    string
+	Visits  int64
+	Tags    []string
+	Address Address `bson:"addr"`
+}
+
+type UserMutation struct {
+	Name    string  `mttor:",,omitempty"`
+	Visits  int64   `mttor:",inc"`
+	Tag     string  `mttor:"Tags,push"`
+	Address Address `mttor:"Address,set"`
+}
+
+type Other struct {
+	Name string
+}
+
+func TestLog(t *testing.T) {
+	ctx := context.Background()
+	engine := mttor.NewDefaultEngine()
+
+	var registry mttorlog.Registry
+	err := registry.Register("user", User{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = registry.Register("other", &Other{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mutation := UserMutation{
+		Visits:  1 << 60,
+		Tag:     "new",
+		Address: Address{City: "Warsaw"},
+	}
+
+	log, err := mttorlog.Record(ctx, engine.(mttor.RecordingEngine), &registry, reflect.TypeOf(User{}), mutation)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(log.Operations) != 3 {
+		t.Error("invalid operations recorded", log.Operations)
+		return
+	}
+
+	expected := User{Name: "asdf", Visits: 1 + 1<<60, Tags: []string{"old", "new"}, Address: Address{City: "Warsaw"}}
+
+	for _, encoding := range []string{"json", "bson"} {
+		t.Run(encoding, func(t *testing.T) {
+			var decoded *mttorlog.Log
+			if encoding == "json" {
+				data, err := json.Marshal(log)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				decoded, err = registry.DecodeJSON(data)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			} else {
+				data, err := bson.Marshal(log)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				decoded, err = registry.DecodeBSON(data)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+
+			user := User{Name: "asdf", Visits: 1, Tags: []string{"old"}}
+			err := mttorlog.Replay(ctx, engine, &registry, decoded, &user)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if !reflect.DeepEqual(user, expected) {
+				t.Error("invalid replayed user", user)
+				return
+			}
+		})
+	}
+
+	t.Run("render", func(t *testing.T) {
+		update, err := mttorlog.RenderMongo(ctx, engine.(mttor.MongoEngine), &registry, log)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expectedUpdate := bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "visits", Value: int64(1 << 60)}}},
+			{Key: "$push", Value: bson.D{{Key: "tags", Value: bson.D{{Key: "$each", Value: []string{"new"}}}}}},
+			{Key: "$set", Value: bson.D{{Key: "addr", Value: Address{City: "Warsaw"}}}},
+		}
+		if !reflect.DeepEqual(update, expectedUpdate) {
+			t.Error("invalid update", update)
+			return
+		}
+	})
+
+	t.Run("type_mismatch", func(t *testing.T) {
+		err := mttorlog.Replay(ctx, engine, &registry, log, &Other{})
+		if !errors.Is(err, mttorlog.ErrTypeMismatch) {
+			t.Error("expected type mismatch, got", err)
+			return
+		}
+	})
+
+	t.Run("expr", func(t *testing.T) {
+		_, err := mttorlog.Record(ctx, engine.(mttor.RecordingEngine), &registry, reflect.TypeOf(User{}), mttor.MapMutation{
+			"Visits,expr": mttor.Add(mttor.Field("Visits"), mttor.Literal(1)),
+		})
+		if !errors.Is(err, mttorlog.ErrUnsupportedValue) {
+			t.Error("expected unsupported value, got", err)
+			return
+		}
+	})
+
+	t.Run("unknown_version", func(t *testing.T) {
+		_, err := registry.DecodeJSON([]byte(`{"v":2,"targetType":"user","operations":[]}`))
+		if !errors.Is(err, mttorlog.ErrUnsupportedVersion) {
+			t.Error("expected unsupported version, got", err)
+			return
+		}
+	})
+}
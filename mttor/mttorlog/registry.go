@@ -0,0 +1,129 @@
+package mttorlog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var ErrUnknownType = errors.New("arcah/mttorlog: type is not registered")
+var ErrAlreadyRegistered = errors.New("arcah/mttorlog: type or ID is already registered")
+
+// Registry assigns stable IDs to target types, so logs do not depend on go type names.
+// Zero value is ready to use. It's safe to use concurrently.
+type Registry struct {
+	lock     sync.RWMutex
+	idToType map[string]reflect.Type
+	typeToID map[reflect.Type]string
+}
+
+func typeOfSample(sample interface{}) reflect.Type {
+	ty := reflect.TypeOf(sample)
+	for ty != nil && ty.Kind() == reflect.Ptr {
+		ty = ty.Elem()
+	}
+	return ty
+}
+
+// Registers type of sample, which may be pointer, under given ID.
+// Each type and each ID may be registered once.
+func (r *Registry) Register(id string, sample interface{}) (err error) {
+	ty := typeOfSample(sample)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.idToType == nil {
+		r.idToType = map[string]reflect.Type{}
+		r.typeToID = map[reflect.Type]string{}
+	}
+
+	if _, ok := r.idToType[id]; ok {
+		err = fmt.Errorf("%w: ID %s", ErrAlreadyRegistered, id)
+		return
+	}
+	if _, ok := r.typeToID[ty]; ok {
+		err = fmt.Errorf("%w: type %s", ErrAlreadyRegistered, ty)
+		return
+	}
+
+	r.idToType[id] = ty
+	r.typeToID[ty] = id
+	return
+}
+
+// Returns ID of given type, which may be pointer.
+func (r *Registry) TypeID(ty reflect.Type) (id string, err error) {
+	for ty != nil && ty.Kind() == reflect.Ptr {
+		ty = ty.Elem()
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	id, ok := r.typeToID[ty]
+	if !ok {
+		err = fmt.Errorf("%w: type %s", ErrUnknownType, ty)
+	}
+	return
+}
+
+// Returns type registered under given ID.
+func (r *Registry) Type(id string) (ty reflect.Type, err error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	ty, ok := r.idToType[id]
+	if !ok {
+		err = fmt.Errorf("%w: ID %s", ErrUnknownType, id)
+	}
+	return
+}
+
+// Decodes log encoded as JSON and checks, that its version is supported and its target type is registered.
+func (r *Registry) DecodeJSON(data []byte) (log *Log, err error) {
+	var res Log
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return
+	}
+
+	err = r.check(&res)
+	if err != nil {
+		return
+	}
+
+	log = &res
+	return
+}
+
+// Decodes log encoded as BSON and checks, that its version is supported and its target type is registered.
+func (r *Registry) DecodeBSON(data []byte) (log *Log, err error) {
+	var res Log
+	err = bson.Unmarshal(data, &res)
+	if err != nil {
+		return
+	}
+
+	err = r.check(&res)
+	if err != nil {
+		return
+	}
+
+	log = &res
+	return
+}
+
+func (r *Registry) check(log *Log) (err error) {
+	err = checkVersion(log)
+	if err != nil {
+		return
+	}
+
+	_, err = r.Type(log.TargetType)
+	return
+}
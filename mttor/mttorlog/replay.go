@@ -0,0 +1,112 @@
+package mttorlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/teawithsand/arcah/mttor"
+)
+
+var ErrTypeMismatch = errors.New("arcah/mttorlog: log was recorded for target of other type")
+var ErrUnsupportedValue = errors.New("arcah/mttorlog: value of operation can't be recorded")
+
+// Records mutation of target of given type, which is registered in registry.
+// Only operations, which would be applied, are recorded; fields omitted due to omitempty are not.
+// Expressions have no stable encoding, so mutations with them can't be recorded.
+func Record(
+	ctx context.Context,
+	engine mttor.RecordingEngine,
+	registry *Registry,
+	targetType reflect.Type,
+	mutation interface{},
+) (log *Log, err error) {
+	id, err := registry.TypeID(targetType)
+	if err != nil {
+		return
+	}
+
+	ops, err := engine.RecordMutation(ctx, targetType, mutation)
+	if err != nil {
+		return
+	}
+
+	log = &Log{
+		Version:    FormatVersion,
+		TargetType: id,
+		Operations: make([]Operation, 0, len(ops)),
+	}
+	for _, op := range ops {
+		if _, ok := op.Value.(mttor.Expr); ok {
+			log = nil
+			err = fmt.Errorf("%w: expression assigned to %s", ErrUnsupportedValue, op.TargetFieldName)
+			return
+		}
+
+		log.Operations = append(log.Operations, Operation{
+			Field: op.TargetFieldName,
+			Path:  op.BSONPath,
+			Op:    op.MutationName,
+			Args:  op.Args,
+			Value: op.Value,
+		})
+	}
+	return
+}
+
+// Applies log to target, which must have type log was recorded for.
+// Mutation is checked by engine as any other one, so readonly fields and permissions are respected.
+func Replay(ctx context.Context, engine mttor.Engine, registry *Registry, log *Log, target interface{}) (err error) {
+	err = checkTarget(registry, log, reflect.TypeOf(target))
+	if err != nil {
+		return
+	}
+
+	mutation, err := log.Mutation()
+	if err != nil {
+		return
+	}
+
+	return engine.Mutate(ctx, target, mutation)
+}
+
+// Renders log as mongo update of document of type log was recorded for.
+func RenderMongo(ctx context.Context, engine mttor.MongoEngine, registry *Registry, log *Log) (update interface{}, err error) {
+	err = checkVersion(log)
+	if err != nil {
+		return
+	}
+
+	targetType, err := registry.Type(log.TargetType)
+	if err != nil {
+		return
+	}
+
+	mutation, err := log.Mutation()
+	if err != nil {
+		return
+	}
+
+	return engine.RenderMongoMutation(ctx, targetType, mutation)
+}
+
+func checkTarget(registry *Registry, log *Log, targetType reflect.Type) (err error) {
+	err = checkVersion(log)
+	if err != nil {
+		return
+	}
+
+	logType, err := registry.Type(log.TargetType)
+	if err != nil {
+		return
+	}
+
+	for targetType != nil && targetType.Kind() == reflect.Ptr {
+		targetType = targetType.Elem()
+	}
+	if targetType != logType {
+		err = fmt.Errorf("%w: expected %s, got %s", ErrTypeMismatch, logType, targetType)
+	}
+	return
+}
@@ -0,0 +1,196 @@
+package mttorsync
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/teawithsand/arcah/mttor"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Cache holds copies of documents of collection decoded into T, which are kept in sync by applying change events.
+//
+// Updates of documents, which are not cached, are ignored, unless event has full document.
+// Events, which invalidate change stream, like drop, clear cache.
+type Cache[T any] struct {
+	engine mttor.ChangeApplyingEngine
+
+	lock sync.RWMutex
+	docs map[string]*T
+}
+
+// Creates empty cache, which applies updates with engine given.
+func NewCache[T any](engine mttor.ChangeApplyingEngine) *Cache[T] {
+	return &Cache[T]{
+		engine: engine,
+		docs:   map[string]*T{},
+	}
+}
+
+// Returns key of document with given _id in cache.
+// It's encoded document key, as one in change events.
+func documentKey(id interface{}) (key string, err error) {
+	raw, err := bson.Marshal(bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return
+	}
+
+	key = string(raw)
+	return
+}
+
+// Returns key of document from document key of change event.
+func eventKey(documentKey bson.Raw) (key string, err error) {
+	id, err := documentKey.LookupErr("_id")
+	if err != nil {
+		return
+	}
+
+	raw, err := bson.Marshal(bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return
+	}
+
+	key = string(raw)
+	return
+}
+
+// Stores document with given _id, usually one loaded before change stream was opened.
+func (c *Cache[T]) Put(id interface{}, doc T) (err error) {
+	key, err := documentKey(id)
+	if err != nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.docs[key] = &doc
+	return
+}
+
+// Returns copy of document with given _id.
+// Copy shares slices, maps and pointers with cached document, so they must not be modified.
+func (c *Cache[T]) Get(id interface{}) (doc T, ok bool) {
+	key, err := documentKey(id)
+	if err != nil {
+		return
+	}
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	stored, ok := c.docs[key]
+	if ok {
+		doc = *stored
+	}
+	return
+}
+
+// Returns count of cached documents.
+func (c *Cache[T]) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return len(c.docs)
+}
+
+func decodeDocument[T any](raw bson.Raw) (doc *T, err error) {
+	var res T
+	err = bson.Unmarshal(raw, &res)
+	if err != nil {
+		return
+	}
+
+	doc = &res
+	return
+}
+
+// Applies single change event to cache.
+func (c *Cache[T]) Apply(ctx context.Context, event ChangeEvent) (err error) {
+	switch event.OperationType {
+	case OperationDrop, OperationRename, OperationInvalidate:
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		c.docs = map[string]*T{}
+		return
+	}
+
+	key, err := eventKey(event.DocumentKey)
+	if err != nil {
+		return
+	}
+
+	var doc *T
+	switch event.OperationType {
+	case OperationInsert, OperationReplace:
+		doc, err = decodeDocument[T](event.FullDocument)
+		if err != nil {
+			return
+		}
+	case OperationUpdate:
+		if len(event.FullDocument) > 0 {
+			doc, err = decodeDocument[T](event.FullDocument)
+			if err != nil {
+				return
+			}
+			break
+		}
+
+		if event.UpdateDescription == nil {
+			return
+		}
+
+		c.lock.RLock()
+		stored, ok := c.docs[key]
+		c.lock.RUnlock()
+		if !ok {
+			return
+		}
+
+		// documents are never modified once stored, so they can be read concurrently
+		res := *stored
+		err = c.engine.ApplyMongoChange(ctx, &res, *event.UpdateDescription)
+		if err != nil {
+			return
+		}
+		doc = &res
+	case OperationDelete:
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		delete(c.docs, key)
+		return
+	default:
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.docs[key] = doc
+	return
+}
+
+// Applies events from source, until it's closed or context is done.
+// Returns nil, when source is closed.
+func (c *Cache[T]) Run(ctx context.Context, source Source) (err error) {
+	for {
+		var event ChangeEvent
+		event, err = source.Next(ctx)
+		if errors.Is(err, ErrSourceClosed) {
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		err = c.Apply(ctx, event)
+		if err != nil {
+			return
+		}
+	}
+}
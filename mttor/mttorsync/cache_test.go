@@ -0,0 +1,146 @@
+package mttorsync_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/mttor"
+	"github.com/teawithsand/arcah/mttor/mttorsync"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type Address struct {
+	City   string `bson:"city"`
+	Street string `bson:"street"`
+}
+
+type Profile struct {
+	Nickname string `bson:"nick"`
+}
+
+type User struct {
+	ID      string   `bson:"_id"`
+	Name    string   `bson:"name"`
+	Tags    []string `bson:"tags"`
+	Address Address  `bson:"addr"`
+	Profile `bson:",inline"`
+}
+
+func mustMarshal(t *testing.T, v interface{}) bson.Raw {
+	raw, err := bson.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	engine := mttor.NewDefaultEngine().(mttor.ChangeApplyingEngine)
+
+	cache := mttorsync.NewCache[User](engine)
+	err := cache.Put("a", User{
+		ID:      "a",
+		Name:    "asdf",
+		Tags:    []string{"x", "y", "z"},
+		Address: Address{City: "Warsaw", Street: "Main"},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	key := func(id string) bson.Raw {
+		return mustMarshal(t, bson.D{{Key: "_id", Value: id}})
+	}
+
+	source := &mttorsync.FakeSource{}
+	source.Push(
+		mttorsync.ChangeEvent{
+			OperationType: mttorsync.OperationUpdate,
+			DocumentKey:   key("a"),
+			UpdateDescription: &mttor.MongoUpdateDescription{
+				UpdatedFields: mustMarshal(t, bson.D{
+					{Key: "name", Value: "fdsa"},
+					{Key: "addr.city", Value: "Cracow"},
+					{Key: "tags.1", Value: "w"},
+					{Key: "nick", Value: "nick"},
+					{Key: "unknown", Value: 1},
+				}),
+				RemovedFields: []string{"addr.street"},
+				TruncatedArrays: []mttor.MongoTruncatedArray{
+					{Field: "tags", NewSize: 2},
+				},
+			},
+		},
+		mttorsync.ChangeEvent{
+			OperationType: mttorsync.OperationInsert,
+			DocumentKey:   key("b"),
+			FullDocument:  mustMarshal(t, User{ID: "b", Name: "new"}),
+		},
+		mttorsync.ChangeEvent{
+			OperationType: mttorsync.OperationUpdate,
+			DocumentKey:   key("missing"),
+			UpdateDescription: &mttor.MongoUpdateDescription{
+				UpdatedFields: mustMarshal(t, bson.D{{Key: "name", Value: "x"}}),
+			},
+		},
+	)
+	source.Close()
+
+	err = cache.Run(ctx, source)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	a, ok := cache.Get("a")
+	expected := User{
+		ID:      "a",
+		Name:    "fdsa",
+		Tags:    []string{"x", "w"},
+		Address: Address{City: "Cracow"},
+		Profile: Profile{Nickname: "nick"},
+	}
+	if !ok || !reflect.DeepEqual(a, expected) {
+		t.Error("invalid updated document", a)
+		return
+	}
+
+	b, ok := cache.Get("b")
+	if !ok || b.Name != "new" {
+		t.Error("invalid inserted document", b)
+		return
+	}
+
+	if cache.Len() != 2 {
+		t.Error("update of missing document was cached")
+		return
+	}
+
+	err = cache.Apply(ctx, mttorsync.ChangeEvent{
+		OperationType: mttorsync.OperationDelete,
+		DocumentKey:   key("a"),
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, ok := cache.Get("a"); ok {
+		t.Error("deleted document is cached")
+		return
+	}
+}
+
+func TestFakeSource_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := (&mttorsync.FakeSource{}).Next(ctx)
+	if err != context.Canceled {
+		t.Error("expected cancellation, got", err)
+		return
+	}
+}
@@ -0,0 +1,139 @@
+// Package mttorsync keeps in-process copies of documents of mongo collection in sync with it,
+// by applying events of change stream to them.
+package mttorsync
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/teawithsand/arcah/mttor"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Returned by Source, when there are no more events.
+var ErrSourceClosed = errors.New("arcah/mttorsync: source of events is closed")
+
+// Operation types of change events, which Cache handles.
+const (
+	OperationInsert     = "insert"
+	OperationReplace    = "replace"
+	OperationUpdate     = "update"
+	OperationDelete     = "delete"
+	OperationDrop       = "drop"
+	OperationRename     = "rename"
+	OperationInvalidate = "invalidate"
+)
+
+// ChangeEvent is event of mongo change stream.
+// Only fields used by Cache are decoded.
+type ChangeEvent struct {
+	OperationType string `bson:"operationType"`
+	// Document with _id of changed document.
+	DocumentKey bson.Raw `bson:"documentKey"`
+	// Set for inserts and replaces, and for updates, if change stream was opened with updateLookup.
+	FullDocument      bson.Raw                      `bson:"fullDocument,omitempty"`
+	UpdateDescription *mttor.MongoUpdateDescription `bson:"updateDescription,omitempty"`
+}
+
+// Source provides events of change stream.
+type Source interface {
+	// Returns next event, blocking until it's available.
+	// Returns ErrSourceClosed, when there are no more events.
+	Next(ctx context.Context) (event ChangeEvent, err error)
+}
+
+type changeStreamSource struct {
+	stream *mongo.ChangeStream
+}
+
+// Returns source, which reads events from mongo change stream.
+func NewChangeStreamSource(stream *mongo.ChangeStream) Source {
+	return &changeStreamSource{stream: stream}
+}
+
+func (s *changeStreamSource) Next(ctx context.Context) (event ChangeEvent, err error) {
+	if !s.stream.Next(ctx) {
+		err = s.stream.Err()
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err == nil {
+			err = ErrSourceClosed
+		}
+		return
+	}
+
+	err = s.stream.Decode(&event)
+	return
+}
+
+// FakeSource is Source, which provides events pushed to it. It's meant for tests.
+// Zero value is ready to use.
+type FakeSource struct {
+	lock   sync.Mutex
+	cond   *sync.Cond
+	events []ChangeEvent
+	closed bool
+}
+
+func (s *FakeSource) init() {
+	if s.cond == nil {
+		s.cond = sync.NewCond(&s.lock)
+	}
+}
+
+// Adds events, which are returned by Next.
+func (s *FakeSource) Push(events ...ChangeEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.init()
+
+	s.events = append(s.events, events...)
+	s.cond.Broadcast()
+}
+
+// Makes Next return ErrSourceClosed, once all events pushed are returned.
+func (s *FakeSource) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.init()
+
+	s.closed = true
+	s.cond.Broadcast()
+}
+
+func (s *FakeSource) Next(ctx context.Context) (event ChangeEvent, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.init()
+
+	// wake up waiting goroutine, when context is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.lock.Lock()
+			defer s.lock.Unlock()
+			s.cond.Broadcast()
+		case <-done:
+		}
+	}()
+
+	for len(s.events) == 0 && !s.closed && ctx.Err() == nil {
+		s.cond.Wait()
+	}
+
+	switch {
+	case len(s.events) > 0:
+		event = s.events[0]
+		s.events = s.events[1:]
+	case ctx.Err() != nil:
+		err = ctx.Err()
+	default:
+		err = ErrSourceClosed
+	}
+	return
+}
@@ -0,0 +1,126 @@
+package mttortest
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"time"
+)
+
+// Generator creates random values of given type.
+type Generator interface {
+	Generate(r *rand.Rand, ty reflect.Type) (v reflect.Value, err error)
+}
+
+type GeneratorFunc func(r *rand.Rand, ty reflect.Type) (v reflect.Value, err error)
+
+func (f GeneratorFunc) Generate(r *rand.Rand, ty reflect.Type) (v reflect.Value, err error) {
+	return f(r, ty)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// DefaultGenerator generates small values, which can be stored in mongo without loss.
+//
+// Numbers are small, so arithmetic does not overflow, and floats are multiples of 1/4, so they are added exactly.
+// Slices and maps are never nil, since nil ones are stored as null, which most update operators reject.
+// Times have millisecond precision and are in UTC.
+var DefaultGenerator Generator = GeneratorFunc(generateValue)
+
+const alphabet = "abcxyzż"
+
+func generateValue(r *rand.Rand, ty reflect.Type) (v reflect.Value, err error) {
+	v = reflect.New(ty).Elem()
+
+	if ty == timeType {
+		ms := r.Int63n(1 << 41)
+		v.Set(reflect.ValueOf(time.UnixMilli(ms).UTC()))
+		return
+	}
+
+	switch ty.Kind() {
+	case reflect.Bool:
+		v.SetBool(r.Intn(2) == 1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(r.Intn(21) - 10))
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		v.SetUint(uint64(r.Intn(11)))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(r.Intn(81)-40) / 4)
+	case reflect.String:
+		runes := []rune(alphabet)
+		n := r.Intn(6)
+		res := make([]rune, n)
+		for i := range res {
+			res[i] = runes[r.Intn(len(runes))]
+		}
+		v.SetString(string(res))
+	case reflect.Slice:
+		n := r.Intn(4)
+		v.Set(reflect.MakeSlice(ty, n, n))
+		for i := 0; i < n; i++ {
+			err = setGenerated(r, v.Index(i))
+			if err != nil {
+				return
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			err = setGenerated(r, v.Index(i))
+			if err != nil {
+				return
+			}
+		}
+	case reflect.Map:
+		if ty.Key().Kind() != reflect.String {
+			err = fmt.Errorf("arcah/mttortest: can't generate map with keys of type %s", ty.Key())
+			return
+		}
+
+		v.Set(reflect.MakeMap(ty))
+		for i := r.Intn(3); i > 0; i-- {
+			var key, elem reflect.Value
+			key, err = generateValue(r, ty.Key())
+			if err != nil {
+				return
+			}
+			elem, err = generateValue(r, ty.Elem())
+			if err != nil {
+				return
+			}
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.Ptr:
+		if r.Intn(4) == 0 {
+			return
+		}
+
+		v.Set(reflect.New(ty.Elem()))
+		err = setGenerated(r, v.Elem())
+	case reflect.Struct:
+		for i := 0; i < ty.NumField(); i++ {
+			if !ty.Field(i).IsExported() {
+				continue
+			}
+
+			err = setGenerated(r, v.Field(i))
+			if err != nil {
+				return
+			}
+		}
+	case reflect.Interface:
+		// nil is only value, which can be both generated and decoded without knowing concrete type
+	default:
+		err = fmt.Errorf("arcah/mttortest: can't generate value of type %s", ty)
+	}
+	return
+}
+
+func setGenerated(r *rand.Rand, dst reflect.Value) (err error) {
+	v, err := generateValue(r, dst.Type())
+	if err != nil {
+		return
+	}
+	dst.Set(v)
+	return
+}
@@ -0,0 +1,386 @@
+// Package mttortest checks, that mutators behave same way, when applied in go and rendered for mongo.
+//
+// Mutator is registered in engine and used with random target and mutation values.
+// Each mutation is applied with Mutate and rendered with RenderMongoMutation; rendered update is applied
+// with mongoeval or real mongo server. Results are compared after encoding and decoding through BSON.
+package mttortest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/mongoutil/mongoeval"
+	"github.com/teawithsand/arcah/mttor"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultMutationName = "mutator"
+	defaultIterations   = 100
+	defaultMaxShrinks   = 1000
+)
+
+// Config of parity check of single mutator.
+type Config struct {
+	// Mutator to check.
+	// When nil, mutator registered in engine by default under MutationName is checked.
+	Mutator mttor.MongoMutator
+
+	// Name, under which mutator is registered.
+	// Defaults to "mutator", when Mutator is set.
+	MutationName string
+
+	// Arguments of mutation, as they would be written in mttor tag, like "omitempty".
+	Args string
+
+	// Type of target field and type of mutation field, which mutator is used with.
+	TargetType reflect.Type
+	ValueType  reflect.Type
+
+	// Count of random cases checked, defaults to 100.
+	Iterations int
+
+	// Seed of random generator, so failures can be reproduced.
+	Seed int64
+
+	// Generator of target and mutation values, defaults to DefaultGenerator.
+	Generator Generator
+
+	// If set, rendered updates are applied by mongo server using documents inserted into this collection.
+	// Otherwise, mongoeval is used.
+	Collection *mongo.Collection
+
+	// Maximum count of shrinking steps performed once divergence is found, defaults to 1000.
+	MaxShrinks int
+}
+
+// Divergence describes case, for which mutation applied in go and in mongo gave different results.
+type Divergence struct {
+	// Value of target field before mutation.
+	Target interface{}
+	// Value of mutation field.
+	Value interface{}
+
+	// Update rendered for mongo, nil if rendering failed.
+	Update interface{}
+
+	// Value of target field after mutation applied in go and decoded from BSON, along with error, if any.
+	Local    interface{}
+	LocalErr error
+
+	// Value of target field after update applied in mongo, along with error, if any.
+	Remote    interface{}
+	RemoteErr error
+}
+
+func (d *Divergence) Error() string {
+	if d == nil {
+		return "<nil>"
+	}
+
+	return fmt.Sprintf(
+		"arcah/mttortest: mutation diverges for target %#v and value %#v, update %v:\n\tgo:    %#v (error: %v)\n\tmongo: %#v (error: %v)",
+		d.Target, d.Value, d.Update, d.Local, d.LocalErr, d.Remote, d.RemoteErr,
+	)
+}
+
+// errPanic is returned as local error, when mutator panicked.
+var errPanic = errors.New("arcah/mttortest: mutator panicked")
+
+type checker struct {
+	cfg Config
+
+	engine      mttor.Engine
+	mongoEngine mttor.MongoEngine
+
+	targetType   reflect.Type
+	mutationType reflect.Type
+}
+
+func newChecker(cfg Config) (c *checker, err error) {
+	if cfg.TargetType == nil || cfg.ValueType == nil {
+		err = errors.New("arcah/mttortest: TargetType and ValueType must be set")
+		return
+	}
+
+	if cfg.Mutator != nil && len(cfg.MutationName) == 0 {
+		cfg.MutationName = defaultMutationName
+	}
+	if cfg.Iterations <= 0 {
+		cfg.Iterations = defaultIterations
+	}
+	if cfg.MaxShrinks <= 0 {
+		cfg.MaxShrinks = defaultMaxShrinks
+	}
+	if cfg.Generator == nil {
+		cfg.Generator = DefaultGenerator
+	}
+
+	options := mttor.EngineOptions{}
+	if cfg.Mutator != nil {
+		options.Mutators = map[string]mttor.Mutator{
+			cfg.MutationName: cfg.Mutator,
+		}
+	}
+	engine := mttor.NewEngine(options)
+
+	tag := "Field," + cfg.MutationName
+	if len(cfg.Args) > 0 {
+		tag += "," + cfg.Args
+	}
+
+	c = &checker{
+		cfg:         cfg,
+		engine:      engine,
+		mongoEngine: engine.(mttor.MongoEngine),
+		targetType: reflect.StructOf([]reflect.StructField{
+			{
+				Name: "Field",
+				Type: cfg.TargetType,
+				Tag:  `bson:"field"`,
+			},
+		}),
+		mutationType: reflect.StructOf([]reflect.StructField{
+			{
+				Name: "Field",
+				Type: cfg.ValueType,
+				Tag:  reflect.StructTag(fmt.Sprintf("mttor:%q", tag)),
+			},
+		}),
+	}
+	return
+}
+
+// Check runs parity check described by config.
+// It returns minimal divergence found or nil, if there was none.
+// Error is returned, when check itself can't be performed.
+func Check(ctx context.Context, cfg Config) (divergence *Divergence, err error) {
+	c, err := newChecker(cfg)
+	if err != nil {
+		return
+	}
+
+	r := rand.New(rand.NewSource(c.cfg.Seed))
+	for i := 0; i < c.cfg.Iterations; i++ {
+		var target, value reflect.Value
+		target, err = c.cfg.Generator.Generate(r, c.cfg.TargetType)
+		if err != nil {
+			return
+		}
+		value, err = c.cfg.Generator.Generate(r, c.cfg.ValueType)
+		if err != nil {
+			return
+		}
+
+		divergence, err = c.run(ctx, target, value)
+		if err != nil || divergence != nil {
+			break
+		}
+	}
+
+	if divergence != nil {
+		divergence, err = c.shrink(ctx, divergence)
+	}
+	return
+}
+
+// AssertParity runs Check and reports divergence found or error as test failure.
+func AssertParity(t testing.TB, cfg Config) {
+	t.Helper()
+
+	divergence, err := Check(context.Background(), cfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if divergence != nil {
+		t.Error(divergence)
+		return
+	}
+}
+
+// Tries simpler values as long as divergence persists.
+func (c *checker) shrink(ctx context.Context, divergence *Divergence) (res *Divergence, err error) {
+	res = divergence
+	steps := 0
+
+	for {
+		found := false
+		target, value := reflect.ValueOf(res.Target), reflect.ValueOf(res.Value)
+		if !target.IsValid() {
+			target = reflect.Zero(c.cfg.TargetType)
+		}
+		if !value.IsValid() {
+			value = reflect.Zero(c.cfg.ValueType)
+		}
+
+		var candidates [][2]reflect.Value
+		for _, t := range shrinkValue(target) {
+			candidates = append(candidates, [2]reflect.Value{t, value})
+		}
+		for _, v := range shrinkValue(value) {
+			candidates = append(candidates, [2]reflect.Value{target, v})
+		}
+
+		for _, candidate := range candidates {
+			if steps >= c.cfg.MaxShrinks {
+				return
+			}
+			steps++
+
+			var d *Divergence
+			d, err = c.run(ctx, candidate[0], candidate[1])
+			if err != nil {
+				return
+			}
+			if d != nil {
+				res = d
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return
+		}
+	}
+}
+
+// Applies mutation with given values of fields locally and in mongo.
+// Returns divergence, if results differ.
+func (c *checker) run(ctx context.Context, target, value reflect.Value) (divergence *Divergence, err error) {
+	targetDoc := reflect.New(c.targetType)
+	targetDoc.Elem().Field(0).Set(target)
+
+	mutation := reflect.New(c.mutationType).Elem()
+	mutation.Field(0).Set(value)
+
+	d := &Divergence{
+		Target: target.Interface(),
+		Value:  value.Interface(),
+	}
+
+	// stored document is what mongo would see, so local mutation is applied to it as well
+	initial, err := bson.Marshal(targetDoc.Interface())
+	if err != nil {
+		err = fmt.Errorf("arcah/mttortest: can't encode target %#v: %w", d.Target, err)
+		return
+	}
+
+	isPanic := false
+	d.Local, d.LocalErr, isPanic = c.runLocal(ctx, initial, mutation.Interface())
+
+	d.Update, d.Remote, d.RemoteErr, err = c.runRemote(ctx, initial, mutation.Interface())
+	if err != nil {
+		return
+	}
+
+	switch {
+	case isPanic:
+		divergence = d
+	case d.LocalErr != nil && d.RemoteErr != nil:
+	case d.LocalErr != nil || d.RemoteErr != nil:
+		divergence = d
+	case !reflect.DeepEqual(d.Local, d.Remote):
+		divergence = d
+	}
+	return
+}
+
+func (c *checker) decodeField(raw []byte) (res interface{}, err error) {
+	doc := reflect.New(c.targetType)
+	err = bson.Unmarshal(raw, doc.Interface())
+	if err != nil {
+		return
+	}
+
+	res = doc.Elem().Field(0).Interface()
+	return
+}
+
+func (c *checker) runLocal(ctx context.Context, initial []byte, mutation interface{}) (res interface{}, localErr error, isPanic bool) {
+	doc := reflect.New(c.targetType)
+	localErr = bson.Unmarshal(initial, doc.Interface())
+	if localErr != nil {
+		return
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				isPanic = true
+				localErr = fmt.Errorf("%w: %v", errPanic, r)
+			}
+		}()
+
+		localErr = c.engine.Mutate(ctx, doc.Interface(), mutation)
+	}()
+	if localErr != nil {
+		return
+	}
+
+	raw, localErr := bson.Marshal(doc.Interface())
+	if localErr != nil {
+		return
+	}
+
+	res, localErr = c.decodeField(raw)
+	return
+}
+
+// Renders mutation and applies it to initial document.
+// Errors of rendering and applying update are returned as remoteErr, while err is returned only when mongo server can't be used.
+func (c *checker) runRemote(ctx context.Context, initial []byte, mutation interface{}) (update, res interface{}, remoteErr, err error) {
+	update, remoteErr = c.mongoEngine.RenderMongoMutation(ctx, reflect.PtrTo(c.targetType), mutation)
+	if remoteErr != nil {
+		return
+	}
+
+	if c.cfg.Collection == nil {
+		var doc bson.D
+		doc, remoteErr = mongoeval.ApplyUpdate(bson.Raw(initial), update)
+		if remoteErr != nil {
+			return
+		}
+
+		var raw []byte
+		raw, remoteErr = bson.Marshal(doc)
+		if remoteErr != nil {
+			return
+		}
+
+		res, remoteErr = c.decodeField(raw)
+		return
+	}
+
+	insertRes, err := c.cfg.Collection.InsertOne(ctx, bson.Raw(initial))
+	if err != nil {
+		return
+	}
+	filter := bson.D{{Key: "_id", Value: insertRes.InsertedID}}
+	defer func() {
+		_, deleteErr := c.cfg.Collection.DeleteOne(ctx, filter)
+		if err == nil {
+			err = deleteErr
+		}
+	}()
+
+	_, remoteErr = c.cfg.Collection.UpdateOne(ctx, filter, update)
+	if remoteErr != nil {
+		return
+	}
+
+	raw, err := c.cfg.Collection.FindOne(ctx, filter).DecodeBytes()
+	if err != nil {
+		return
+	}
+
+	res, remoteErr = c.decodeField(raw)
+	return
+}
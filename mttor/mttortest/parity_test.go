@@ -0,0 +1,139 @@
+package mttortest_test
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/teawithsand/arcah/internal/refutil"
+	"github.com/teawithsand/arcah/mttor"
+	"github.com/teawithsand/arcah/mttor/mttortest"
+	"github.com/teawithsand/reval/stdesc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Sets field to greater of its value and value of mutation.
+type maxMutation struct {
+}
+
+func (m *maxMutation) ApplyMutation(ctx context.Context, target reflect.Value, field stdesc.Field, data mttor.MutatorData) (err error) {
+	value := reflect.ValueOf(data.Value)
+	if value.Int() > field.MustGet(target).Int() {
+		field.MustSet(target, value)
+	}
+	return
+}
+
+func (m *maxMutation) MongoMutationName() string {
+	return "$max"
+}
+
+func (m *maxMutation) RenderMongoDoc(ctx context.Context, data mttor.MongoMutatorData) (entry bson.E, err error) {
+	return bson.E{Key: data.BSONFieldName, Value: data.Value}, nil
+}
+
+// Adds value in go, but sets it in mongo.
+type brokenIncMutation struct {
+	maxMutation
+}
+
+func (m *brokenIncMutation) ApplyMutation(ctx context.Context, target reflect.Value, field stdesc.Field, data mttor.MutatorData) (err error) {
+	prev := refutil.ValueToNumber(field.MustGet(target)).(int64)
+	field.MustSet(target, reflect.ValueOf(prev+data.Value.(int64)))
+	return
+}
+
+func (m *brokenIncMutation) MongoMutationName() string {
+	return "$set"
+}
+
+// Uses mongo server from ARCAH_TEST_MONGO, if it's set.
+func testCollection(t *testing.T) *mongo.Collection {
+	uri := os.Getenv("ARCAH_TEST_MONGO")
+	if len(uri) == 0 {
+		return nil
+	}
+
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	database := client.Database("arcah_mttortest")
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+
+		database.Drop(ctx)
+		client.Disconnect(ctx)
+	})
+	return database.Collection("parity")
+}
+
+func TestCheck_Builtin(t *testing.T) {
+	collection := testCollection(t)
+
+	for _, tc := range []struct {
+		Name       string
+		Mutation   string
+		TargetType reflect.Type
+		ValueType  reflect.Type
+	}{
+		{"set_string", "set", reflect.TypeOf(""), reflect.TypeOf("")},
+		{"set_struct", "set", reflect.TypeOf(struct{ A []int }{}), reflect.TypeOf(struct{ A []int }{})},
+		{"set_ptr", "set", reflect.TypeOf(new(float64)), reflect.TypeOf(new(float64))},
+		{"inc_int", "inc", reflect.TypeOf(int(0)), reflect.TypeOf(int(0))},
+		{"inc_int32", "inc", reflect.TypeOf(int32(0)), reflect.TypeOf(int32(0))},
+		{"inc_float", "inc", reflect.TypeOf(float64(0)), reflect.TypeOf(float64(0))},
+		{"push", "push", reflect.TypeOf([]string{}), reflect.TypeOf("")},
+		{"push_many", "push", reflect.TypeOf([]int{}), reflect.TypeOf([]int{})},
+		{"unset", "unset", reflect.TypeOf(""), reflect.TypeOf(true)},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			mttortest.AssertParity(t, mttortest.Config{
+				MutationName: tc.Mutation,
+				TargetType:   tc.TargetType,
+				ValueType:    tc.ValueType,
+				Collection:   collection,
+			})
+		})
+	}
+}
+
+func TestCheck_Custom(t *testing.T) {
+	mttortest.AssertParity(t, mttortest.Config{
+		Mutator:    &maxMutation{},
+		TargetType: reflect.TypeOf(int64(0)),
+		ValueType:  reflect.TypeOf(int64(0)),
+		Collection: testCollection(t),
+	})
+}
+
+func TestCheck_Divergence(t *testing.T) {
+	divergence, err := mttortest.Check(context.Background(), mttortest.Config{
+		Mutator:    &brokenIncMutation{},
+		TargetType: reflect.TypeOf(int64(0)),
+		ValueType:  reflect.TypeOf(int64(0)),
+		Collection: testCollection(t),
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if divergence == nil {
+		t.Error("expected divergence")
+		return
+	}
+
+	// divergence occurs for any non-zero target, so it's shrunk to smallest one
+	target := divergence.Target.(int64)
+	if (target != 1 && target != -1) || divergence.Value.(int64) != 0 {
+		t.Error("divergence was not shrunk", divergence)
+		return
+	}
+}
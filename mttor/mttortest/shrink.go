@@ -0,0 +1,162 @@
+package mttortest
+
+import (
+	"math"
+	"reflect"
+)
+
+// Returns values, which are simpler than v, simplest first.
+// Shrinking is used to find minimal values, for which divergence still occurs.
+func shrinkValue(v reflect.Value) (res []reflect.Value) {
+	ty := v.Type()
+	add := func(fn func(dst reflect.Value)) {
+		dst := reflect.New(ty).Elem()
+		fn(dst)
+		res = append(res, dst)
+	}
+
+	switch ty.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			add(func(dst reflect.Value) {})
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := v.Int()
+		if n == 0 {
+			return
+		}
+		add(func(dst reflect.Value) {})
+		for _, c := range []int64{n / 2, n - n/abs(n)} {
+			if c != 0 && c != n {
+				c := c
+				add(func(dst reflect.Value) { dst.SetInt(c) })
+			}
+		}
+		if n < 0 && n != math.MinInt64 {
+			add(func(dst reflect.Value) { dst.SetInt(-n) })
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := v.Uint()
+		if n == 0 {
+			return
+		}
+		add(func(dst reflect.Value) {})
+		for _, c := range []uint64{n / 2, n - 1} {
+			if c != 0 {
+				c := c
+				add(func(dst reflect.Value) { dst.SetUint(c) })
+			}
+		}
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f == 0 {
+			return
+		}
+		add(func(dst reflect.Value) {})
+		if math.Trunc(f) != f {
+			add(func(dst reflect.Value) { dst.SetFloat(math.Trunc(f)) })
+		}
+		if f < 0 {
+			add(func(dst reflect.Value) { dst.SetFloat(-f) })
+		}
+	case reflect.String:
+		s := []rune(v.String())
+		if len(s) == 0 {
+			return
+		}
+		add(func(dst reflect.Value) {})
+		for i := range s {
+			rest := string(append(append([]rune{}, s[:i]...), s[i+1:]...))
+			add(func(dst reflect.Value) { dst.SetString(rest) })
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		if v.Len() > 0 {
+			add(func(dst reflect.Value) { dst.Set(reflect.MakeSlice(ty, 0, 0)) })
+		}
+		for i := 0; i < v.Len(); i++ {
+			i := i
+			add(func(dst reflect.Value) {
+				dst.Set(reflect.AppendSlice(reflect.MakeSlice(ty, 0, v.Len()-1), v.Slice(0, i)))
+				dst.Set(reflect.AppendSlice(dst, v.Slice(i+1, v.Len())))
+			})
+		}
+		res = append(res, shrinkElements(v, func() reflect.Value {
+			dst := reflect.MakeSlice(ty, v.Len(), v.Len())
+			reflect.Copy(dst, v)
+			return dst
+		})...)
+	case reflect.Array:
+		res = append(res, shrinkElements(v, func() reflect.Value {
+			dst := reflect.New(ty).Elem()
+			dst.Set(v)
+			return dst
+		})...)
+	case reflect.Map:
+		if v.IsNil() {
+			return
+		}
+		for _, key := range v.MapKeys() {
+			key := key
+			add(func(dst reflect.Value) {
+				dst.Set(reflect.MakeMap(ty))
+				for _, k := range v.MapKeys() {
+					if k.Interface() != key.Interface() {
+						dst.SetMapIndex(k, v.MapIndex(k))
+					}
+				}
+			})
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		add(func(dst reflect.Value) {})
+		for _, elem := range shrinkValue(v.Elem()) {
+			elem := elem
+			add(func(dst reflect.Value) {
+				dst.Set(reflect.New(ty.Elem()))
+				dst.Elem().Set(elem)
+			})
+		}
+	case reflect.Struct:
+		if ty == timeType {
+			return
+		}
+		for i := 0; i < ty.NumField(); i++ {
+			if !ty.Field(i).IsExported() {
+				continue
+			}
+			i := i
+			for _, f := range shrinkValue(v.Field(i)) {
+				f := f
+				add(func(dst reflect.Value) {
+					dst.Set(v)
+					dst.Field(i).Set(f)
+				})
+			}
+		}
+	}
+	return
+}
+
+// Returns copies of slice or array v with single element shrunk.
+func shrinkElements(v reflect.Value, copyValue func() reflect.Value) (res []reflect.Value) {
+	for i := 0; i < v.Len(); i++ {
+		for _, e := range shrinkValue(v.Index(i)) {
+			dst := copyValue()
+			dst.Index(i).Set(e)
+			res = append(res, dst)
+		}
+	}
+	return
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
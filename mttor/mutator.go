@@ -21,8 +21,16 @@ type Mutator interface {
 	ApplyMutation(ctx context.Context, target reflect.Value, field stdesc.Field, data MutatorData) (err error)
 }
 
+// Mutator, which is able to check statically, if it can be applied to field of target type using value of given type.
+// Mutators, which do not implement it, are assumed to accept any types.
+type TypeCheckingMutator interface {
+	Mutator
+	CheckTypes(targetType, valueType reflect.Type) (err error)
+}
+
 type MongoMutatorData struct {
 	MutatorData
+	// Dotted path of target field in BSON document.
 	BSONFieldName string
 	Skip          bool
 }
@@ -34,3 +42,60 @@ type MongoMutator interface {
 	MongoMutationName() string
 	RenderMongoDoc(ctx context.Context, data MongoMutatorData) (entry bson.E, err error)
 }
+
+// Mutation, which is able to render itself as field of $set stage of mongo update pipeline.
+//
+// Mutations, which have any field with mutator, which is not MongoMutator, are rendered as update pipelines,
+// with stage for each field, so fields see changes made by preceding ones.
+type MongoPipelineMutator interface {
+	Mutator
+	// Returns aggregation expression, which computes new value of target field.
+	RenderMongoPipelineField(ctx context.Context, data MongoMutatorData) (expr interface{}, err error)
+}
+
+// Mutator, which is applied only when entity is created, like $setOnInsert.
+// When entity exists, fields with such mutators are ignored.
+type insertOnlyMutator interface {
+	insertOnly()
+}
+
+func isInsertOnly(mutator Mutator) bool {
+	_, ok := mutator.(insertOnlyMutator)
+	return ok
+}
+
+// Mutator, which value refers to fields of target, so it has to be resolved against descriptor of target.
+type bindingMutator interface {
+	bindValue(desc stdesc.Descriptor, value interface{}) (res interface{}, err error)
+}
+
+type SQLMutatorData struct {
+	MutatorData
+	SQLColumnName string
+	// Type of target field.
+	TargetType reflect.Type
+
+	Dialect SQLDialect
+	// Count of arguments, which were already rendered by previous mutators.
+	ArgOffset int
+}
+
+// Returns placeholder for i-th argument returned by mutator, counting from zero.
+func (data *SQLMutatorData) Placeholder(i int) string {
+	return data.Dialect.Placeholder(data.ArgOffset + i + 1)
+}
+
+// Returns expression cast to double precision float in dialect of data.
+func (data *SQLMutatorData) FloatCast(expr string) string {
+	if dialect, ok := data.Dialect.(SQLFloatCastDialect); ok {
+		return dialect.FloatCast(expr)
+	}
+	return "CAST(" + expr + " AS DOUBLE PRECISION)"
+}
+
+// Mutation, which is able to render itself as assignment in SQL UPDATE statement.
+type SQLMutator interface {
+	Mutator
+	// Returns expression assigned to column, like `counter + $1`, and arguments for placeholders used in it.
+	RenderSQLAssignment(ctx context.Context, data SQLMutatorData) (expr string, args []interface{}, err error)
+}
@@ -14,10 +14,34 @@ type setMutation struct {
 }
 
 func (sm *setMutation) ApplyMutation(ctx context.Context, target reflect.Value, field stdesc.Field, data MutatorData) (err error) {
-	field.MustSet(target, reflect.ValueOf(data.Value))
+	value := reflect.ValueOf(data.Value)
+	if !value.IsValid() {
+		value = reflect.Zero(field.Type)
+	}
+
+	err = sm.CheckTypes(field.Type, value.Type())
+	if err != nil {
+		return
+	}
+
+	field.MustSet(target, value)
 	return
 }
 
+func (sm *setMutation) CheckTypes(targetType, valueType reflect.Type) (err error) {
+	if !valueType.AssignableTo(targetType) {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("value of type %s is not assignable to field of type %s", valueType, targetType),
+		}
+		return
+	}
+	return
+}
+
+func (sm *setMutation) DescribeMutation(targetFieldName string) string {
+	return "New value of " + targetFieldName
+}
+
 func (sm *setMutation) MongoMutationName() string {
 	return "$set"
 }
@@ -29,6 +53,42 @@ func (sm *setMutation) RenderMongoDoc(ctx context.Context, data MongoMutatorData
 	}, nil
 }
 
+func (sm *setMutation) RenderMongoPipelineField(ctx context.Context, data MongoMutatorData) (expr interface{}, err error) {
+	return bson.D{{Key: "$literal", Value: data.Value}}, nil
+}
+
+func (sm *setMutation) RenderSQLAssignment(ctx context.Context, data SQLMutatorData) (expr string, args []interface{}, err error) {
+	return data.Placeholder(0), []interface{}{data.Value}, nil
+}
+
+// Sets field, only when entity is created with Upsert.
+// Immutable fields may be set with it.
+type setOnInsertMutation struct {
+	set setMutation
+}
+
+func (sm *setOnInsertMutation) insertOnly() {}
+
+func (sm *setOnInsertMutation) ApplyMutation(ctx context.Context, target reflect.Value, field stdesc.Field, data MutatorData) (err error) {
+	return sm.set.ApplyMutation(ctx, target, field, data)
+}
+
+func (sm *setOnInsertMutation) CheckTypes(targetType, valueType reflect.Type) (err error) {
+	return sm.set.CheckTypes(targetType, valueType)
+}
+
+func (sm *setOnInsertMutation) DescribeMutation(targetFieldName string) string {
+	return "Value of " + targetFieldName + " set, when entity is created"
+}
+
+func (sm *setOnInsertMutation) MongoMutationName() string {
+	return "$setOnInsert"
+}
+
+func (sm *setOnInsertMutation) RenderMongoDoc(ctx context.Context, data MongoMutatorData) (entry bson.E, err error) {
+	return sm.set.RenderMongoDoc(ctx, data)
+}
+
 type incMutation struct {
 }
 
@@ -67,10 +127,39 @@ func (sm *incMutation) ApplyMutation(ctx context.Context, target reflect.Value,
 		tempResult = pv + modValue.(float64)
 	}
 
-	field.MustSet(target, reflect.ValueOf(tempResult))
+	// result is widened to 64 bits, so it has to be converted back to type of field
+	field.MustSet(target, reflect.ValueOf(tempResult).Convert(field.Type))
+	return
+}
+
+func (sm *incMutation) CheckTypes(targetType, valueType reflect.Type) (err error) {
+	if refutil.NumberKind(targetType) == reflect.Invalid {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("inc mutation target field is not number"),
+		}
+		return
+	}
+
+	if refutil.NumberKind(valueType) == reflect.Invalid {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("mutator value target field is not number"),
+		}
+		return
+	}
+
+	if refutil.NumberKind(targetType) != refutil.NumberKind(valueType) {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("numbers in mutator and field have different types"),
+		}
+		return
+	}
 	return
 }
 
+func (sm *incMutation) DescribeMutation(targetFieldName string) string {
+	return "Value added to " + targetFieldName
+}
+
 func (sm *incMutation) MongoMutationName() string {
 	return "$inc"
 }
@@ -82,6 +171,63 @@ func (sm *incMutation) RenderMongoDoc(ctx context.Context, data MongoMutatorData
 	}, nil
 }
 
+func (sm *incMutation) RenderMongoPipelineField(ctx context.Context, data MongoMutatorData) (expr interface{}, err error) {
+	// like $inc, missing field is treated as zero
+	return bson.D{{Key: "$add", Value: bson.A{
+		bson.D{{Key: "$ifNull", Value: bson.A{"$" + data.BSONFieldName, 0}}},
+		bson.D{{Key: "$literal", Value: data.Value}},
+	}}}, nil
+}
+
+func (sm *incMutation) RenderSQLAssignment(ctx context.Context, data SQLMutatorData) (expr string, args []interface{}, err error) {
+	if refutil.ValueToNumber(reflect.ValueOf(data.Value)) == nil {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("mutator value target field is not number"),
+		}
+		return
+	}
+
+	return data.SQLColumnName + " + " + data.Placeholder(0), []interface{}{data.Value}, nil
+}
+
+// Sets field to its zero value.
+// Value of mutation field is ignored, so it's usually bool combined with omitempty.
+type unsetMutation struct {
+}
+
+func (sm *unsetMutation) ApplyMutation(ctx context.Context, target reflect.Value, field stdesc.Field, data MutatorData) (err error) {
+	field.MustSet(target, reflect.Zero(field.Type))
+	return
+}
+
+func (sm *unsetMutation) CheckTypes(targetType, valueType reflect.Type) (err error) {
+	return
+}
+
+func (sm *unsetMutation) DescribeMutation(targetFieldName string) string {
+	return "If set, clears " + targetFieldName
+}
+
+func (sm *unsetMutation) MongoMutationName() string {
+	return "$unset"
+}
+
+func (sm *unsetMutation) RenderMongoDoc(ctx context.Context, data MongoMutatorData) (entry bson.E, err error) {
+	return bson.E{
+		Key:   data.BSONFieldName,
+		Value: "",
+	}, nil
+}
+
+func (sm *unsetMutation) RenderMongoPipelineField(ctx context.Context, data MongoMutatorData) (expr interface{}, err error) {
+	return "$$REMOVE", nil
+}
+
+func (sm *unsetMutation) RenderSQLAssignment(ctx context.Context, data SQLMutatorData) (expr string, args []interface{}, err error) {
+	// zero value rather than NULL, like ApplyMutation, so NOT NULL columns can be unset
+	return data.Placeholder(0), []interface{}{reflect.Zero(data.TargetType).Interface()}, nil
+}
+
 type pushMutation struct {
 }
 
@@ -112,6 +258,29 @@ func (sm *pushMutation) ApplyMutation(ctx context.Context, target reflect.Value,
 	return
 }
 
+func (sm *pushMutation) CheckTypes(targetType, valueType reflect.Type) (err error) {
+	if targetType.Kind() != reflect.Slice {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("target is not slice"),
+		}
+		return
+	}
+
+	isElem := valueType == targetType.Elem()
+	isElemList := (valueType.Kind() == reflect.Slice || valueType.Kind() == reflect.Array) && valueType.Elem() == targetType.Elem()
+	if !isElem && !isElemList {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("element of type %s is not compatible with slice of type %s", valueType, targetType),
+		}
+		return
+	}
+	return
+}
+
+func (sm *pushMutation) DescribeMutation(targetFieldName string) string {
+	return "Value or values appended to " + targetFieldName
+}
+
 func (sm *pushMutation) MongoMutationName() string {
 	return "$push"
 }
@@ -135,3 +304,16 @@ func (sm *pushMutation) RenderMongoDoc(ctx context.Context, data MongoMutatorDat
 		},
 	}, nil
 }
+
+func (sm *pushMutation) RenderMongoPipelineField(ctx context.Context, data MongoMutatorData) (expr interface{}, err error) {
+	entry, err := sm.RenderMongoDoc(ctx, data)
+	if err != nil {
+		return
+	}
+	values := entry.Value.(bson.D)[0].Value
+
+	return bson.D{{Key: "$concatArrays", Value: bson.A{
+		bson.D{{Key: "$ifNull", Value: bson.A{"$" + data.BSONFieldName, bson.A{}}}},
+		bson.D{{Key: "$literal", Value: values}},
+	}}}, nil
+}
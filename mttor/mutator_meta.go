@@ -1,12 +1,14 @@
 package mttor
 
 import (
-	"strings"
+	"reflect"
 
 	"github.com/teawithsand/arcah/internal/refutil"
+	"github.com/teawithsand/arcah/internal/tagparse"
+	"github.com/teawithsand/arcah/mongoutil"
 )
 
-const defaultMutatorTagName = "mttor"
+const defaultMutatorTagName = tagparse.MttorTagName
 
 type MutationArgs map[string][]string
 
@@ -41,45 +43,113 @@ type mutatorMeta struct {
 	MutationName string
 
 	TargetMutationArgs MutationArgs
-}
 
-// TODO(teawithsand): use reval tag parsing here
+	// Roles allowed to use this field, taken from roles tag.
+	Roles []string
+}
 
 func (mm *mutatorMeta) ParseTag(tags string) (err error) {
-	values := strings.Split(tags, ",")
-	if len(values) >= 1 {
-		mm.TargetFieldName = values[0]
-	}
-	if len(values) >= 2 {
-		mm.MutationName = values[1]
+	tag, err := tagparse.ParseMutationTag(tags)
+	if err != nil {
+		return
 	}
 
-	args := MutationArgs{}
+	mm.TargetFieldName = tag.TargetFieldName
+	mm.MutationName = tag.MutationName
+	mm.TargetMutationArgs = MutationArgs(tag.Args)
+	return
+}
 
-	if len(values) >= 3 {
-		for _, v := range values[2:] {
-			res := strings.SplitN(v, ":", 1)
-			if len(res) == 2 {
-				args[res[0]] = append(args[res[0]], res[1])
-			} else {
-				args[res[0]] = append(args[res[0]], "")
-			}
+// Overrides metadata parsed from tags with one registered with Describe.
+func (mm *mutatorMeta) applyDescription(fd fieldDescription) {
+	if len(fd.TargetFieldName) > 0 {
+		mm.TargetFieldName = fd.TargetFieldName
+	}
+	if len(fd.MutationName) > 0 {
+		mm.MutationName = fd.MutationName
+	}
+	if len(fd.Args) > 0 {
+		args := MutationArgs{}
+		for k, v := range mm.TargetMutationArgs {
+			args[k] = v
 		}
-
+		for k, v := range fd.Args {
+			args[k] = append(append([]string(nil), args[k]...), v...)
+		}
+		mm.TargetMutationArgs = args
+	}
+	if fd.Roles != nil {
+		mm.Roles = fd.Roles
 	}
-	mm.TargetMutationArgs = args
-
-	return
 }
 
 type mutatorTargetMeta struct {
-	refutil.BSONFieldMeta
+	BSON mongoutil.StructTags
+	// Dotted path of field in BSON document, which includes names of subdocuments, field is embedded in.
+	BSONPath string
+
+	refutil.SQLFieldMeta
+
+	// Name registered with Describe, which mutations use to refer to this field.
+	// Empty, when go field name is used.
+	TargetName string
+
+	// If true, field can't be changed by any mutation.
+	Readonly bool
+
+	// If true, field can't be changed once entity is created.
+	Immutable bool
+
+	// Roles allowed to write this field, taken from roles tag.
+	Roles []string
 }
 
-func (mtm *mutatorTargetMeta) ParseTag(bsonTags string) (err error) {
-	err = mtm.BSONFieldMeta.ParseTag(bsonTags)
+// Parses metadata of target field from its tags.
+// BSON tags are parsed with parser provided, so that they match ones used by mongo driver.
+//
+// Tag mttor on target field has form of `mttor:"-,flags..."`, where supported flags are readonly and immutable.
+// Other mttor tags are ignored, so types may be used both as targets and mutations.
+func (mtm *mutatorTargetMeta) ParseField(bsonTagParser mongoutil.StructTagParser, field reflect.StructField) (err error) {
+	tags := field.Tag
+
+	mtm.BSON, err = bsonTagParser.ParseStructTags(field)
+	if err != nil {
+		return
+	}
+
+	err = mtm.SQLFieldMeta.ParseTag(tags.Get(refutil.DBTagName), tags.Get(refutil.SQLTagName))
+	if err != nil {
+		return
+	}
+
+	targetTag, err := tagparse.ParseTargetTag(tags.Get(defaultMutatorTagName))
 	if err != nil {
 		return
 	}
+
+	mtm.Readonly = targetTag.Readonly
+	mtm.Immutable = targetTag.Immutable
+
+	mtm.Roles = parseRolesTag(tags.Get(rolesTagName))
 	return
 }
+
+// Overrides metadata parsed from tags with one registered with Describe.
+func (mtm *mutatorTargetMeta) applyDescription(fd fieldDescription) {
+	if len(fd.TargetName) > 0 {
+		mtm.TargetName = fd.TargetName
+	}
+	if len(fd.BSONName) > 0 {
+		mtm.BSON.Name = fd.BSONName
+		mtm.BSON.NameSet = true
+	}
+	if len(fd.SQLColumn) > 0 {
+		mtm.SQLColumnName = fd.SQLColumn
+		mtm.SQLSkip = false
+	}
+	mtm.Readonly = mtm.Readonly || fd.Readonly
+	mtm.Immutable = mtm.Immutable || fd.Immutable
+	if fd.Roles != nil {
+		mtm.Roles = fd.Roles
+	}
+}
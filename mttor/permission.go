@@ -0,0 +1,97 @@
+package mttor
+
+import (
+	"context"
+	"reflect"
+	"strings"
+)
+
+// Name of tag, which lists roles allowed to write field.
+// It may be set on both target and mutation fields.
+const rolesTagName = "roles"
+
+func parseRolesTag(tag string) (roles []string) {
+	for _, role := range strings.Split(tag, ",") {
+		role = strings.TrimSpace(role)
+		if len(role) > 0 {
+			roles = append(roles, role)
+		}
+	}
+	return
+}
+
+// Describes field, which is about to be written by mutation.
+type FieldPermission struct {
+	TargetType reflect.Type
+	FieldName  string
+
+	// Roles listed on target field. Any of them is required to write the field.
+	// Empty, when target field is not restricted.
+	TargetRoles []string
+
+	// Roles listed on mutation field. Any of them is required to use the field.
+	// Empty, when mutation field is not restricted.
+	MutationRoles []string
+}
+
+// PermissionChecker decides if caller, described by context, may write specified field.
+type PermissionChecker interface {
+	CanWrite(ctx context.Context, field FieldPermission) (ok bool, err error)
+}
+
+type PermissionCheckerFunc func(ctx context.Context, field FieldPermission) (ok bool, err error)
+
+func (f PermissionCheckerFunc) CanWrite(ctx context.Context, field FieldPermission) (ok bool, err error) {
+	return f(ctx, field)
+}
+
+type rolesContextKey struct{}
+
+// Returns context, which carries roles of caller, used by RolesPermissionChecker.
+func ContextWithRoles(ctx context.Context, roles ...string) context.Context {
+	return context.WithValue(ctx, rolesContextKey{}, roles)
+}
+
+// Returns roles stored in context with ContextWithRoles.
+func RolesFromContext(ctx context.Context) (roles []string) {
+	roles, _ = ctx.Value(rolesContextKey{}).([]string)
+	return
+}
+
+func hasAnyRole(roles []string, required []string) bool {
+	if len(required) == 0 {
+		return true
+	}
+
+	for _, r := range required {
+		for _, role := range roles {
+			if r == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Checker, which allows write, when caller has any of roles required by target field
+// and any of roles required by mutation field.
+// Roles of caller are taken from context using RolesFromContext.
+var RolesPermissionChecker PermissionChecker = PermissionCheckerFunc(func(ctx context.Context, field FieldPermission) (ok bool, err error) {
+	roles := RolesFromContext(ctx)
+	ok = hasAnyRole(roles, field.TargetRoles) && hasAnyRole(roles, field.MutationRoles)
+	return
+})
+
+// Returned, when caller is not allowed to write some fields of target.
+type PermissionError struct {
+	TargetType reflect.Type
+	Fields     []string
+}
+
+func (err *PermissionError) Error() string {
+	if err == nil {
+		return "<nil>"
+	}
+
+	return "arcah/mttor: writing fields " + strings.Join(err.Fields, ", ") + " of " + err.TargetType.String() + " is forbidden"
+}
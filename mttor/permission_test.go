@@ -0,0 +1,83 @@
+package mttor_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/mttor"
+)
+
+type Profile struct {
+	Name     string
+	Verified bool   `roles:"admin"`
+	Note     string `roles:"admin,owner"`
+}
+
+type ProfilePublicUpdate struct {
+	Name     string `mttor:",,omitempty"`
+	Verified bool   `mttor:",,omitempty"`
+	Note     string `mttor:",,omitempty" roles:"owner"`
+}
+
+func TestMutator_Permissions(t *testing.T) {
+	engine := mttor.NewEngine(mttor.EngineOptions{
+		PermissionChecker: mttor.RolesPermissionChecker,
+	})
+
+	t.Run("allowed", func(t *testing.T) {
+		profile := Profile{}
+		err := engine.Mutate(mttor.ContextWithRoles(context.Background(), "owner"), &profile, ProfilePublicUpdate{
+			Name: "asdf",
+			Note: "note",
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if profile.Name != "asdf" || profile.Note != "note" {
+			t.Error("data wasn't mutated", profile)
+			return
+		}
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		profile := Profile{}
+		err := engine.Mutate(context.Background(), &profile, ProfilePublicUpdate{
+			Name:     "asdf",
+			Verified: true,
+			Note:     "note",
+		})
+
+		var permissionError *mttor.PermissionError
+		if !errors.As(err, &permissionError) {
+			t.Error("expected permission error, got", err)
+			return
+		}
+
+		if !reflect.DeepEqual(permissionError.Fields, []string{"Verified", "Note"}) {
+			t.Error("invalid forbidden fields", permissionError.Fields)
+			return
+		}
+
+		if profile != (Profile{}) {
+			t.Error("data was changed, while expected it not to", profile)
+			return
+		}
+	})
+
+	t.Run("mutation_roles", func(t *testing.T) {
+		mongoEngine := engine.(mttor.MongoEngine)
+		_, err := mongoEngine.RenderMongoMutation(mttor.ContextWithRoles(context.Background(), "admin"), reflect.TypeOf(Profile{}), ProfilePublicUpdate{
+			Note: "note",
+		})
+
+		var permissionError *mttor.PermissionError
+		if !errors.As(err, &permissionError) {
+			t.Error("expected permission error, got", err)
+			return
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package mttor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/teawithsand/arcah/internal/tagparse"
+)
+
+// Returned, when mutation targets fields, which are marked as readonly or immutable.
+type ReadonlyFieldError struct {
+	TargetType reflect.Type
+	Fields     []string
+	// Flag of each of fields, either readonly or immutable.
+	Flags []string
+}
+
+func (err *ReadonlyFieldError) Error() string {
+	if err == nil {
+		return "<nil>"
+	}
+
+	fields := make([]string, 0, len(err.Fields))
+	for i, f := range err.Fields {
+		fields = append(fields, f+" ("+err.Flags[i]+")")
+	}
+	return "arcah/mttor: fields " + strings.Join(fields, ", ") + " of " + err.TargetType.String() + " can't be changed"
+}
+
+// Engine, which is able to check if mutation type touches readonly or immutable fields of target type.
+// It's meant to be used during startup or in tests, so invalid mutation types are found before any request is made.
+type ReadonlyChecker interface {
+	CheckReadonly(ctx context.Context, targetType, mutationType reflect.Type) (err error)
+}
+
+func (dm *defaultMutatorEngine) CheckReadonly(ctx context.Context, targetType, mutationType reflect.Type) (err error) {
+	for targetType.Kind() == reflect.Ptr {
+		targetType = targetType.Elem()
+	}
+
+	targetDescriptor, err := dm.targetComputer.ComputeDescriptor(ctx, targetType)
+	if err != nil {
+		return
+	}
+
+	mutationDescriptor, err := dm.mutationComputer.ComputeDescriptor(ctx, mutationType)
+	if err != nil {
+		return
+	}
+
+	var readonlyFields, readonlyFlags []string
+	for _, mf := range sortedFields(mutationDescriptor) {
+		meta := mf.Meta.(mutatorMeta)
+
+		tf, ok := targetDescriptor.NameToField[meta.TargetFieldName]
+		if !ok {
+			err = &Error{
+				Descriptorion: fmt.Sprintf("Field %s is not available in target of type %s", meta.TargetFieldName, targetType),
+			}
+			return
+		}
+
+		if flag := readonlyFlag(tf.Meta.(mutatorTargetMeta), dm.mutationMap[meta.MutationName]); len(flag) > 0 {
+			readonlyFields = append(readonlyFields, meta.TargetFieldName)
+			readonlyFlags = append(readonlyFlags, flag)
+		}
+	}
+
+	if len(readonlyFields) > 0 {
+		err = &ReadonlyFieldError{
+			TargetType: targetType,
+			Fields:     readonlyFields,
+			Flags:      readonlyFlags,
+		}
+		return
+	}
+
+	return
+}
+
+// Returns flag, which prevents mutator from being applied to target field, or empty string, if it may be applied.
+// Immutable fields may be only set, when entity is created, with insert-only mutators.
+func readonlyFlag(meta mutatorTargetMeta, mutator Mutator) string {
+	if meta.Readonly {
+		return tagparse.ReadonlyFlag
+	}
+	if meta.Immutable && !isInsertOnly(mutator) {
+		return tagparse.ImmutableFlag
+	}
+	return ""
+}
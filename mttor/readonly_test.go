@@ -0,0 +1,136 @@
+package mttor_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/mttor"
+)
+
+type Document struct {
+	ID        string `bson:"_id" mttor:"-,readonly"`
+	OwnerID   string `mttor:"-,immutable"`
+	CreatedAt int64  `mttor:",readonly"`
+	Title     string
+}
+
+// Counter is used both as target and as mutation.
+type Counter struct {
+	Value int64 `mttor:",inc"`
+}
+
+type MisspelledDocument struct {
+	OwnerID string `mttor:"-,imutable"`
+	Title   string
+}
+
+type DocumentSetTitle struct {
+	Title string
+}
+
+type DocumentChangeOwner struct {
+	Title   string
+	OwnerID string `mttor:",,omitempty"`
+}
+
+func TestMutator_Readonly(t *testing.T) {
+	engine := mttor.NewDefaultEngine()
+
+	t.Run("writable", func(t *testing.T) {
+		doc := Document{}
+		err := engine.Mutate(context.Background(), &doc, DocumentSetTitle{
+			Title: "asdf",
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if doc.Title != "asdf" {
+			t.Error("data wasn't mutated", doc)
+			return
+		}
+	})
+
+	t.Run("immutable", func(t *testing.T) {
+		doc := Document{
+			OwnerID: "fdsa",
+		}
+
+		// even empty value is rejected, since mutation type itself is invalid
+		err := engine.Mutate(context.Background(), &doc, DocumentChangeOwner{
+			Title: "asdf",
+		})
+
+		var readonlyError *mttor.ReadonlyFieldError
+		if !errors.As(err, &readonlyError) {
+			t.Error("expected readonly error, got", err)
+			return
+		}
+
+		if doc.Title != "" {
+			t.Error("data was changed, while expected it not to", doc)
+			return
+		}
+
+		_, err = engine.(mttor.MongoEngine).RenderMongoMutation(context.Background(), reflect.TypeOf(doc), DocumentChangeOwner{
+			OwnerID: "asdf",
+		})
+		if !errors.As(err, &readonlyError) {
+			t.Error("expected readonly error, got", err)
+			return
+		}
+	})
+
+	t.Run("check", func(t *testing.T) {
+		checker := engine.(mttor.ReadonlyChecker)
+		err := checker.CheckReadonly(context.Background(), reflect.TypeOf(Document{}), reflect.TypeOf(DocumentSetTitle{}))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		err = checker.CheckReadonly(context.Background(), reflect.TypeOf(Document{}), reflect.TypeOf(DocumentChangeOwner{}))
+		var readonlyError *mttor.ReadonlyFieldError
+		if !errors.As(err, &readonlyError) {
+			t.Error("expected readonly error, got", err)
+			return
+		}
+
+		if !reflect.DeepEqual(readonlyError.Fields, []string{"OwnerID"}) || !reflect.DeepEqual(readonlyError.Flags, []string{"immutable"}) {
+			t.Error("invalid readonly fields", readonlyError.Fields, readonlyError.Flags)
+			return
+		}
+	})
+
+	t.Run("misspelled_flag", func(t *testing.T) {
+		checker := engine.(mttor.ReadonlyChecker)
+		err := checker.CheckReadonly(context.Background(), reflect.TypeOf(MisspelledDocument{}), reflect.TypeOf(DocumentSetTitle{}))
+		if err == nil {
+			t.Error("expected error")
+			return
+		}
+
+		err = engine.Mutate(context.Background(), &MisspelledDocument{}, DocumentSetTitle{Title: "asdf"})
+		if err == nil {
+			t.Error("expected error")
+			return
+		}
+	})
+
+	t.Run("mutation_tags_on_target", func(t *testing.T) {
+		counter := Counter{Value: 1}
+		err := engine.Mutate(context.Background(), &counter, Counter{Value: 2})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if counter.Value != 3 {
+			t.Error("data wasn't mutated", counter)
+			return
+		}
+	})
+}
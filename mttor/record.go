@@ -0,0 +1,61 @@
+package mttor
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/teawithsand/arcah/internal/tagparse"
+)
+
+// RecordedOperation is single field of mutation, which would be applied to target,
+// in form independent of type of mutation.
+type RecordedOperation struct {
+	// Name, which mutations use to refer to target field.
+	TargetFieldName string
+	// Dotted path of target field in BSON document.
+	BSONPath string
+
+	MutationName string
+	Args         MutationArgs
+	Value        interface{}
+}
+
+// Returns key of MapMutation, which applies this operation.
+func (op *RecordedOperation) MapKey() string {
+	return tagparse.FormatMutationTag(tagparse.MutationTag{
+		TargetFieldName: op.TargetFieldName,
+		MutationName:    op.MutationName,
+		Args:            op.Args,
+	})
+}
+
+// Engine, which resolves mutations into operations, so they can be stored and applied later with MapMutation.
+type RecordingEngine interface {
+	// Returns operations, which mutation of target of given type consists of, in order they are applied in.
+	// Fields omitted due to omitempty are not returned.
+	RecordMutation(ctx context.Context, targetType reflect.Type, mutation interface{}) (ops []RecordedOperation, err error)
+}
+
+func (dm *defaultMutatorEngine) RecordMutation(ctx context.Context, targetType reflect.Type, mutation interface{}) (ops []RecordedOperation, err error) {
+	planned, err := dm.planMutation(ctx, targetType, mutation)
+	if err != nil {
+		return
+	}
+
+	ops = make([]RecordedOperation, 0, len(planned))
+	for _, op := range planned {
+		value := op.Data.Value
+		if be, ok := value.(*boundExpr); ok {
+			value = be.source
+		}
+
+		ops = append(ops, RecordedOperation{
+			TargetFieldName: op.Data.FieldName,
+			BSONPath:        op.TargetMeta.BSONPath,
+			MutationName:    op.Data.MutationName,
+			Args:            op.Data.Args,
+			Value:           value,
+		})
+	}
+	return
+}
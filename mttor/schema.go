@@ -0,0 +1,230 @@
+package mttor
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/teawithsand/arcah/jsonschema"
+	"github.com/teawithsand/reval/stdesc"
+)
+
+// Extension keywords of schemas of mutation fields.
+const (
+	// Name of mutation applied with field.
+	SchemaOpKey = "x-arcah-op"
+	// Name of target field, which field mutates.
+	SchemaTargetKey = "x-arcah-target"
+)
+
+// Engine, which describes mutations as JSON schemas, so clients know which requests they may send.
+type SchemaEngine interface {
+	// Returns schema of mutation of given type encoded as JSON, which is applied to target of given type.
+	//
+	// Each field has its mutation and target field set with extension keywords and meaning of mutation in description.
+	// Fields with omitempty arg are optional, since omitting them has no effect, others are required.
+	// Rules of valid tags of mutation fields and, for set mutations, target fields are included.
+	MutationSchema(ctx context.Context, generator *jsonschema.Generator, targetType, mutationType reflect.Type) (schema *jsonschema.Schema, err error)
+}
+
+// Mutator, which describes its meaning for schemas of mutations.
+type DocumentedMutator interface {
+	Mutator
+	// Returns description of mutation field, which mutates target field of given name.
+	DescribeMutation(targetFieldName string) string
+}
+
+func (dm *defaultMutatorEngine) MutationSchema(ctx context.Context, generator *jsonschema.Generator, targetType, mutationType reflect.Type) (schema *jsonschema.Schema, err error) {
+	for targetType.Kind() == reflect.Ptr {
+		targetType = targetType.Elem()
+	}
+	for mutationType.Kind() == reflect.Ptr {
+		mutationType = mutationType.Elem()
+	}
+
+	targetDescriptor, err := dm.targetComputer.ComputeDescriptor(ctx, targetType)
+	if err != nil {
+		return
+	}
+
+	mutationDescriptor, err := dm.mutationComputer.ComputeDescriptor(ctx, mutationType)
+	if err != nil {
+		return
+	}
+
+	sb := schemaBuilder{
+		engine:           dm,
+		generator:        generator,
+		targetType:       targetType,
+		targetDescriptor: targetDescriptor,
+		mutationType:     mutationType,
+		pathToField:      map[fieldPathKey]stdesc.Field{},
+	}
+	for _, f := range mutationDescriptor.NameToField {
+		sb.pathToField[newFieldPathKey(f.Path)] = f
+	}
+
+	schema, err = sb.objectSchema(ctx, mutationType, nil)
+	if err != nil {
+		return
+	}
+
+	if len(sb.readonlyFields) > 0 {
+		schema = nil
+		err = &ReadonlyFieldError{
+			TargetType: targetType,
+			Fields:     sb.readonlyFields,
+			Flags:      sb.readonlyFlags,
+		}
+		return
+	}
+	return
+}
+
+// Key of field in map, which is made of indices of its path.
+type fieldPathKey string
+
+func newFieldPathKey(path []int) fieldPathKey {
+	var b strings.Builder
+	for i, index := range path {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(strconv.Itoa(index))
+	}
+	return fieldPathKey(b.String())
+}
+
+type schemaBuilder struct {
+	engine           *defaultMutatorEngine
+	generator        *jsonschema.Generator
+	targetType       reflect.Type
+	targetDescriptor stdesc.Descriptor
+	mutationType     reflect.Type
+
+	// Fields of mutation by their paths.
+	pathToField    map[fieldPathKey]stdesc.Field
+	readonlyFields []string
+	readonlyFlags  []string
+}
+
+// Returns true, if any mutation field is nested in struct at given path.
+func (sb *schemaBuilder) hasFieldsIn(path []int) bool {
+	key := newFieldPathKey(path)
+	for _, f := range sb.pathToField {
+		if len(f.Path) > len(path) && newFieldPathKey(f.Path[:len(path)]) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns schema of JSON object, which mutation structure of given type at given path is encoded as.
+func (sb *schemaBuilder) objectSchema(ctx context.Context, ty reflect.Type, path []int) (schema *jsonschema.Schema, err error) {
+	schema = &jsonschema.Schema{
+		Type:                 "object",
+		Properties:           map[string]*jsonschema.Schema{},
+		AdditionalProperties: jsonschema.False(),
+	}
+
+	for _, jf := range jsonschema.JSONFields(ty) {
+		fieldPath := append(append([]int{}, path...), jf.Index...)
+
+		var fieldSchema *jsonschema.Schema
+		var required bool
+		if mf, ok := sb.pathToField[newFieldPathKey(fieldPath)]; ok {
+			fieldSchema, required, err = sb.fieldSchema(ctx, mf)
+			if err != nil {
+				return
+			}
+		} else if isStructField(jf.Field) && sb.hasFieldsIn(fieldPath) {
+			// embedded structure is still nested object in JSON
+			fieldType := jf.Field.Type
+			for fieldType.Kind() == reflect.Ptr {
+				fieldType = fieldType.Elem()
+			}
+
+			fieldSchema, err = sb.objectSchema(ctx, fieldType, fieldPath)
+			if err != nil {
+				return
+			}
+			if jf.Field.Type.Kind() == reflect.Ptr {
+				fieldSchema.Type = []string{"object", "null"}
+			}
+		} else {
+			// field is skipped, so it does not matter if it's set
+			continue
+		}
+
+		schema.Properties[jf.Name] = fieldSchema
+		if required {
+			schema.Required = append(schema.Required, jf.Name)
+		}
+	}
+	return
+}
+
+func (sb *schemaBuilder) fieldSchema(ctx context.Context, mf stdesc.Field) (schema *jsonschema.Schema, required bool, err error) {
+	meta := mf.Meta.(mutatorMeta)
+
+	tf, ok := sb.targetDescriptor.NameToField[meta.TargetFieldName]
+	if !ok {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("Field %s is not available in target of type %s", meta.TargetFieldName, sb.targetType),
+		}
+		return
+	}
+
+	mutator, ok := sb.engine.mutationMap[meta.MutationName]
+	if !ok {
+		err = &Error{
+			Descriptorion: fmt.Sprintf("Mutation %s is not registered", meta.MutationName),
+		}
+		return
+	}
+
+	if flag := readonlyFlag(tf.Meta.(mutatorTargetMeta), mutator); len(flag) > 0 {
+		sb.readonlyFields = append(sb.readonlyFields, meta.TargetFieldName)
+		sb.readonlyFlags = append(sb.readonlyFlags, flag)
+	}
+
+	schema = sb.generator.TypeSchema(mf.Type)
+	if len(schema.Ref) == 0 {
+		schema = schema.Clone()
+	}
+
+	rules, err := sb.generator.FieldRules(ctx, sb.mutationType, mf.Path)
+	if err != nil {
+		return
+	}
+
+	required = !meta.TargetMutationArgs.IsSet("omitempty")
+	if jsonschema.ApplyRules(schema, mf.Type, rules) {
+		required = true
+	}
+
+	mutationName := meta.MutationName
+	if len(mutationName) == 0 {
+		mutationName = "set"
+	}
+
+	if _, isSet := mutator.(*setMutation); isSet {
+		rules, err = sb.generator.FieldRules(ctx, sb.targetType, tf.Path)
+		if err != nil {
+			return
+		}
+		jsonschema.ApplyRules(schema, tf.Type, rules)
+	}
+
+	if documented, ok := mutator.(DocumentedMutator); ok {
+		schema.Description = documented.DescribeMutation(meta.TargetFieldName)
+	} else {
+		schema.Description = fmt.Sprintf("Mutation %s of %s", mutationName, meta.TargetFieldName)
+	}
+
+	schema.SetExtension(SchemaOpKey, mutationName)
+	schema.SetExtension(SchemaTargetKey, meta.TargetFieldName)
+	return
+}
@@ -0,0 +1,99 @@
+package mttor_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/jsonschema"
+	"github.com/teawithsand/arcah/mttor"
+)
+
+type SchemaUser struct {
+	Name    string `valid:"max:32"`
+	Visits  int64
+	Tags    []string
+	Created int64 `mttor:",readonly"`
+}
+
+type SchemaUserMutation struct {
+	Name   string   `json:"name" mttor:"Name,,omitempty"`
+	Visits int64    `json:"visits" mttor:"Visits,inc"`
+	Tags   []string `json:"tags,omitempty" mttor:"Tags,push" valid:"min:1"`
+	Note   string   `json:"-" mttor:"-"`
+}
+
+type SchemaUserSetCreated struct {
+	Created int64
+}
+
+func TestMutationSchema(t *testing.T) {
+	engine := mttor.NewDefaultEngine().(mttor.SchemaEngine)
+
+	t.Run("fields", func(t *testing.T) {
+		schema, err := engine.MutationSchema(context.Background(), &jsonschema.Generator{}, reflect.TypeOf(SchemaUser{}), reflect.TypeOf(SchemaUserMutation{}))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		raw, err := json.Marshal(schema)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		var res map[string]interface{}
+		err = json.Unmarshal(raw, &res)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if res["additionalProperties"] != false {
+			t.Error("additional properties are allowed", string(raw))
+			return
+		}
+
+		if !reflect.DeepEqual(res["required"], []interface{}{"visits", "tags"}) {
+			t.Error("invalid required fields", res["required"])
+			return
+		}
+
+		properties := res["properties"].(map[string]interface{})
+		if len(properties) != 3 {
+			t.Error("invalid properties", properties)
+			return
+		}
+
+		visits := properties["visits"].(map[string]interface{})
+		if visits[mttor.SchemaOpKey] != "inc" || visits[mttor.SchemaTargetKey] != "Visits" || visits["type"] != "integer" {
+			t.Error("invalid inc field schema", visits)
+			return
+		}
+
+		name := properties["name"].(map[string]interface{})
+		if name[mttor.SchemaOpKey] != "set" || name["maxLength"] != 32. {
+			t.Error("invalid set field schema", name)
+			return
+		}
+
+		tags := properties["tags"].(map[string]interface{})
+		if tags[mttor.SchemaOpKey] != "push" || tags["minItems"] != 1. {
+			t.Error("invalid push field schema", tags)
+			return
+		}
+	})
+
+	t.Run("readonly", func(t *testing.T) {
+		_, err := engine.MutationSchema(context.Background(), &jsonschema.Generator{}, reflect.TypeOf(SchemaUser{}), reflect.TypeOf(SchemaUserSetCreated{}))
+
+		var readonlyError *mttor.ReadonlyFieldError
+		if !errors.As(err, &readonlyError) {
+			t.Error("expected readonly error, got", err)
+			return
+		}
+	})
+}
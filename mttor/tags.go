@@ -0,0 +1,28 @@
+package mttor
+
+import "github.com/teawithsand/arcah/internal/tagparse"
+
+// Name of tag, which describes fields of mutations and targets.
+const TagName = tagparse.MttorTagName
+
+// MutationTag is parsed mttor tag of mutation field.
+type MutationTag = tagparse.MutationTag
+
+// TargetTag is parsed mttor tag of target field, like `mttor:"-,readonly"`.
+type TargetTag = tagparse.TargetTag
+
+// Parses mttor tag of mutation field the way engines do.
+// It's meant for tools like static analyzers, which check tags without computing descriptors.
+func ParseMutationTag(tag string) (res MutationTag, err error) {
+	return tagparse.ParseMutationTag(tag)
+}
+
+// Parses mttor tag of target field the way engines do.
+func ParseTargetTag(tag string) (res TargetTag, err error) {
+	return tagparse.ParseTargetTag(tag)
+}
+
+// Returns true, if mttor tag belongs to target field rather than mutation field.
+func IsTargetTag(tag string) bool {
+	return tagparse.IsTargetTag(tag)
+}
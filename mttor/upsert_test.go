@@ -0,0 +1,151 @@
+package mttor_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/mttor"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type IngestedDocument struct {
+	SKU     string `mttor:"-,immutable"`
+	Owner   string `mttor:"-,immutable"`
+	Created int64  `mttor:",readonly"`
+	Name    string
+	Seen    int
+}
+
+type IngestDocument struct {
+	SKU   string `mttor:"SKU,setOnInsert"`
+	Owner string `mttor:"Owner,setOnInsert"`
+	Name  string
+	Seen  int `mttor:"Seen,inc"`
+}
+
+type IngestCreated struct {
+	Created int64 `mttor:"Created,setOnInsert"`
+}
+
+func TestMutator_Upsert(t *testing.T) {
+	engine := mttor.NewDefaultEngine()
+	upsertEngine := engine.(mttor.UpsertEngine)
+	mutation := IngestDocument{SKU: "a-1", Owner: "asdf", Name: "fdsa", Seen: 1}
+
+	t.Run("mutate_ignores_insert_only", func(t *testing.T) {
+		doc := IngestedDocument{SKU: "b-2", Owner: "qwer", Seen: 2}
+		err := engine.Mutate(context.Background(), &doc, mutation)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := IngestedDocument{SKU: "b-2", Owner: "qwer", Name: "fdsa", Seen: 3}
+		if doc != expected {
+			t.Error("invalid document", doc)
+			return
+		}
+	})
+
+	t.Run("existing", func(t *testing.T) {
+		doc := IngestedDocument{SKU: "b-2", Seen: 2}
+		err := upsertEngine.Upsert(context.Background(), &doc, mutation, true)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := IngestedDocument{SKU: "b-2", Name: "fdsa", Seen: 3}
+		if doc != expected {
+			t.Error("invalid document", doc)
+			return
+		}
+	})
+
+	t.Run("created", func(t *testing.T) {
+		doc := IngestedDocument{SKU: "b-2", Created: 10, Seen: 2}
+		err := upsertEngine.Upsert(context.Background(), &doc, mutation, false)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := IngestedDocument{SKU: "a-1", Owner: "asdf", Name: "fdsa", Seen: 1}
+		if doc != expected {
+			t.Error("invalid document", doc)
+			return
+		}
+	})
+
+	t.Run("readonly", func(t *testing.T) {
+		doc := IngestedDocument{}
+		err := upsertEngine.Upsert(context.Background(), &doc, IngestCreated{Created: 1}, false)
+
+		var readonlyError *mttor.ReadonlyFieldError
+		if !errors.As(err, &readonlyError) {
+			t.Error("expected readonly error, got", err)
+			return
+		}
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		doc := IngestedDocument{SKU: "b-2", Seen: 2}
+		err := upsertEngine.Upsert(context.Background(), &doc, mttor.MapMutation{
+			"Name":      "fdsa",
+			"Seen,expr": mttor.Div(mttor.Field("Seen"), mttor.Literal(0)),
+		}, false)
+		if err == nil {
+			t.Error("expected error")
+			return
+		}
+
+		expected := IngestedDocument{SKU: "b-2", Seen: 2}
+		if doc != expected {
+			t.Error("document was changed", doc)
+			return
+		}
+	})
+
+	t.Run("document", func(t *testing.T) {
+		doc := bson.M{"SKU": "b-2", "Seen": int64(2)}
+		err := upsertEngine.Upsert(context.Background(), doc, mutation, false)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := bson.M{"SKU": "a-1", "Owner": "asdf", "Name": "fdsa", "Seen": int32(1)}
+		if !reflect.DeepEqual(doc, expected) {
+			t.Error("invalid document", doc)
+			return
+		}
+	})
+
+	t.Run("render", func(t *testing.T) {
+		update, opts, err := upsertEngine.RenderMongoUpsert(context.Background(), reflect.TypeOf(IngestedDocument{}), mutation)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := bson.D{
+			{Key: "$setOnInsert", Value: bson.D{
+				{Key: "sku", Value: "a-1"},
+				{Key: "owner", Value: "asdf"},
+			}},
+			{Key: "$set", Value: bson.D{{Key: "name", Value: "fdsa"}}},
+			{Key: "$inc", Value: bson.D{{Key: "seen", Value: 1}}},
+		}
+		if !reflect.DeepEqual(update, expected) {
+			t.Error("invalid update", update)
+			return
+		}
+
+		if opts.Upsert == nil || !*opts.Upsert {
+			t.Error("upsert is not enabled")
+			return
+		}
+	})
+}
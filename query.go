@@ -8,6 +8,10 @@ type keyValueQuery struct {
 	Value interface{} `json:"value"`
 }
 
+// AllQuery matches all entities.
+// Repositories require it to update or delete all entities, so that nil query can't do that by mistake.
+type AllQuery struct{}
+
 type AndQuery []Query
 type OrQuery []Query
 type NotQuery struct {
@@ -15,8 +15,8 @@ type MongoQueryRenderer struct {
 
 func mongoRenderKeyValueQuery(op string, query keyValueQuery) interface{} {
 	return bson.D{
-		{op, bson.D{
-			{query.Field, query.Value},
+		{Key: op, Value: bson.D{
+			{Key: query.Field, Value: query.Value},
 		}},
 	}
 }
@@ -25,6 +25,9 @@ func (mqr *MongoQueryRenderer) innerRender(query Query) (res interface{}, err er
 	switch typedQuery := query.(type) {
 	case MongoQuery:
 		return typedQuery.RenderMongo()
+	case AllQuery:
+		res = bson.D{}
+		return
 	case AndQuery:
 		mapped := make([]interface{}, 0, len(typedQuery))
 		for _, q := range typedQuery {
@@ -0,0 +1,270 @@
+package arcah
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/teawithsand/arcah/acquery"
+	"github.com/teawithsand/arcah/mttor"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Returned by repository, when no entity matches query.
+var ErrNotFound = errors.New("arcah: entity not found")
+
+// Returned by repository, when entities are updated or deleted with nil query. AllQuery has to be used to match all of them.
+var ErrNilQuery = errors.New("arcah: nil query can't be used to update or delete entities")
+
+// Repository stores entities of type T.
+// Queries are rendered with MongoQueryRenderer, so nil query matches all entities,
+// but Update and Delete return ErrNilQuery for it, so AllQuery has to be given explicitly.
+//
+// Services should depend on this interface, so that fake can be substituted in tests.
+type Repository[T any] interface {
+	// Returns entities matching query, ordered by fields given, which are aliases of order schema of T.
+	Find(ctx context.Context, query Query, order acquery.OrderFields, pagination acquery.Pagination) (res []T, err error)
+	// Returns ErrNotFound, if no entity matches query.
+	FindOne(ctx context.Context, query Query) (res T, err error)
+	Count(ctx context.Context, query Query) (count int64, err error)
+
+	// Applies mutation to all entities matching query and returns count of matched ones.
+	// Returns mttor.ErrEmptyMutation, if there is nothing to update, and ErrNilQuery, if query is nil.
+	Update(ctx context.Context, query Query, mutation interface{}) (matched int64, err error)
+	// Applies mutation to single entity matching query and returns it, as it's after update.
+	// Returns ErrNotFound, if no entity matches query.
+	FindOneAndUpdate(ctx context.Context, query Query, mutation interface{}) (res T, err error)
+
+	// Deletes all entities matching query and returns count of deleted ones.
+	// Returns ErrNilQuery, if query is nil.
+	Delete(ctx context.Context, query Query) (deleted int64, err error)
+}
+
+// Options of repository created with NewMongoRepository.
+type MongoRepositoryOptions struct {
+	// Defaults to mttor.NewMongoEngine().
+	Engine mttor.MongoEngine
+
+	// Schema of fields, which entities may be ordered by.
+	// If nil, it's created for T by OrderSchemaFactory.
+	OrderSchema *acquery.OrderSchema
+	// Defaults to acquery.NewMongoOrderSchemaFactory().
+	OrderSchemaFactory acquery.OrderSchemaFactory
+
+	// Limit of count of entities returned by Find, even if pagination has no limit.
+	// Zero means no limit.
+	MaxLimit uint32
+}
+
+type mongoRepository[T any] struct {
+	collection *mongo.Collection
+	options    MongoRepositoryOptions
+	renderer   MongoQueryRenderer
+
+	// Order schema created by factory, once it's needed first time.
+	// It's created again, if factory failed.
+	schemaLock sync.Mutex
+	schema     *acquery.OrderSchema
+}
+
+var _ Repository[struct{}] = &mongoRepository[struct{}]{}
+
+// Creates repository, which stores entities of type T as documents of collection.
+func NewMongoRepository[T any](collection *mongo.Collection, options MongoRepositoryOptions) Repository[T] {
+	if options.Engine == nil {
+		options.Engine = mttor.NewMongoEngine()
+	}
+	if options.OrderSchemaFactory == nil {
+		options.OrderSchemaFactory = acquery.NewMongoOrderSchemaFactory()
+	}
+
+	return &mongoRepository[T]{
+		collection: collection,
+		options:    options,
+	}
+}
+
+func (r *mongoRepository[T]) filter(query Query) (filter interface{}, err error) {
+	if query == nil {
+		filter = bson.D{}
+		return
+	}
+	return r.renderer.Render(query)
+}
+
+func (r *mongoRepository[T]) orderSchema(ctx context.Context) (schema *acquery.OrderSchema, err error) {
+	if r.options.OrderSchema != nil {
+		schema = r.options.OrderSchema
+		return
+	}
+
+	r.schemaLock.Lock()
+	defer r.schemaLock.Unlock()
+
+	if r.schema == nil {
+		schema, err = r.options.OrderSchemaFactory.CreateOrderSchema(ctx, reflect.TypeOf((*T)(nil)).Elem())
+		if err != nil {
+			return
+		}
+		r.schema = schema
+	}
+
+	schema = r.schema
+	return
+}
+
+func (r *mongoRepository[T]) sort(ctx context.Context, order acquery.OrderFields) (sort bson.D, err error) {
+	schema, err := r.orderSchema(ctx)
+	if err != nil {
+		return
+	}
+
+	err = schema.Validate(order)
+	if err != nil {
+		return
+	}
+
+	sort = schema.Process(order).GetFields()
+	return
+}
+
+// Renders mutation and returns mttor.ErrEmptyMutation, if it's empty, since mongo rejects empty updates.
+func (r *mongoRepository[T]) update(ctx context.Context, mutation interface{}) (update interface{}, err error) {
+	update, err = mttor.RenderFor[T](ctx, r.options.Engine, mutation)
+	if err != nil {
+		return
+	}
+
+	if d, ok := update.(bson.D); ok && len(d) == 0 {
+		update = nil
+		err = mttor.ErrEmptyMutation
+		return
+	}
+	return
+}
+
+func (r *mongoRepository[T]) Find(ctx context.Context, query Query, order acquery.OrderFields, pagination acquery.Pagination) (res []T, err error) {
+	filter, err := r.filter(query)
+	if err != nil {
+		return
+	}
+
+	sort, err := r.sort(ctx, order)
+	if err != nil {
+		return
+	}
+
+	if r.options.MaxLimit > 0 {
+		// zero limit means no limit for mongo
+		if pagination.Limit == 0 {
+			pagination.Limit = r.options.MaxLimit
+		}
+		pagination = pagination.WithMaxLimit(r.options.MaxLimit)
+	}
+
+	opts := options.Find().SetSkip(int64(pagination.Offset)).SetLimit(int64(pagination.Limit))
+	if len(sort) > 0 {
+		opts.SetSort(sort)
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return
+	}
+
+	res = []T{}
+	err = cursor.All(ctx, &res)
+	return
+}
+
+func (r *mongoRepository[T]) FindOne(ctx context.Context, query Query) (res T, err error) {
+	filter, err := r.filter(query)
+	if err != nil {
+		return
+	}
+
+	err = r.collection.FindOne(ctx, filter).Decode(&res)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = ErrNotFound
+	}
+	return
+}
+
+func (r *mongoRepository[T]) Count(ctx context.Context, query Query) (count int64, err error) {
+	filter, err := r.filter(query)
+	if err != nil {
+		return
+	}
+
+	return r.collection.CountDocuments(ctx, filter)
+}
+
+func (r *mongoRepository[T]) Update(ctx context.Context, query Query, mutation interface{}) (matched int64, err error) {
+	if query == nil {
+		err = ErrNilQuery
+		return
+	}
+
+	filter, err := r.filter(query)
+	if err != nil {
+		return
+	}
+
+	update, err := r.update(ctx, mutation)
+	if err != nil {
+		return
+	}
+
+	res, err := r.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return
+	}
+
+	matched = res.MatchedCount
+	return
+}
+
+func (r *mongoRepository[T]) FindOneAndUpdate(ctx context.Context, query Query, mutation interface{}) (res T, err error) {
+	filter, err := r.filter(query)
+	if err != nil {
+		return
+	}
+
+	update, err := r.update(ctx, mutation)
+	if errors.Is(err, mttor.ErrEmptyMutation) {
+		// nothing changes, so entity is returned as it is
+		return r.FindOne(ctx, query)
+	}
+	if err != nil {
+		return
+	}
+
+	err = r.collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&res)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = ErrNotFound
+	}
+	return
+}
+
+func (r *mongoRepository[T]) Delete(ctx context.Context, query Query) (deleted int64, err error) {
+	if query == nil {
+		err = ErrNilQuery
+		return
+	}
+
+	filter, err := r.filter(query)
+	if err != nil {
+		return
+	}
+
+	res, err := r.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return
+	}
+
+	deleted = res.DeletedCount
+	return
+}
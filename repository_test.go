@@ -0,0 +1,413 @@
+package arcah_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/teawithsand/arcah"
+	"github.com/teawithsand/arcah/acquery"
+	"github.com/teawithsand/arcah/mttor"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Item struct {
+	ID    string `bson:"_id" mttor:"-,readonly"`
+	Name  string `bson:"name"`
+	Stock int64  `bson:"stock"`
+}
+
+type ItemRestock struct {
+	Stock int64 `mttor:"Stock,inc"`
+}
+
+type ItemRename struct {
+	Name string `mttor:",,omitempty"`
+}
+
+// countingOrderSchemaFactory counts created schemas and fails first failures times.
+type countingOrderSchemaFactory struct {
+	acquery.OrderSchemaFactory
+	count    int
+	failures int
+}
+
+var errSchemaFault = errors.New("schema fault")
+
+func (f *countingOrderSchemaFactory) CreateOrderSchema(ctx context.Context, ty reflect.Type) (*acquery.OrderSchema, error) {
+	f.count++
+	if f.count <= f.failures {
+		return nil, errSchemaFault
+	}
+	return f.OrderSchemaFactory.CreateOrderSchema(ctx, ty)
+}
+
+type nameQuery string
+
+func (q nameQuery) RenderMongo() (res interface{}, err error) {
+	res = bson.D{{Key: "name", Value: string(q)}}
+	return
+}
+
+// Uses mongo server from ARCAH_TEST_MONGO, if it's set.
+// Otherwise returns collection of client, which is not connected, so only errors returned before querying can be tested.
+func testCollection(t *testing.T) (collection *mongo.Collection, connected bool) {
+	uri := os.Getenv("ARCAH_TEST_MONGO")
+	if len(uri) == 0 {
+		client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client.Database("arcah").Collection("items"), false
+	}
+
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	database := client.Database("arcah_repository")
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+
+		database.Drop(ctx)
+		client.Disconnect(ctx)
+	})
+	return database.Collection("items"), true
+}
+
+func TestRepository_Mongo(t *testing.T) {
+	ctx := context.Background()
+	collection, connected := testCollection(t)
+	repo := arcah.NewMongoRepository[Item](collection, arcah.MongoRepositoryOptions{
+		MaxLimit: 2,
+	})
+
+	t.Run("invalid_order", func(t *testing.T) {
+		_, err := repo.Find(ctx, nil, acquery.OrderFields{{Name: "unknown"}}, acquery.Pagination{})
+		if !errors.Is(err, acquery.ErrInvalidOrderFields) {
+			t.Error("expected invalid order error, got", err)
+			return
+		}
+	})
+
+	t.Run("order_schema_created_once", func(t *testing.T) {
+		factory := &countingOrderSchemaFactory{OrderSchemaFactory: acquery.NewMongoOrderSchemaFactory()}
+		repo := arcah.NewMongoRepository[Item](collection, arcah.MongoRepositoryOptions{
+			OrderSchemaFactory: factory,
+		})
+
+		for i := 0; i < 2; i++ {
+			_, err := repo.Find(ctx, nil, acquery.OrderFields{{Name: "unknown"}}, acquery.Pagination{})
+			if !errors.Is(err, acquery.ErrInvalidOrderFields) {
+				t.Error("expected invalid order error, got", err)
+				return
+			}
+		}
+
+		if factory.count != 1 {
+			t.Error("order schema created", factory.count, "times")
+			return
+		}
+	})
+
+	t.Run("order_schema_error_not_cached", func(t *testing.T) {
+		factory := &countingOrderSchemaFactory{OrderSchemaFactory: acquery.NewMongoOrderSchemaFactory(), failures: 1}
+		repo := arcah.NewMongoRepository[Item](collection, arcah.MongoRepositoryOptions{
+			OrderSchemaFactory: factory,
+		})
+
+		_, err := repo.Find(ctx, nil, acquery.OrderFields{{Name: "unknown"}}, acquery.Pagination{})
+		if !errors.Is(err, errSchemaFault) {
+			t.Error("expected schema fault, got", err)
+			return
+		}
+
+		_, err = repo.Find(ctx, nil, acquery.OrderFields{{Name: "unknown"}}, acquery.Pagination{})
+		if !errors.Is(err, acquery.ErrInvalidOrderFields) {
+			t.Error("expected invalid order error, got", err)
+			return
+		}
+	})
+
+	t.Run("nil_query", func(t *testing.T) {
+		_, err := repo.Update(ctx, nil, ItemRename{Name: "x"})
+		if !errors.Is(err, arcah.ErrNilQuery) {
+			t.Error("expected nil query error, got", err)
+			return
+		}
+
+		_, err = repo.Delete(ctx, nil)
+		if !errors.Is(err, arcah.ErrNilQuery) {
+			t.Error("expected nil query error, got", err)
+			return
+		}
+	})
+
+	t.Run("empty_mutation", func(t *testing.T) {
+		_, err := repo.Update(ctx, nameQuery("a"), ItemRename{})
+		if !errors.Is(err, mttor.ErrEmptyMutation) {
+			t.Error("expected empty mutation error, got", err)
+			return
+		}
+	})
+
+	if !connected {
+		return
+	}
+
+	_, err := collection.InsertMany(ctx, []interface{}{
+		Item{ID: "1", Name: "a", Stock: 1},
+		Item{ID: "2", Name: "b", Stock: 2},
+		Item{ID: "3", Name: "c", Stock: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("find", func(t *testing.T) {
+		res, err := repo.Find(ctx, nil, acquery.OrderFields{{Name: "stock", Desc: true}}, acquery.Pagination{Offset: 1})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		expected := []Item{{ID: "2", Name: "b", Stock: 2}, {ID: "1", Name: "a", Stock: 1}}
+		if !reflect.DeepEqual(res, expected) {
+			t.Error("invalid result", res)
+			return
+		}
+	})
+
+	t.Run("count", func(t *testing.T) {
+		count, err := repo.Count(ctx, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if count != 3 {
+			t.Error("invalid count", count)
+			return
+		}
+	})
+
+	t.Run("find_one_and_update", func(t *testing.T) {
+		res, err := repo.FindOneAndUpdate(ctx, nameQuery("b"), ItemRestock{Stock: 5})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		if res != (Item{ID: "2", Name: "b", Stock: 7}) {
+			t.Error("invalid result", res)
+			return
+		}
+
+		_, err = repo.FindOneAndUpdate(ctx, nameQuery("x"), ItemRestock{Stock: 5})
+		if !errors.Is(err, arcah.ErrNotFound) {
+			t.Error("expected not found error, got", err)
+			return
+		}
+	})
+
+	t.Run("update_and_delete", func(t *testing.T) {
+		matched, err := repo.Update(ctx, nameQuery("a"), ItemRename{Name: "d"})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if matched != 1 {
+			t.Error("invalid matched count", matched)
+			return
+		}
+
+		deleted, err := repo.Delete(ctx, nameQuery("d"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if deleted != 1 {
+			t.Error("invalid deleted count", deleted)
+			return
+		}
+
+		_, err = repo.FindOne(ctx, nameQuery("d"))
+		if !errors.Is(err, arcah.ErrNotFound) {
+			t.Error("expected not found error, got", err)
+			return
+		}
+
+		deleted, err = repo.Delete(ctx, arcah.AllQuery{})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if deleted != 2 {
+			t.Error("invalid deleted count", deleted)
+			return
+		}
+	})
+}
+
+// memoryRepository is fake Repository, which keeps entities in memory.
+// It supports only nameQuery and AllQuery and ignores order.
+type memoryRepository struct {
+	engine mttor.Engine
+	items  []Item
+}
+
+var _ arcah.Repository[Item] = &memoryRepository{}
+
+func (r *memoryRepository) matches(query arcah.Query, item Item) bool {
+	switch q := query.(type) {
+	case nil, arcah.AllQuery:
+		return true
+	case nameQuery:
+		return item.Name == string(q)
+	}
+	return false
+}
+
+func (r *memoryRepository) Find(ctx context.Context, query arcah.Query, order acquery.OrderFields, pagination acquery.Pagination) (res []Item, err error) {
+	res = []Item{}
+	for _, item := range r.items {
+		if r.matches(query, item) {
+			res = append(res, item)
+		}
+	}
+
+	if int(pagination.Offset) >= len(res) {
+		res = res[:0]
+		return
+	}
+	res = res[pagination.Offset:]
+	if pagination.Limit > 0 && int(pagination.Limit) < len(res) {
+		res = res[:pagination.Limit]
+	}
+	return
+}
+
+func (r *memoryRepository) FindOne(ctx context.Context, query arcah.Query) (res Item, err error) {
+	for _, item := range r.items {
+		if r.matches(query, item) {
+			res = item
+			return
+		}
+	}
+	err = arcah.ErrNotFound
+	return
+}
+
+func (r *memoryRepository) Count(ctx context.Context, query arcah.Query) (count int64, err error) {
+	res, err := r.Find(ctx, query, nil, acquery.Pagination{})
+	count = int64(len(res))
+	return
+}
+
+func (r *memoryRepository) Update(ctx context.Context, query arcah.Query, mutation interface{}) (matched int64, err error) {
+	if query == nil {
+		err = arcah.ErrNilQuery
+		return
+	}
+
+	for i := range r.items {
+		if !r.matches(query, r.items[i]) {
+			continue
+		}
+
+		err = r.engine.Mutate(ctx, &r.items[i], mutation)
+		if err != nil {
+			return
+		}
+		matched++
+	}
+	return
+}
+
+func (r *memoryRepository) FindOneAndUpdate(ctx context.Context, query arcah.Query, mutation interface{}) (res Item, err error) {
+	for i := range r.items {
+		if !r.matches(query, r.items[i]) {
+			continue
+		}
+
+		err = r.engine.Mutate(ctx, &r.items[i], mutation)
+		res = r.items[i]
+		return
+	}
+	err = arcah.ErrNotFound
+	return
+}
+
+func (r *memoryRepository) Delete(ctx context.Context, query arcah.Query) (deleted int64, err error) {
+	if query == nil {
+		err = arcah.ErrNilQuery
+		return
+	}
+
+	kept := r.items[:0]
+	for _, item := range r.items {
+		if r.matches(query, item) {
+			deleted++
+			continue
+		}
+		kept = append(kept, item)
+	}
+	r.items = kept
+	return
+}
+
+// Sells one item with given name, removing it from repository, when it's sold out.
+// It's service, which depends on repository only by its interface.
+func sellItem(ctx context.Context, repo arcah.Repository[Item], name string) (err error) {
+	item, err := repo.FindOneAndUpdate(ctx, nameQuery(name), ItemRestock{Stock: -1})
+	if err != nil {
+		return
+	}
+
+	if item.Stock <= 0 {
+		_, err = repo.Delete(ctx, nameQuery(name))
+	}
+	return
+}
+
+func TestRepository_Fake(t *testing.T) {
+	ctx := context.Background()
+	repo := &memoryRepository{
+		engine: mttor.NewDefaultEngine(),
+		items: []Item{
+			{ID: "1", Name: "a", Stock: 1},
+			{ID: "2", Name: "b", Stock: 2},
+		},
+	}
+
+	for _, name := range []string{"a", "b"} {
+		err := sellItem(ctx, repo, name)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	res, err := repo.Find(ctx, arcah.AllQuery{}, nil, acquery.Pagination{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(res, []Item{{ID: "2", Name: "b", Stock: 1}}) {
+		t.Error("invalid items", res)
+		return
+	}
+
+	err = sellItem(ctx, repo, "a")
+	if !errors.Is(err, arcah.ErrNotFound) {
+		t.Error("expected not found error, got", err)
+		return
+	}
+}
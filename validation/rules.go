@@ -0,0 +1,115 @@
+// Package validation validates structures using rules from their valid tags.
+//
+// Tags are comma separated lists of rules, like `valid:"required,min:1,max:32"`. Supported rules are:
+//
+//	required         value is not zero value
+//	min:n, max:n     bounds, which are inclusive
+//	gt:n, lt:n       bounds, which are exclusive
+//	len:n            exact length
+//	oneof:a b c      one of space separated values
+//	email, url, uuid format of string
+//
+// Bounds limit length of strings (counted in runes), slices, arrays and maps, or values of numbers.
+// Nil pointers are checked only by required rule, other rules apply to values pointers point to.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/teawithsand/arcah/internal/tagparse"
+)
+
+// Name of tag with validation rules.
+const TagName = "valid"
+
+var ErrInvalidTag = errors.New("arcah/validation: invalid valid tag")
+
+// Formats of strings, which can be required with rules.
+const (
+	FormatEmail = "email"
+	FormatURL   = "url"
+	FormatUUID  = "uuid"
+)
+
+// Rules of single field.
+type Rules struct {
+	Required bool
+
+	Min *float64
+	Max *float64
+	Gt  *float64
+	Lt  *float64
+	Len *float64
+
+	OneOf []string
+	// One of Format* constants or empty, if format is not checked.
+	Format string
+}
+
+// Returns true, if there are no rules.
+func (r *Rules) IsEmpty() bool {
+	return !r.Required && r.Min == nil && r.Max == nil && r.Gt == nil && r.Lt == nil && r.Len == nil &&
+		len(r.OneOf) == 0 && len(r.Format) == 0
+}
+
+// Parses rules from value of valid tag.
+func ParseRules(tag string) (rules Rules, err error) {
+	parsed, err := tagparse.ParseValidTag(tag)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", ErrInvalidTag, err)
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, r := range parsed {
+		name, value := r.Name, r.Value
+		if seen[name] {
+			err = fmt.Errorf("%w: rule %s is set more than once", ErrInvalidTag, name)
+			return
+		}
+		seen[name] = true
+
+		switch name {
+		case "required":
+			rules.Required = true
+		case FormatEmail, FormatURL, FormatUUID:
+			rules.Format = name
+		case "oneof":
+			rules.OneOf = strings.Fields(value)
+		case "min", "max", "gt", "lt", "len":
+			var bound float64
+			bound, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				err = fmt.Errorf("%w: rule %s requires number", ErrInvalidTag, name)
+				return
+			}
+
+			switch name {
+			case "min":
+				rules.Min = &bound
+			case "max":
+				rules.Max = &bound
+			case "gt":
+				rules.Gt = &bound
+			case "lt":
+				rules.Lt = &bound
+			case "len":
+				rules.Len = &bound
+			}
+			continue
+		default:
+			err = fmt.Errorf("%w: unknown rule %s", ErrInvalidTag, name)
+			return
+		}
+
+		// only oneof has value
+		if (name == "oneof") != r.HasValue {
+			err = fmt.Errorf("%w: invalid value of rule %s", ErrInvalidTag, name)
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,111 @@
+package validation_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/teawithsand/arcah/validation"
+)
+
+type Address struct {
+	City string `valid:"required"`
+}
+
+type User struct {
+	Name  string   `valid:"required,min:2,max:4"`
+	Email string   `valid:"email"`
+	Role  string   `valid:"oneof:admin user"`
+	Age   int      `valid:"gt:0,lt:150"`
+	Tags  []string `valid:"max:2"`
+	HQ    *Address
+	Home  Address
+
+	secret string `valid:"required"`
+}
+
+func TestValidator_Validate(t *testing.T) {
+	validator := validation.NewValidator()
+	valid := User{Name: "abc", Email: "a@b.c", Role: "user", Age: 20, Home: Address{City: "x"}}
+
+	for _, tc := range []struct {
+		name     string
+		mutate   func(u *User)
+		expected []validation.FieldError
+	}{
+		{name: "valid", mutate: func(u *User) {}},
+		{name: "required", mutate: func(u *User) { u.Name = "" }, expected: []validation.FieldError{{Path: "Name", Rule: "required"}}},
+		{name: "max", mutate: func(u *User) { u.Name = "abcde" }, expected: []validation.FieldError{{Path: "Name", Rule: "max"}}},
+		{name: "email", mutate: func(u *User) { u.Email = "asdf" }, expected: []validation.FieldError{{Path: "Email", Rule: "email"}}},
+		{name: "oneof", mutate: func(u *User) { u.Role = "root" }, expected: []validation.FieldError{{Path: "Role", Rule: "oneof"}}},
+		{name: "gt", mutate: func(u *User) { u.Age = 0 }, expected: []validation.FieldError{{Path: "Age", Rule: "gt"}}},
+		{name: "slice", mutate: func(u *User) { u.Tags = []string{"a", "b", "c"} }, expected: []validation.FieldError{{Path: "Tags", Rule: "max"}}},
+		{name: "nested", mutate: func(u *User) { u.HQ = &Address{} }, expected: []validation.FieldError{{Path: "HQ.City", Rule: "required"}}},
+		{
+			name:   "many",
+			mutate: func(u *User) { u.Age = 200; u.Home.City = "" },
+			expected: []validation.FieldError{
+				{Path: "Age", Rule: "lt"},
+				{Path: "Home.City", Rule: "required"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			user := valid
+			tc.mutate(&user)
+
+			err := validator.Validate(context.Background(), &user)
+			if tc.expected == nil {
+				if err != nil {
+					t.Error(err)
+				}
+				return
+			}
+
+			var validationError *validation.Error
+			if !errors.As(err, &validationError) {
+				t.Error("expected validation error, got", err)
+				return
+			}
+
+			if !reflect.DeepEqual(validationError.Fields, tc.expected) {
+				t.Error("invalid violations", validationError.Fields)
+				return
+			}
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := validation.ParseRules("required,min:1,oneof:a b")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !rules.Required || rules.Min == nil || *rules.Min != 1 || !reflect.DeepEqual(rules.OneOf, []string{"a", "b"}) {
+		t.Error("invalid rules", rules)
+		return
+	}
+
+	for _, tag := range []string{"min", "min:a", "required:1", "oneof", "unknown", "min:1,min:2"} {
+		_, err := validation.ParseRules(tag)
+		if !errors.Is(err, validation.ErrInvalidTag) {
+			t.Error("expected invalid tag error for", tag, "got", err)
+			return
+		}
+	}
+}
+
+func TestValidator_FieldRules(t *testing.T) {
+	rules, err := validation.DefaultValidator.FieldRules(context.Background(), reflect.TypeOf(User{}), []int{5, 0})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !rules.Required {
+		t.Error("invalid rules", rules)
+		return
+	}
+}
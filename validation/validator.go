@@ -0,0 +1,295 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/teawithsand/arcah/internal/refutil"
+	"github.com/teawithsand/reval"
+	"github.com/teawithsand/reval/stdesc"
+)
+
+// FieldError is violation of rule of single field.
+type FieldError struct {
+	// Dotted path of field, made of names of go fields.
+	Path string
+	Rule string
+}
+
+// Error is returned, when value violates any rules.
+type Error struct {
+	Fields []FieldError
+}
+
+func (err *Error) Error() string {
+	if err == nil {
+		return "<nil>"
+	}
+
+	violations := make([]string, 0, len(err.Fields))
+	for _, f := range err.Fields {
+		violations = append(violations, f.Path+" ("+f.Rule+")")
+	}
+	return "arcah/validation: fields violate rules: " + strings.Join(violations, ", ")
+}
+
+type fieldMeta struct {
+	Rules Rules
+	// Set for unexported fields, which can't be read.
+	Ignored bool
+}
+
+// Validator checks rules of valid tags of structures.
+// Rules of each structure type are parsed once.
+//
+// It implements mttor.TargetValidator, so it can be set in mttor.EngineOptions.
+type Validator struct {
+	computer *stdesc.Computer
+}
+
+// Validator used, when none is provided.
+var DefaultValidator = NewValidator()
+
+func NewValidator() *Validator {
+	return &Validator{
+		computer: &stdesc.Computer{
+			// each structure is described separately, including embedded ones, which are validated like other nested ones
+			FieldProcessorFactory: stdesc.FieldProcessorFunc(func(pf stdesc.PendingFiled) (options stdesc.FieldOptions, err error) {
+				options.Name = pf.Field.Name
+				if !pf.Field.IsExported() {
+					options.Meta = fieldMeta{Ignored: true}
+					return
+				}
+
+				rules, err := ParseRules(pf.Field.Tag.Get(TagName))
+				if err != nil {
+					err = fmt.Errorf("%w (field %s)", err, pf.Field.Name)
+					return
+				}
+
+				options.Meta = fieldMeta{Rules: rules}
+				return
+			}),
+			Cache: &sync.Map{},
+		},
+	}
+}
+
+// Returns rules of field at given path in structure of given type.
+// Path is index of field, like one of reflect.StructField.
+func (v *Validator) FieldRules(ctx context.Context, ty reflect.Type, path []int) (rules Rules, err error) {
+	for i, index := range path {
+		for ty.Kind() == reflect.Ptr {
+			ty = ty.Elem()
+		}
+
+		if i < len(path)-1 {
+			ty = ty.Field(index).Type
+			continue
+		}
+
+		var desc stdesc.Descriptor
+		desc, err = v.computer.ComputeDescriptor(ctx, ty)
+		if err != nil {
+			return
+		}
+
+		rules = desc.NameToField[ty.Field(index).Name].Meta.(fieldMeta).Rules
+	}
+	return
+}
+
+// Checks, if structure value or pointer to it is valid.
+// Nested structures are validated as well.
+// Returns *Error, when any rule is violated.
+func (v *Validator) Validate(ctx context.Context, value interface{}) (err error) {
+	refValue := reflect.ValueOf(value)
+	for refValue.Kind() == reflect.Ptr {
+		if refValue.IsNil() {
+			return
+		}
+		refValue = refValue.Elem()
+	}
+
+	if refValue.Kind() != reflect.Struct {
+		err = fmt.Errorf("arcah/validation: value of type %T is not structure", value)
+		return
+	}
+
+	var violations []FieldError
+	err = v.validateStruct(ctx, refValue, "", &violations)
+	if err != nil {
+		return
+	}
+
+	if len(violations) > 0 {
+		err = &Error{Fields: violations}
+	}
+	return
+}
+
+// Checks value wrapped with reval, like Validate does.
+// Values other than structures have no valid tags, so they are always valid.
+func (v *Validator) ValidateTarget(ctx context.Context, target reval.Value) (err error) {
+	if target == nil {
+		return
+	}
+
+	ty := reflect.TypeOf(target.Raw())
+	for ty.Kind() == reflect.Ptr {
+		ty = ty.Elem()
+	}
+	if ty.Kind() != reflect.Struct {
+		return
+	}
+	return v.Validate(ctx, target.Raw())
+}
+
+func (v *Validator) validateStruct(ctx context.Context, value reflect.Value, prefix string, violations *[]FieldError) (err error) {
+	desc, err := v.computer.ComputeDescriptor(ctx, value.Type())
+	if err != nil {
+		return
+	}
+
+	fields := make([]stdesc.Field, 0, len(desc.NameToField))
+	for _, f := range desc.NameToField {
+		fields = append(fields, f)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Path[0] < fields[j].Path[0]
+	})
+
+	for _, f := range fields {
+		meta := f.Meta.(fieldMeta)
+		if meta.Ignored {
+			continue
+		}
+
+		fieldValue := value.Field(f.Path[0])
+		path := prefix + f.Name
+
+		if rule, ok := checkRules(&meta.Rules, fieldValue); !ok {
+			*violations = append(*violations, FieldError{Path: path, Rule: rule})
+			continue
+		}
+
+		for fieldValue.Kind() == reflect.Ptr && !fieldValue.IsNil() {
+			fieldValue = fieldValue.Elem()
+		}
+		if fieldValue.Kind() == reflect.Struct {
+			err = v.validateStruct(ctx, fieldValue, path+".", violations)
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Returns name of first rule value violates.
+func checkRules(rules *Rules, value reflect.Value) (rule string, ok bool) {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return "required", !rules.Required
+		}
+		value = value.Elem()
+	}
+
+	if rules.Required && value.IsZero() {
+		return "required", false
+	}
+
+	if size, isSized := sizeOf(value); isSized {
+		switch {
+		case rules.Min != nil && size < *rules.Min:
+			return "min", false
+		case rules.Max != nil && size > *rules.Max:
+			return "max", false
+		case rules.Gt != nil && size <= *rules.Gt:
+			return "gt", false
+		case rules.Lt != nil && size >= *rules.Lt:
+			return "lt", false
+		case rules.Len != nil && size != *rules.Len:
+			return "len", false
+		}
+	}
+
+	if len(rules.OneOf) > 0 && !isOneOf(rules.OneOf, value) {
+		return "oneof", false
+	}
+
+	if len(rules.Format) > 0 && value.Kind() == reflect.String && !hasFormat(rules.Format, value.String()) {
+		return rules.Format, false
+	}
+
+	return "", true
+}
+
+// Returns length of strings, slices, arrays and maps or value of numbers, which bounds apply to.
+func sizeOf(value reflect.Value) (size float64, ok bool) {
+	switch value.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), true
+	}
+
+	switch n := refutil.ValueToNumber(value).(type) {
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return
+}
+
+func isOneOf(options []string, value reflect.Value) bool {
+	if value.Kind() == reflect.String {
+		for _, o := range options {
+			if value.String() == o {
+				return true
+			}
+		}
+		return false
+	}
+
+	n, isNumber := sizeOf(value)
+	if !isNumber || value.Kind() == reflect.Slice || value.Kind() == reflect.Array || value.Kind() == reflect.Map {
+		// rule does not apply to other values
+		return true
+	}
+
+	for _, o := range options {
+		if f, err := strconv.ParseFloat(o, 64); err == nil && f == n {
+			return true
+		}
+	}
+	return false
+}
+
+func hasFormat(format, s string) bool {
+	switch format {
+	case FormatEmail:
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	case FormatURL:
+		u, err := url.Parse(s)
+		return err == nil && len(u.Scheme) > 0 && len(u.Host) > 0
+	case FormatUUID:
+		return uuidRegexp.MatchString(s)
+	}
+	return true
+}